@@ -0,0 +1,9 @@
+// Package api содержит машиночитаемые описания API сервиса: OpenAPI для HTTP и protobuf для gRPC.
+package api
+
+import _ "embed"
+
+// OpenAPI - это описание HTTP-API в формате OpenAPI 3.
+//
+//go:embed openapi.json
+var OpenAPI []byte
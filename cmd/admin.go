@@ -0,0 +1,284 @@
+package main
+
+import (
+	"Darkyfun/UrlShortener/internal/clicks"
+	"Darkyfun/UrlShortener/internal/config"
+	"Darkyfun/UrlShortener/internal/links"
+	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/service"
+	"Darkyfun/UrlShortener/internal/storage/cache"
+	"Darkyfun/UrlShortener/internal/storage/persistent"
+	"Darkyfun/UrlShortener/internal/webhooks"
+	"Darkyfun/UrlShortener/internal/workspace"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// adminTimeout - это время, отведённое на выполнение одной административной команды.
+const adminTimeout = time.Second * 10
+
+var errUsage = errors.New("missing required flag")
+
+// adminEnv - это подключения к хранилищам, необходимые административным командам.
+type adminEnv struct {
+	db  persistent.Db
+	rdb *cache.RapidDb
+	// events ставит события в очередь доставок, доставляет их работающий сервер.
+	events webhooks.Publisher
+	// svc создаёт ссылки и считает статистику так же, как HTTP и gRPC API.
+	svc *service.Shortener
+}
+
+// close закрывает подключения к хранилищам.
+func (e *adminEnv) close() {
+	e.db.Close()
+	if err := e.rdb.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// connect читает конфигурацию так же, как сервер, и подключается к кэшу и SQL-базе данных.
+func connect(configPath string) (*adminEnv, error) {
+	conf, err := config.Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := logging.NewLogger(conf.Log.OutputType, os.Stderr)
+	env := &adminEnv{
+		db:  persistent.Connect(context.Background(), logger, storageOpts(conf.Storage)),
+		rdb: cache.NewCacheDb(cacheOpts(conf.Cache), logger),
+	}
+	env.events = webhooks.NewDispatcher(&env.db, logger, webhooks.Options(conf.Webhooks))
+
+	// команды не перенаправляют клиентов, поэтому переходы не записываются.
+	env.svc = service.NewShortener(env.rdb, &env.db, logger, clicks.Discard{}, conf.Server.Addr)
+	env.svc.SetBlocklist(conf.Runtime.Blocklist)
+	env.svc.SetDeadlines(service.Deadlines(conf.Deadlines))
+	env.svc.SetTTLPolicy(ttlPolicy(conf.Cache))
+	env.svc.SetPublisher(env.events)
+	return env, nil
+}
+
+// createCmd создаёт короткую ссылку теми же проверками, что и /receive, и выводит её псевдоним.
+// Без -alias, как и /receive, переиспользует существующую ссылку на тот же URL.
+// Использование: create -config=config/conf.yaml -url=https://example.com [-alias=promo]
+func createCmd(args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file, $SHORTENER_CONFIG_PATH by default")
+	url := fs.String("url", "", "original url")
+	alias := fs.String("alias", "", "alias, random by default")
+	_ = fs.Parse(args)
+
+	if *url == "" {
+		return fmt.Errorf("%w: -url", errUsage)
+	}
+	req, err := service.NewShortenRequest(*url, nil, nil, links.Meta{})
+	if err != nil {
+		return err
+	}
+	if *alias != "" {
+		if req, err = req.WithAlias(*alias); err != nil {
+			return err
+		}
+	}
+
+	env, err := connect(*configPath)
+	if err != nil {
+		return err
+	}
+	defer env.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	link, err := env.svc.Shorten(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(link.Alias)
+	return nil
+}
+
+// resolveCmd показывает, куда ведёт псевдоним, и есть ли он в кэше.
+// Использование: resolve -config=config/conf.yaml -alias=promo
+func resolveCmd(args []string) error {
+	fs := flag.NewFlagSet("resolve", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file, $SHORTENER_CONFIG_PATH by default")
+	alias := fs.String("alias", "", "alias to resolve")
+	_ = fs.Parse(args)
+
+	if *alias == "" {
+		return fmt.Errorf("%w: -alias", errUsage)
+	}
+
+	env, err := connect(*configPath)
+	if err != nil {
+		return err
+	}
+	defer env.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	orig, err := env.db.GetOriginal(ctx, *alias)
+	if err != nil {
+		return err
+	}
+
+	cached, err := env.rdb.Get(ctx, *alias)
+	switch {
+	case errors.Is(err, cache.ErrCacheMiss):
+		cached = "(not cached)"
+	case err != nil:
+		return err
+	}
+
+	rules, err := env.db.GetRules(ctx, *alias)
+	if err != nil {
+		return err
+	}
+	ab, err := env.db.GetSplit(ctx, *alias)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "alias\t%s\n", *alias)
+	fmt.Fprintf(w, "original\t%s\n", orig)
+	fmt.Fprintf(w, "cached\t%s\n", cached)
+	fmt.Fprintf(w, "rules\t%d\n", len(rules))
+	fmt.Fprintf(w, "variants\t%d\n", len(ab.Variants))
+	return w.Flush()
+}
+
+// deleteCmd удаляет ссылку из SQL-базы данных и все её записи из кэша.
+// Использование: delete -config=config/conf.yaml -alias=promo
+func deleteCmd(args []string) error {
+	fs := flag.NewFlagSet("delete", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file, $SHORTENER_CONFIG_PATH by default")
+	alias := fs.String("alias", "", "alias to delete")
+	_ = fs.Parse(args)
+
+	if *alias == "" {
+		return fmt.Errorf("%w: -alias", errUsage)
+	}
+
+	env, err := connect(*configPath)
+	if err != nil {
+		return err
+	}
+	defer env.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	// после удаления ссылки её рабочее пространство не узнать, а событие получают только его подписчики.
+	if ws, err := env.db.LinkWorkspace(ctx, *alias); err == nil {
+		ctx = workspace.WithID(ctx, ws)
+	}
+
+	// кэш чистим даже если в базе ссылки уже нет: в нём могла остаться устаревшая запись.
+	dbErr := env.db.Delete(ctx, *alias)
+	if err = env.rdb.Purge(ctx, *alias); err != nil {
+		return err
+	}
+	if dbErr != nil {
+		return dbErr
+	}
+	env.events.Publish(ctx, webhooks.Event{Type: webhooks.EventLinkDeleted, Alias: *alias})
+
+	fmt.Println("deleted", *alias)
+	return nil
+}
+
+// listCmd выводит список ссылок.
+// Использование: list -config=config/conf.yaml [-tag=promo] [-owner=growth] [-campaign=spring] [-q=example.com] [-limit=50] [-cursor=...]
+func listCmd(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file, $SHORTENER_CONFIG_PATH by default")
+	tag := fs.String("tag", "", "filter by tag")
+	owner := fs.String("owner", "", "filter by owner")
+	campaign := fs.String("campaign", "", "filter by campaign")
+	query := fs.String("q", "", "filter by original url substring")
+	limit := fs.Int("limit", links.DefaultLimit, "page size")
+	cursor := fs.String("cursor", "", "cursor of the next page")
+	_ = fs.Parse(args)
+
+	f, err := links.Filter{Tag: *tag, Owner: *owner, Campaign: *campaign, Query: *query, Limit: *limit}.Normalize()
+	if err != nil {
+		return err
+	}
+	if *cursor != "" {
+		if f.Cursor, err = links.DecodeCursor(*cursor); err != nil {
+			return err
+		}
+	}
+
+	env, err := connect(*configPath)
+	if err != nil {
+		return err
+	}
+	defer env.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	page, next, err := env.db.ListLinks(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ALIAS\tORIGINAL\tCREATED\tOWNER\tTAGS")
+	for _, l := range page {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", l.Alias, l.Original, l.CreatedAt.Format(time.RFC3339), l.Owner, strings.Join(l.Tags, ","))
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+
+	if next != nil {
+		fmt.Println("next cursor:", next.Encode())
+	}
+	return nil
+}
+
+// statsCmd выводит статистику переходов по псевдониму в формате JSON, как и API, вместе с ещё не перенесёнными из кэша переходами.
+// Использование: stats -config=config/conf.yaml -alias=promo
+func statsCmd(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file, $SHORTENER_CONFIG_PATH by default")
+	alias := fs.String("alias", "", "alias")
+	_ = fs.Parse(args)
+
+	if *alias == "" {
+		return fmt.Errorf("%w: -alias", errUsage)
+	}
+
+	env, err := connect(*configPath)
+	if err != nil {
+		return err
+	}
+	defer env.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	stats, err := env.svc.Stats(ctx, *alias)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(stats)
+}
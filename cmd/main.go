@@ -1,17 +1,30 @@
 package main
 
 import (
+	"Darkyfun/UrlShortener/api"
+	"Darkyfun/UrlShortener/internal/clicks"
 	"Darkyfun/UrlShortener/internal/config"
+	"Darkyfun/UrlShortener/internal/lifecycle"
 	"Darkyfun/UrlShortener/internal/logging"
 	"Darkyfun/UrlShortener/internal/logging/logpath"
+	"Darkyfun/UrlShortener/internal/outbox"
+	"Darkyfun/UrlShortener/internal/ratelimit"
+	"Darkyfun/UrlShortener/internal/rollup"
+	"Darkyfun/UrlShortener/internal/server/grpcapi"
 	"Darkyfun/UrlShortener/internal/server/middleware"
+	"Darkyfun/UrlShortener/internal/service"
 	"Darkyfun/UrlShortener/internal/storage/cache"
 	"Darkyfun/UrlShortener/internal/storage/persistent"
+	"Darkyfun/UrlShortener/internal/warmup"
+	"Darkyfun/UrlShortener/internal/webhooks"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -19,91 +32,244 @@ import (
 	"time"
 )
 
+// commands - это подкоманды, которые выполняются вместо запуска сервера.
+var commands = map[string]func(args []string) error{
+	"export":  exportCmd,
+	"import":  importCmd,
+	"create":  createCmd,
+	"resolve": resolveCmd,
+	"delete":  deleteCmd,
+	"list":    listCmd,
+	"stats":   statsCmd,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
 	fmt.Println("Starting service")
 
-	configPath := flag.String("config", "", "logpath for config file")
+	configPath := flag.String("config", "", "path to config file, $SHORTENER_CONFIG_PATH by default")
 	flag.Parse()
 
 	// парсим файл конфигурации.
-	conf, err := config.GetConfig(*configPath)
+	conf, err := config.Load(*configPath)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	// инициализируем логеры.
 	fmt.Println("Reading config")
-	logPaths := logpath.DestinationLog("./logs")
+	logPaths, err := logpath.DestinationLog(logOptions(conf.Log))
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	fmt.Println("Setting destination for logs")
 
-	defer func() {
-		if err := logPaths.CloseFiles(); err != nil {
-			fmt.Println(err)
-		}
-	}()
+	logLevel, _ := logging.ParseLevel(conf.Runtime.LogLevel)
+	baseLogger := logging.NewLeveledLogger(conf.Log.OutputType, logPaths.ErrorLog, logLevel)
 
-	baseLogger := logging.NewLogger(conf.GetString("OutputType"), logPaths.ErrorLog)
+	// lc останавливает сервис по шагам, которые регистрируются ниже по мере запуска компонентов.
+	lc := lifecycle.New(baseLogger)
+
+	// по SIGUSR1 заново открываем файлы логов, чтобы работать с внешним logrotate.
+	lc.Go("log reopen", func(ctx context.Context) {
+		onSignal(ctx, syscall.SIGUSR1, func() {
+			if err := logPaths.Reopen(); err != nil {
+				fmt.Println(err)
+				return
+			}
+			baseLogger.Log(ctx, "info", "log files reopened")
+		})
+	})
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
 	defer cancel()
 
-	cacheOpts := cache.Opts{
-		Addr:       conf.GetString("CacheAddr"),
-		User:       conf.GetString("CacheUser"),
-		Password:   conf.GetString("CachePass"),
-		MaxRetries: conf.GetInt("MaxRetries"),
-		PoolSize:   conf.GetInt("PoolSize"),
-	}
-
 	// подключаемся к кэшу.
-	rdb := cache.NewCacheDb(cacheOpts, baseLogger)
-	defer func() {
-		err = rdb.Close()
-		if err != nil {
-			baseLogger.Log("error", "can not close the connection to Cache Db: "+err.Error())
-		}
-	}()
+	rdb := cache.NewCacheDb(cacheOpts(conf.Cache), baseLogger)
 	fmt.Println("Connected to cache database")
 
 	// подключаемся в SQL-базе данных.
-	db := persistent.NewDb(ctx, baseLogger, conf.GetString("SqlConnString"))
-	defer db.Close()
+	db := persistent.Connect(ctx, baseLogger, storageOpts(conf.Storage))
 	fmt.Println("Connected to persistence database")
 
-	// бесконечный healthcheck к кэшу.
-	go cache.PingCache(rdb, baseLogger)
+	// healthcheck к кэшу и SQL-базе данных.
+	lc.Go("cache healthcheck", func(ctx context.Context) { cache.PingCache(ctx, rdb, baseLogger) })
+	lc.Go("storage healthcheck", func(ctx context.Context) { persistent.PingStorage(ctx, &db, baseLogger) })
+
+	// прогрев кэша идёт в фоне, чтобы не задерживать запуск сервера.
+	if conf.Cache.Warmup != "" {
+		lc.Go("cache warm-up", func(ctx context.Context) {
+			_, _ = warmup.Run(ctx, warmupOpts(conf.Cache), &db, rdb, baseLogger)
+		})
+	}
+
+	// асинхронная запись переходов по ссылкам.
+	var recorder service.ClickRecorder = clicks.Discard{}
+	if conf.Clicks.Events {
+		r := clicks.NewRecorder(&db, baseLogger, conf.Clicks.QueueSize)
+		lc.Go("click recorder", r.Run)
+		recorder = r
+	}
+
+	// события жизненного цикла ссылок доставляются подписчикам в фоне.
+	dispatcher := webhooks.NewDispatcher(&db, baseLogger, webhooks.Options(conf.Webhooks))
+	lc.Go("webhook delivery", dispatcher.Run)
+
+	// счётчики переходов копятся в кэше и периодически переносятся в SQL-базу данных.
+	flusher := clicks.NewFlusher(rdb, &db, baseLogger, conf.Clicks.CountFlushInterval, conf.Clicks.CountFlushBatch)
+	flusher.SetNotifier(dispatcher)
+	lc.Go("click counter flush", flusher.Run)
 
-	// бесконечный healthcheck к SQL-базе данных.
-	go persistent.PingStorage(&db, baseLogger)
+	// записанные переходы сворачиваются по часам, из свёртки строится статистика по интервалам.
+	aggregator := rollup.NewAggregator(&db, baseLogger, conf.Clicks.RollupInterval, conf.Clicks.RollupBatch)
+	lc.Go("click rollup", aggregator.Run)
+
+	// события об изменении ссылок записываются в таблицу outbox вместе с изменением и ретранслируются в фоне.
+	publisher, err := outboxPublisher(conf.Outbox)
+	if err != nil {
+		log.Fatal(err)
+	}
+	relay := outbox.NewRelay(&db, publisher, baseLogger, conf.Outbox.Interval, conf.Outbox.Batch)
+	lc.Go("outbox relay", relay.Run)
 
 	// инициализируем gin.
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
 
-	middleLogger := middleware.NewLogHandler(logPaths.IncomeLog)
+	middleLogger := middleware.NewLogHandler(logPaths.IncomeLog, logLevel)
+	limiter := ratelimit.NewLimiter(conf.Runtime.RateLimit, conf.Runtime.RateBurst)
+	router.Use(middleware.RequestID())
 	router.Use(middleLogger.Logger())
 	router.Use(gin.Recovery())
+	router.Use(middleware.RateLimit(limiter))
+
+	shortener := service.NewShortener(rdb, &db, baseLogger, recorder, conf.Server.Addr)
+	shortener.SetBlocklist(conf.Runtime.Blocklist)
+	shortener.SetDeadlines(service.Deadlines(conf.Deadlines))
+	shortener.SetTTLPolicy(ttlPolicy(conf.Cache))
+	shortener.SetPublisher(dispatcher)
+
+	// собственные домены загружаются при запуске и периодически перечитываются,
+	// чтобы подхватить домены, зарегистрированные через другие экземпляры сервиса.
+	refreshDomains(ctx, &db, shortener, baseLogger)
+	lc.Go("domain refresh", func(ctx context.Context) {
+		ticker := time.NewTicker(conf.Server.DomainRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				refreshDomains(ctx, &db, shortener, baseLogger)
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
 
-	router.GET("/redirect/:alias", middleware.Redirect(rdb, &db, baseLogger))
-	router.POST("/receive", middleware.Validate(), middleware.Saver(rdb, &db, conf.GetString("ServerAddr")))
+	// горячая перезагрузка настроек: при изменении файла конфигурации и по SIGHUP.
+	reloader := config.NewReloader(config.Path(*configPath), conf.Runtime, baseLogger, func(r config.Runtime) {
+		level, _ := logging.ParseLevel(r.LogLevel)
+		logLevel.SetLevel(level.Level())
+		limiter.SetLimit(r.RateLimit, r.RateBurst)
+		shortener.SetBlocklist(r.Blocklist)
+	})
+	reloader.Watch()
+	lc.Go("config reload", func(ctx context.Context) {
+		onSignal(ctx, syscall.SIGHUP, func() { _ = reloader.Reload() })
+	})
+
+	redirectOpts := func() middleware.RedirectOptions {
+		r := reloader.Current()
+		return middleware.RedirectOptions{Status: r.RedirectStatus, StickyMaxAge: r.StickyMaxAge, CountryHeader: conf.Clicks.CountryHeader}
+	}
+
+	router.GET("/redirect/:alias", middleware.Redirect(shortener, redirectOpts))
+
+	// запросы к API ограничены рабочим пространством ключа API, перенаправления открыты всем.
+	scoped := router.Group("", middleware.Authenticate(&db, conf.Server.RequireAPIKey))
+	scoped.POST("/receive", middleware.Validate(), middleware.Saver(shortener))
+	scoped.GET("/api/links", middleware.ListLinks(&db, conf.Deadlines.StoreRead))
+	scoped.GET("/api/links/:alias/stats", middleware.LinkStats(&db, rdb, conf.Deadlines.StoreRead))
+	scoped.PUT("/api/links/:alias/meta", middleware.UpdateLinkMeta(shortener))
+	scoped.DELETE("/api/links/:alias", middleware.DeleteLink(shortener))
+	scoped.GET("/api/campaigns/:name/links", middleware.CampaignLinks(&db, conf.Deadlines.StoreRead))
+	scoped.GET("/api/campaigns/:name/stats", middleware.CampaignStats(&db, rdb, conf.Deadlines.StoreRead))
+	scoped.POST("/api/webhooks", middleware.CreateHook(&db, conf.Webhooks.AllowHTTP))
+	scoped.GET("/api/webhooks", middleware.ListHooks(&db))
+	scoped.DELETE("/api/webhooks/:id", middleware.DeleteHook(&db))
+	scoped.GET("/api/webhooks/:id/deliveries", middleware.HookDeliveries(&db))
+	scoped.POST("/api/domains", middleware.CreateDomain(&db, shortener))
+	scoped.GET("/api/domains", middleware.ListDomains(&db))
+	scoped.DELETE("/api/domains/:host", middleware.DeleteDomain(&db, shortener))
+
+	if conf.Server.AdminToken != "" {
+		admin := router.Group("/admin", middleware.AdminAuth(conf.Server.AdminToken))
+		admin.POST("/workspaces", middleware.CreateWorkspace(&db))
+		admin.GET("/workspaces", middleware.ListWorkspaces(&db))
+		admin.POST("/workspaces/:id/members", middleware.AddMember(&db))
+	}
+	router.GET("/openapi.json", middleware.OpenAPI(api.OpenAPI))
 
 	server := &http.Server{
-		Addr:         conf.GetString("ServerAddr"),
+		Addr:         conf.Server.Addr,
 		Handler:      router,
-		ReadTimeout:  conf.GetDuration("ReadTimeout") * time.Second,
-		WriteTimeout: conf.GetDuration("WriteTimeout") * time.Second,
-		IdleTimeout:  conf.GetDuration("IdleTimeout") * time.Second,
+		ReadTimeout:  conf.Server.ReadTimeout,
+		WriteTimeout: conf.Server.WriteTimeout,
+		IdleTimeout:  conf.Server.IdleTimeout,
 	}
 
 	// запускаем сервер.
 	fmt.Println("Starting server")
-	go func() { baseLogger.Log("info", server.ListenAndServe().Error()) }()
+	go func() {
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("can not serve http: %v\n", err)
+		}
+	}()
 
 	fmt.Println("Server has been started")
 
+	// запускаем gRPC-сервер.
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(grpcapi.RequestIDInterceptor,
+		grpcapi.AuthInterceptor(&db, conf.Server.RequireAPIKey)))
+	grpcapi.Register(grpcServer, shortener)
+	if addr := conf.Server.GrpcAddr; addr != "" {
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			log.Fatalf("can not listen gRPC address: %v\n", err)
+		}
+		go func() {
+			if err := grpcServer.Serve(lis); err != nil {
+				baseLogger.Log(context.Background(), "info", err.Error())
+			}
+		}()
+		fmt.Println("gRPC server has been started")
+	}
+
+	// порядок остановки: перестаём принимать запросы и дожидаемся текущих, останавливаем фоновые задачи
+	// (запись переходов дописывает очередь), закрываем кэш и SQL-базу данных, закрываем логи.
+	lc.OnShutdown("http server", server.Shutdown)
+	lc.OnShutdown("grpc server", func(ctx context.Context) error {
+		return gracefulStop(ctx, grpcServer)
+	})
+	lc.OnShutdown("background workers", lc.StopWorkers)
+	lc.OnShutdown("outbox publisher", func(context.Context) error { return publisher.Close() })
+	lc.OnShutdown("cache", func(context.Context) error { return rdb.Close() })
+	lc.OnShutdown("storage", func(context.Context) error {
+		db.Close()
+		return nil
+	})
+	lc.OnShutdown("logs", func(context.Context) error { return logPaths.CloseFiles() })
+
 	// graceful shutdown.
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	signal.Notify(
 		quit,
 		syscall.SIGINT,
@@ -112,17 +278,144 @@ func main() {
 	<-quit
 
 	fmt.Println("Shutting down the server")
-	serveCtx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	if err := lc.Shutdown(conf.Server.ShutdownGrace); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Println("Done")
+}
+
+// onSignal вызывает fn на каждый сигнал sig, пока не отменён ctx.
+func onSignal(ctx context.Context, sig os.Signal, fn func()) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sig)
+	defer signal.Stop(ch)
 
-	if err = server.Shutdown(serveCtx); err != nil {
-		log.Fatalf("Shutting down: %v\n", err)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ch:
+			fn()
+		}
 	}
+}
+
+// gracefulStop останавливает gRPC-сервер, дожидаясь текущих вызовов, но не дольше ctx.
+func gracefulStop(ctx context.Context, s *grpc.Server) error {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
 
 	select {
-	case <-serveCtx.Done():
-		baseLogger.Log("warn", "Server shutdown by timeout")
-	default:
-		fmt.Println("Done")
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.Stop()
+		return ctx.Err()
+	}
+}
+
+// logOptions возвращает настройки файлов логов из конфигурации.
+func logOptions(conf config.Log) logpath.Options {
+	return logpath.Options{
+		Dir:        conf.Dir,
+		DirMode:    conf.DirMode,
+		FileMode:   conf.FileMode,
+		MaxSize:    conf.MaxSizeMB << 20,
+		MaxAge:     conf.MaxAge,
+		MaxBackups: conf.MaxBackups,
+		Compress:   conf.Compress,
+	}
+}
+
+// storageOpts возвращает опции подключения к SQL-базе данных из конфигурации.
+func storageOpts(conf config.Storage) persistent.Opts {
+	return persistent.Opts{
+		Primary:  conf.ConnString,
+		Replicas: conf.Replicas,
+		Pool: persistent.PoolOpts{
+			MaxConns:          conf.MaxConns,
+			MinConns:          conf.MinConns,
+			MaxConnLifetime:   conf.MaxConnLifetime,
+			MaxConnIdleTime:   conf.MaxConnIdleTime,
+			HealthCheckPeriod: conf.HealthCheckPeriod,
+		},
+	}
+}
+
+// cacheOpts возвращает опции подключения к кэшу из конфигурации.
+func cacheOpts(conf config.Cache) cache.Opts {
+	return cache.Opts{
+		Addr:       conf.Addr,
+		User:       conf.User,
+		Password:   conf.Password,
+		MaxRetries: conf.MaxRetries,
+		PoolSize:   conf.PoolSize,
+
+		Mode:             conf.Mode,
+		Addrs:            conf.Addrs,
+		MasterName:       conf.MasterName,
+		SentinelUser:     conf.SentinelUser,
+		SentinelPassword: conf.SentinelPassword,
+
+		TLS: cache.TLSOpts{
+			Enabled:            conf.TLS,
+			CAFile:             conf.TLSCAFile,
+			CertFile:           conf.TLSCertFile,
+			KeyFile:            conf.TLSKeyFile,
+			ServerName:         conf.TLSServerName,
+			InsecureSkipVerify: conf.TLSInsecure,
+		},
+	}
+}
+
+// ttlPolicy возвращает правила выбора времени жизни записей кэша из конфигурации.
+func ttlPolicy(conf config.Cache) cache.TTLPolicy {
+	return cache.TTLPolicy{
+		Base:       conf.TTL,
+		Sliding:    conf.Sliding,
+		PopularTTL: conf.PopularTTL,
+		Threshold:  conf.PopularThreshold,
+		Window:     conf.PopularWindow,
+	}
+}
+
+// warmupOpts возвращает настройки прогрева кэша из конфигурации.
+func warmupOpts(conf config.Cache) warmup.Options {
+	return warmup.Options{
+		Order: conf.Warmup,
+		Limit: conf.WarmupLimit,
+		Batch: conf.WarmupBatch,
+		TTL:   ttlPolicy(conf),
+	}
+}
+
+// outboxPublisher возвращает Publisher для ретрансляции событий из таблицы outbox.
+func outboxPublisher(conf config.Outbox) (outbox.Publisher, error) {
+	switch conf.Publisher {
+	case "none":
+		return outbox.Discard{}, nil
+	case "stdout":
+		return outbox.NewWriter(os.Stdout), nil
+	case "file":
+		return outbox.NewFileWriter(conf.File)
+	case "nats":
+		return outbox.NewNATS(conf.NatsURL, conf.NatsSubject, conf.Timeout)
+	}
+	return nil, fmt.Errorf("%w: %q", outbox.ErrUnknownPublisher, conf.Publisher)
+}
+
+// refreshDomains загружает в shortener список собственных доменов. При ошибке остаётся прежний список.
+func refreshDomains(ctx context.Context, db *persistent.Db, shortener *service.Shortener, logger *logging.EventLogger) {
+	domains, err := db.ListDomains(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Log(ctx, "error", "unable to load custom domains", logging.Err(err))
+		}
+		return
 	}
+	shortener.SetDomains(domains)
 }
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"Darkyfun/UrlShortener/internal/config"
+	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/storage/cache"
+	"Darkyfun/UrlShortener/internal/storage/persistent"
+	"Darkyfun/UrlShortener/internal/transfer"
+	"Darkyfun/UrlShortener/internal/webhooks"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// exportCmd выгружает таблицу url в CSV или JSONL.
+// Использование: export -config=config/conf.yaml -format=csv -file=links.csv
+func exportCmd(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file, $SHORTENER_CONFIG_PATH by default")
+	format := fs.String("format", transfer.FormatCSV, "output format: csv or jsonl")
+	file := fs.String("file", "", "output file, stdout by default")
+	_ = fs.Parse(args)
+
+	conf, err := config.Load(*configPath)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if *file != "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	w, err := transfer.NewWriter(*format, out)
+	if err != nil {
+		return err
+	}
+
+	logger := logging.NewLogger(conf.Log.OutputType, os.Stderr)
+	db := persistent.Connect(context.Background(), logger, storageOpts(conf.Storage))
+	defer db.Close()
+
+	count := 0
+	err = db.ExportLinks(context.Background(), func(r transfer.Record) error {
+		count++
+		return w.Write(r)
+	})
+	if err != nil {
+		return err
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d links\n", count)
+	return nil
+}
+
+// importCmd загружает ссылки из CSV или JSONL в таблицу url.
+// Использование: import -config=config/conf.yaml -format=jsonl -file=links.jsonl -on-conflict=skip
+func importCmd(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file, $SHORTENER_CONFIG_PATH by default")
+	format := fs.String("format", transfer.FormatCSV, "input format: csv or jsonl")
+	file := fs.String("file", "", "input file, stdin by default")
+	policy := fs.String("on-conflict", transfer.PolicyFail, "what to do with existing aliases: skip, overwrite or fail")
+	_ = fs.Parse(args)
+
+	if err := transfer.ValidPolicy(*policy); err != nil {
+		return err
+	}
+
+	conf, err := config.Load(*configPath)
+	if err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	r, err := transfer.NewReader(*format, in)
+	if err != nil {
+		return err
+	}
+
+	logger := logging.NewLogger(conf.Log.OutputType, os.Stderr)
+	db := persistent.Connect(context.Background(), logger, storageOpts(conf.Storage))
+	defer db.Close()
+
+	created, overwritten, err := db.ImportLinks(context.Background(), r, *policy)
+	if err != nil {
+		return err
+	}
+	count := len(created) + len(overwritten)
+
+	// перезаписанные ссылки убираем из кэша, иначе по ним продолжат перенаправлять на старые адреса.
+	if len(overwritten) > 0 {
+		rdb := cache.NewCacheDb(cacheOpts(conf.Cache), logger)
+		defer rdb.Close()
+
+		for _, alias := range overwritten {
+			if err = rdb.Purge(context.Background(), alias); err != nil {
+				return fmt.Errorf("imported %d links, but failed to purge %s from cache: %w", count, alias, err)
+			}
+		}
+	}
+
+	events := webhooks.NewDispatcher(&db, logger, webhooks.Options(conf.Webhooks))
+	for _, alias := range created {
+		events.Publish(context.Background(), webhooks.Event{Type: webhooks.EventLinkCreated, Alias: alias})
+	}
+	for _, alias := range overwritten {
+		events.Publish(context.Background(), webhooks.Event{Type: webhooks.EventLinkUpdated, Alias: alias})
+	}
+
+	fmt.Fprintf(os.Stderr, "imported %d links, %d of them overwritten\n", count, len(overwritten))
+	return nil
+}
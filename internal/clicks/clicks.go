@@ -0,0 +1,122 @@
+// Package clicks содержит асинхронную запись переходов по коротким ссылкам.
+package clicks
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"context"
+	"sync"
+	"time"
+)
+
+const writeTimeout = time.Second * 3
+
+// Click - это один переход по короткой ссылке.
+type Click struct {
+	Alias       string
+	Variant     string
+	Destination string
+	Referrer    string
+	UserAgent   string
+	// Country - код страны клиента, если его определил прокси или CDN перед сервисом.
+	Country string
+	Time    time.Time
+}
+
+// Stats - это сводная статистика переходов по псевдониму.
+// Total и остальные поля считаются по записанным переходам, Count - по счётчику, который ведётся и без их записи.
+type Stats struct {
+	Alias    string           `json:"alias"`
+	Total    int64            `json:"total"`
+	Count    int64            `json:"count"`
+	Variants map[string]int64 `json:"variants,omitempty"`
+	First    time.Time        `json:"first,omitempty"`
+	Last     time.Time        `json:"last,omitempty"`
+}
+
+type Saver interface {
+	SaveClick(ctx context.Context, click Click) error
+}
+
+type Logger interface {
+	Log(ctx context.Context, level string, msg string, fields ...logging.Field)
+}
+
+// Discard - это получатель переходов, который их не записывает. Используется, когда запись переходов отключена.
+type Discard struct{}
+
+func (Discard) Record(context.Context, Click) {}
+
+// queued - это переход, ожидающий записи, вместе с идентификатором запроса, в котором он произошёл.
+type queued struct {
+	click     Click
+	requestID string
+}
+
+// Recorder - это структура, которая принимает переходы и записывает их в фоне, не задерживая ответ клиенту.
+type Recorder struct {
+	queue chan queued
+	store Saver
+	log   Logger
+
+	// mu защищает queue от записи после закрытия.
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewRecorder возвращает Recorder с очередью указанного размера.
+func NewRecorder(store Saver, log Logger, size int) *Recorder {
+	return &Recorder{
+		queue: make(chan queued, size),
+		store: store,
+		log:   log,
+	}
+}
+
+// Record ставит переход в очередь на запись. Если очередь переполнена или Run уже остановлен, переход отбрасывается.
+// Идентификатор запроса из ctx сохраняется, чтобы ошибки записи можно было сопоставить с запросом.
+func (r *Recorder) Record(ctx context.Context, click Click) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		r.log.Log(ctx, "warn", "click recorder is stopped, click dropped", logging.String("alias", click.Alias))
+		return
+	}
+
+	select {
+	case r.queue <- queued{click: click, requestID: logging.RequestID(ctx)}:
+	default:
+		r.log.Log(ctx, "warn", "click queue is full, click dropped", logging.String("alias", click.Alias))
+	}
+}
+
+// Run записывает переходы из очереди в хранилище, пока не отменён ctx.
+// После отмены новые переходы больше не принимаются, а уже поставленные в очередь дописываются.
+func (r *Recorder) Run(ctx context.Context) {
+	for {
+		select {
+		case q := <-r.queue:
+			r.save(q)
+		case <-ctx.Done():
+			r.mu.Lock()
+			r.closed = true
+			close(r.queue)
+			r.mu.Unlock()
+
+			for q := range r.queue {
+				r.save(q)
+			}
+			return
+		}
+	}
+}
+
+// save записывает один переход.
+func (r *Recorder) save(q queued) {
+	ctx, cancel := context.WithTimeout(logging.WithRequestID(context.Background(), q.requestID), writeTimeout)
+	defer cancel()
+
+	if err := r.store.SaveClick(ctx, q.click); err != nil {
+		r.log.Log(ctx, "error", "unable to save click", logging.String("alias", q.click.Alias), logging.Err(err))
+	}
+}
@@ -0,0 +1,67 @@
+package clicks
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memorySaver хранит переходы в памяти.
+type memorySaver struct {
+	mu     sync.Mutex
+	clicks []Click
+}
+
+func (m *memorySaver) SaveClick(_ context.Context, click Click) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clicks = append(m.clicks, click)
+	return nil
+}
+
+func (m *memorySaver) len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.clicks)
+}
+
+func TestRecorder(t *testing.T) {
+	store := &memorySaver{}
+	rec := NewRecorder(store, logging.NewLogger("json", io.Discard), 2)
+
+	// очередь переполнена, третий переход отбрасывается.
+	rec.Record(context.Background(), Click{Alias: "first", Variant: "A"})
+	rec.Record(context.Background(), Click{Alias: "second", Variant: "B"})
+	rec.Record(context.Background(), Click{Alias: "dropped"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go rec.Run(ctx)
+
+	assert.Eventually(t, func() bool { return store.len() == 2 }, time.Second, time.Millisecond*10)
+	assert.Equal(t, "A", store.clicks[0].Variant)
+	assert.Equal(t, "B", store.clicks[1].Variant)
+}
+
+func TestRecorder_Drain(t *testing.T) {
+	store := &memorySaver{}
+	rec := NewRecorder(store, logging.NewLogger("json", io.Discard), 10)
+
+	for i := 0; i < 5; i++ {
+		rec.Record(context.Background(), Click{Alias: "queued"})
+	}
+
+	// отменённый Run дописывает очередь и только потом возвращается.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec.Run(ctx)
+	assert.Equal(t, 5, store.len())
+
+	// после остановки переходы отбрасываются.
+	rec.Record(context.Background(), Click{Alias: "late"})
+	assert.Equal(t, 5, store.len())
+}
@@ -0,0 +1,87 @@
+package clicks
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"context"
+	"time"
+)
+
+// CountBuffer - это кэш, в котором копятся счётчики переходов до переноса в хранилище.
+type CountBuffer interface {
+	TakeClickCounts(ctx context.Context, n int) (map[string]int64, error)
+	RestoreClickCounts(ctx context.Context, counts map[string]int64) error
+}
+
+// CountSaver - это хранилище, к счётчикам которого прибавляются накопленные переходы.
+type CountSaver interface {
+	AddClickCounts(ctx context.Context, counts map[string]int64) (map[string]int64, error)
+}
+
+// CountNotifier получает прибавленные переходы counts и итоговые счётчики totals после каждого переноса.
+type CountNotifier interface {
+	ClicksCounted(ctx context.Context, counts, totals map[string]int64)
+}
+
+// Flusher - это структура, которая периодически переносит счётчики переходов из кэша в хранилище.
+type Flusher struct {
+	buf      CountBuffer
+	store    CountSaver
+	log      Logger
+	interval time.Duration
+	batch    int
+	notifier CountNotifier
+}
+
+// NewFlusher возвращает Flusher, переносящий счётчики раз в interval пакетами по batch псевдонимов.
+func NewFlusher(buf CountBuffer, store CountSaver, log Logger, interval time.Duration, batch int) *Flusher {
+	return &Flusher{buf: buf, store: store, log: log, interval: interval, batch: batch}
+}
+
+// SetNotifier задаёт получателя итоговых счётчиков. Метод нужно вызывать до Run.
+func (f *Flusher) SetNotifier(n CountNotifier) {
+	f.notifier = n
+}
+
+// Run переносит счётчики раз в интервал, пока не отменён ctx. После отмены счётчики переносятся последний раз.
+func (f *Flusher) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = f.Flush(ctx)
+		case <-ctx.Done():
+			ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+			_ = f.Flush(ctx)
+			cancel()
+			return
+		}
+	}
+}
+
+// Flush переносит все накопленные счётчики. Счётчики, которые не удалось сохранить, возвращаются в кэш.
+func (f *Flusher) Flush(ctx context.Context) error {
+	for {
+		counts, err := f.buf.TakeClickCounts(ctx, f.batch)
+		if err != nil {
+			f.log.Log(ctx, "error", "unable to take click counts", logging.Err(err))
+			return err
+		}
+		if len(counts) == 0 {
+			return nil
+		}
+
+		totals, err := f.store.AddClickCounts(ctx, counts)
+		if err != nil {
+			f.log.Log(ctx, "error", "unable to save click counts", logging.Int("count", len(counts)), logging.Err(err))
+			if err := f.buf.RestoreClickCounts(ctx, counts); err != nil {
+				f.log.Log(ctx, "error", "click counts lost", logging.Int("count", len(counts)), logging.Err(err))
+			}
+			return err
+		}
+		if f.notifier != nil {
+			f.notifier.ClicksCounted(ctx, counts, totals)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package clicks
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+// memoryCounts - это кэш счётчиков в памяти, отдающий их по одному псевдониму.
+type memoryCounts struct {
+	counts map[string]int64
+}
+
+func (m *memoryCounts) TakeClickCounts(_ context.Context, n int) (map[string]int64, error) {
+	taken := make(map[string]int64)
+	for alias, c := range m.counts {
+		if len(taken) == n {
+			break
+		}
+		taken[alias] = c
+		delete(m.counts, alias)
+	}
+	return taken, nil
+}
+
+func (m *memoryCounts) RestoreClickCounts(_ context.Context, counts map[string]int64) error {
+	for alias, c := range counts {
+		m.counts[alias] += c
+	}
+	return nil
+}
+
+// countTotals - это хранилище счётчиков в памяти.
+type countTotals struct {
+	totals map[string]int64
+	err    error
+}
+
+func (c *countTotals) AddClickCounts(_ context.Context, counts map[string]int64) (map[string]int64, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	totals := make(map[string]int64)
+	for alias, n := range counts {
+		c.totals[alias] += n
+		totals[alias] = c.totals[alias]
+	}
+	return totals, nil
+}
+
+// notified запоминает итоговые счётчики, переданные после переноса.
+type notified map[string]int64
+
+func (n notified) ClicksCounted(_ context.Context, _, totals map[string]int64) {
+	for alias, total := range totals {
+		n[alias] = total
+	}
+}
+
+func TestFlusher(t *testing.T) {
+	buf := &memoryCounts{counts: map[string]int64{"a": 3, "b": 1, "c": 7}}
+	store := &countTotals{totals: map[string]int64{"a": 10}}
+	totals := notified{}
+	f := NewFlusher(buf, store, logging.NewLogger("json", io.Discard), time.Hour, 1)
+	f.SetNotifier(totals)
+
+	assert.Nil(t, f.Flush(context.Background()))
+	assert.Equal(t, map[string]int64{"a": 13, "b": 1, "c": 7}, store.totals)
+	assert.Equal(t, notified{"a": 13, "b": 1, "c": 7}, totals)
+	assert.Empty(t, buf.counts)
+
+	// несохранённые счётчики возвращаются в кэш.
+	buf.counts["a"] = 2
+	store.err = errors.New("postgres is down")
+	assert.ErrorIs(t, f.Flush(context.Background()), store.err)
+	assert.Equal(t, map[string]int64{"a": 2}, buf.counts)
+
+	// при остановке счётчики переносятся последний раз.
+	store.err = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	f.Run(ctx)
+	assert.Equal(t, int64(15), store.totals["a"])
+}
@@ -3,42 +3,501 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 )
 
-var ErrEnvVarNotFound = errors.New("you need to set up environment variable first.\nThat variable should contain 'conf.yaml' file")
 var ErrFileNotFound = errors.New("config file is missing")
+var ErrInvalidConfig = errors.New("invalid config")
 
-// GetConfig парсит файл конфигурации.
-func GetConfig(path string) (*viper.Viper, error) {
+// EnvPrefix - это префикс переменных окружения, переопределяющих настройки.
+// Например, ServerAddr переопределяется переменной SHORTENER_SERVER_ADDR.
+const EnvPrefix = "SHORTENER"
+
+// PathEnv - это переменная окружения с путём к файлу конфигурации, если он не передан явно.
+const PathEnv = "SHORTENER_CONFIG_PATH"
+
+// Config - это проверенная конфигурация сервиса.
+type Config struct {
+	Log       Log       `mapstructure:",squash"`
+	Server    Server    `mapstructure:",squash"`
+	Cache     Cache     `mapstructure:",squash"`
+	Storage   Storage   `mapstructure:",squash"`
+	Clicks    Clicks    `mapstructure:",squash"`
+	Deadlines Deadlines `mapstructure:",squash"`
+	Webhooks  Webhooks  `mapstructure:",squash"`
+	Outbox    Outbox    `mapstructure:",squash"`
+	Runtime   Runtime   `mapstructure:",squash"`
+}
+
+// Log - это настройки файлов логов.
+type Log struct {
+	OutputType string        `mapstructure:"OutputType"`
+	Dir        string        `mapstructure:"LogDir"`
+	DirMode    os.FileMode   `mapstructure:"LogDirMode"`
+	FileMode   os.FileMode   `mapstructure:"LogFileMode"`
+	MaxSizeMB  int64         `mapstructure:"LogMaxSizeMB"`
+	MaxAge     time.Duration `mapstructure:"LogMaxAge"`
+	MaxBackups int           `mapstructure:"LogMaxBackups"`
+	Compress   bool          `mapstructure:"LogCompress"`
+}
+
+// Server - это настройки HTTP- и gRPC-серверов.
+type Server struct {
+	Addr         string        `mapstructure:"ServerAddr"`
+	ReadTimeout  time.Duration `mapstructure:"ReadTimeout"`
+	WriteTimeout time.Duration `mapstructure:"WriteTimeout"`
+	IdleTimeout  time.Duration `mapstructure:"IdleTimeout"`
+	GrpcAddr     string        `mapstructure:"GrpcAddr"`
+
+	// ShutdownGrace - сколько ждать завершения запросов и фоновых задач при остановке.
+	ShutdownGrace time.Duration `mapstructure:"ShutdownGrace"`
+	// DomainRefresh - как часто перечитывается список собственных доменов, зарегистрированных другими экземплярами сервиса.
+	DomainRefresh time.Duration `mapstructure:"DomainRefreshInterval"`
+	// RequireAPIKey запрещает запросы к API без ключа, иначе они попадают в рабочее пространство по умолчанию.
+	RequireAPIKey bool `mapstructure:"RequireAPIKey"`
+	// AdminToken открывает API администратора /admin, пустой токен отключает его.
+	AdminToken string `mapstructure:"AdminToken"`
+}
+
+// Cache - это настройки подключения к Redis.
+type Cache struct {
+	Addr       string `mapstructure:"CacheAddr"`
+	User       string `mapstructure:"CacheUser"`
+	Password   string `mapstructure:"CachePass"`
+	MaxRetries int    `mapstructure:"MaxRetries"`
+	PoolSize   int    `mapstructure:"PoolSize"`
+
+	// Mode - standalone, sentinel или cluster. Addrs - адреса sentinel-узлов или узлов кластера.
+	Mode             string   `mapstructure:"CacheMode"`
+	Addrs            []string `mapstructure:"CacheAddrs"`
+	MasterName       string   `mapstructure:"CacheMasterName"`
+	SentinelUser     string   `mapstructure:"CacheSentinelUser"`
+	SentinelPassword string   `mapstructure:"CacheSentinelPass"`
+
+	TLS           bool   `mapstructure:"CacheTLS"`
+	TLSCAFile     string `mapstructure:"CacheTLSCA"`
+	TLSCertFile   string `mapstructure:"CacheTLSCert"`
+	TLSKeyFile    string `mapstructure:"CacheTLSKey"`
+	TLSServerName string `mapstructure:"CacheTLSServerName"`
+	TLSInsecure   bool   `mapstructure:"CacheTLSInsecure"`
+
+	// TTL - время жизни записей, Sliding продлевает его при попадании в кэш.
+	// Псевдонимы с PopularThreshold переходами за PopularWindow кэшируются на PopularTTL, 0 отключает учёт популярности.
+	TTL              time.Duration `mapstructure:"CacheTTL"`
+	Sliding          bool          `mapstructure:"CacheSlidingTTL"`
+	PopularTTL       time.Duration `mapstructure:"CachePopularTTL"`
+	PopularThreshold int64         `mapstructure:"CachePopularThreshold"`
+	PopularWindow    time.Duration `mapstructure:"CachePopularWindow"`
+
+	// Warmup - порядок выбора ссылок для прогрева кэша при старте, recent или clicks, пустая строка отключает прогрев.
+	// Загружается WarmupLimit ссылок пакетами по WarmupBatch.
+	Warmup      string `mapstructure:"CacheWarmup"`
+	WarmupLimit int    `mapstructure:"CacheWarmupLimit"`
+	WarmupBatch int    `mapstructure:"CacheWarmupBatch"`
+}
+
+// Storage - это настройки подключения к SQL-базе данных.
+type Storage struct {
+	ConnString string   `mapstructure:"SqlConnString"`
+	Replicas   []string `mapstructure:"SqlReplicaConnStrings"`
+
+	// Настройки пулов pgxpool, 0 оставляет значение pgxpool по умолчанию.
+	MaxConns          int32         `mapstructure:"SqlMaxConns"`
+	MinConns          int32         `mapstructure:"SqlMinConns"`
+	MaxConnLifetime   time.Duration `mapstructure:"SqlMaxConnLifetime"`
+	MaxConnIdleTime   time.Duration `mapstructure:"SqlMaxConnIdleTime"`
+	HealthCheckPeriod time.Duration `mapstructure:"SqlHealthCheckPeriod"`
+}
+
+// Clicks - это настройки записи переходов по ссылкам.
+// Events включает запись каждого перехода, счётчики переходов ведутся всегда
+// и переносятся из кэша в SQL-базу данных раз в CountFlushInterval пакетами по CountFlushBatch псевдонимов.
+// Записанные переходы сворачиваются по часам для статистики раз в RollupInterval пакетами по RollupBatch.
+// CountryHeader - заголовок с кодом страны клиента от прокси или CDN, по умолчанию пуст и не читается. Клиент может
+// подставить заголовок сам, поэтому включать его стоит только за прокси, который перезаписывает или удаляет его.
+type Clicks struct {
+	QueueSize          int           `mapstructure:"ClickQueueSize"`
+	Events             bool          `mapstructure:"ClickEvents"`
+	CountFlushInterval time.Duration `mapstructure:"ClickCountFlushInterval"`
+	CountFlushBatch    int           `mapstructure:"ClickCountFlushBatch"`
+	RollupInterval     time.Duration `mapstructure:"ClickRollupInterval"`
+	RollupBatch        int           `mapstructure:"ClickRollupBatch"`
+	CountryHeader      string        `mapstructure:"ClickCountryHeader"`
+}
+
+// Deadlines - это ограничения времени на отдельные обращения к хранилищам при обработке запроса.
+type Deadlines struct {
+	Cache      time.Duration `mapstructure:"CacheTimeout"`
+	StoreRead  time.Duration `mapstructure:"StoreReadTimeout"`
+	StoreWrite time.Duration `mapstructure:"StoreWriteTimeout"`
+}
+
+// Webhooks - это настройки доставки событий подписчикам.
+// Неудачная доставка повторяется через WebhookBackoff, каждый раз вдвое дольше, но не дольше WebhookMaxBackoff,
+// после WebhookMaxAttempts попыток событие переносится в таблицу недоставленных.
+type Webhooks struct {
+	Interval    time.Duration `mapstructure:"WebhookInterval"`
+	Batch       int           `mapstructure:"WebhookBatch"`
+	MaxAttempts int           `mapstructure:"WebhookMaxAttempts"`
+	Backoff     time.Duration `mapstructure:"WebhookBackoff"`
+	MaxBackoff  time.Duration `mapstructure:"WebhookMaxBackoff"`
+	Timeout     time.Duration `mapstructure:"WebhookTimeout"`
+	// AllowHTTP разрешает подписки на http-адреса, AllowPrivate - доставку на локальные и частные адреса.
+	AllowHTTP    bool `mapstructure:"WebhookAllowHTTP"`
+	AllowPrivate bool `mapstructure:"WebhookAllowPrivate"`
+}
+
+// Outbox - это настройки ретрансляции событий об изменении ссылок.
+// Publisher - none, stdout, file или nats. none только очищает таблицу outbox.
+type Outbox struct {
+	Publisher   string        `mapstructure:"OutboxPublisher"`
+	File        string        `mapstructure:"OutboxFile"`
+	NatsURL     string        `mapstructure:"OutboxNatsURL"`
+	NatsSubject string        `mapstructure:"OutboxNatsSubject"`
+	Interval    time.Duration `mapstructure:"OutboxInterval"`
+	Batch       int           `mapstructure:"OutboxBatch"`
+	Timeout     time.Duration `mapstructure:"OutboxTimeout"`
+}
+
+// defaults - это значения по умолчанию для всех настроек. По этим же ключам читаются переменные окружения.
+var defaults = map[string]any{
+	// logging config.
+	"OutputType":    "console",
+	"LogDir":        "./logs",
+	"LogDirMode":    "0750",
+	"LogFileMode":   "0640",
+	"LogMaxSizeMB":  100,
+	"LogMaxAge":     "24h",
+	"LogMaxBackups": 7,
+	"LogCompress":   true,
+
+	// server config.
+	"ServerAddr":   "localhost:8081",
+	"ReadTimeout":  "10s",
+	"WriteTimeout": "5s",
+	"IdleTimeout":  "30s",
+	"GrpcAddr":     "",
+
+	"ShutdownGrace":         "15s",
+	"DomainRefreshInterval": "30s",
+	"RequireAPIKey":         false,
+	"AdminToken":            "",
+
+	// cache config.
+	"CacheAddr":  "localhost:6379",
+	"CacheUser":  "",
+	"CachePass":  "",
+	"MaxRetries": 3,
+	"PoolSize":   10,
+
+	"CacheMode":         "standalone",
+	"CacheAddrs":        []string{},
+	"CacheMasterName":   "",
+	"CacheSentinelUser": "",
+	"CacheSentinelPass": "",
+
+	"CacheTLS":           false,
+	"CacheTLSCA":         "",
+	"CacheTLSCert":       "",
+	"CacheTLSKey":        "",
+	"CacheTLSServerName": "",
+	"CacheTLSInsecure":   false,
+
+	"CacheTTL":              "1h",
+	"CacheSlidingTTL":       false,
+	"CachePopularTTL":       "24h",
+	"CachePopularThreshold": 0,
+	"CachePopularWindow":    "1m",
+
+	"CacheWarmup":      "",
+	"CacheWarmupLimit": 10000,
+	"CacheWarmupBatch": 500,
+
+	// storage config.
+	"SqlConnString":         "",
+	"SqlReplicaConnStrings": []string{},
+	"SqlMaxConns":           0,
+	"SqlMinConns":           0,
+	"SqlMaxConnLifetime":    0,
+	"SqlMaxConnIdleTime":    0,
+	"SqlHealthCheckPeriod":  0,
+
+	// clicks config.
+	"ClickQueueSize":          1024,
+	"ClickEvents":             true,
+	"ClickCountFlushInterval": "10s",
+	"ClickCountFlushBatch":    1000,
+	"ClickRollupInterval":     "1m",
+	"ClickRollupBatch":        5000,
+	"ClickCountryHeader":      "",
+
+	// deadlines config.
+	"CacheTimeout":      "1s",
+	"StoreReadTimeout":  "3s",
+	"StoreWriteTimeout": "3s",
+
+	// webhooks config.
+	"WebhookInterval":     "5s",
+	"WebhookBatch":        100,
+	"WebhookMaxAttempts":  8,
+	"WebhookBackoff":      "30s",
+	"WebhookMaxBackoff":   "1h",
+	"WebhookTimeout":      "5s",
+	"WebhookAllowHTTP":    false,
+	"WebhookAllowPrivate": false,
+
+	// outbox config.
+	"OutboxPublisher":   "none",
+	"OutboxFile":        "",
+	"OutboxNatsURL":     "nats://localhost:4222",
+	"OutboxNatsSubject": "shortener",
+	"OutboxInterval":    "1s",
+	"OutboxBatch":       500,
+	"OutboxTimeout":     "5s",
+
+	// runtime config, see Runtime.
+	"LogLevel":       "debug",
+	"RateLimit":      0,
+	"RateBurst":      20,
+	"RedirectStatus": 307,
+	"StickyMaxAge":   "720h",
+	"Blocklist":      []string{},
+}
+
+// Load читает конфигурацию из файла path, переменных окружения SHORTENER_* и значений по умолчанию
+// (в порядке убывания приоритета у окружения, файла и значений по умолчанию) и проверяет её.
+// Пустой path означает файл из переменной SHORTENER_CONFIG_PATH; если нет и её, файл не читается.
+// Для совместимости вместо пути можно передать имя переменной окружения, содержащей путь.
+// Возвращаемая ошибка содержит сразу все найденные проблемы.
+func Load(path string) (Config, error) {
 	conf := viper.New()
+	for key, value := range defaults {
+		conf.SetDefault(key, value)
+		_ = conf.BindEnv(key, envName(key))
+	}
 
-	_, ok := os.LookupEnv(path)
-	if ok == false {
-		return nil, ErrEnvVarNotFound
+	if path = Path(path); path != "" {
+		conf.SetConfigFile(path)
+		if err := conf.ReadInConfig(); err != nil {
+			return Config{}, fmt.Errorf("%w: %v", ErrFileNotFound, err)
+		}
 	}
 
-	conf.SetConfigFile(os.Getenv(path))
-	if err := conf.ReadInConfig(); err != nil {
-		return nil, ErrFileNotFound
+	var c Config
+	decodeErr := conf.Unmarshal(&c, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		durationHook,
+		fileModeHook,
+		mapstructure.StringToSliceHookFunc(","),
+	)))
+	if decodeErr != nil {
+		decodeErr = fmt.Errorf("%w: %v", ErrInvalidConfig, decodeErr)
 	}
-	// logging config.
-	conf.SetDefault("OutputType", "console")
 
-	// server config.
-	conf.SetDefault("Addr", "localhost:8081")
-	conf.SetDefault("ReadTimeout", time.Second*10)
-	conf.SetDefault("WriteTimeout", time.Second*5)
-	conf.SetDefault("IdleTimeout", time.Second*30)
-
-	// Cache config.
-	conf.SetDefault("RedisAddr", "localhost:6379")
-	conf.SetDefault("RedisUser", "")
-	conf.SetDefault("RedisPassword", "")
-	conf.SetDefault("MaxRetries", 3)
-	conf.SetDefault("PoolSize", 10)
-
-	return conf, nil
+	return c, errors.Join(decodeErr, c.Validate())
+}
+
+// Path возвращает путь к файлу конфигурации, который прочитает Load(path), или пустую строку.
+func Path(path string) string {
+	if path == "" {
+		return os.Getenv(PathEnv)
+	}
+	if _, err := os.Stat(path); err != nil {
+		if fromEnv, ok := os.LookupEnv(path); ok {
+			return fromEnv
+		}
+	}
+	return path
+}
+
+// Validate проверяет конфигурацию и возвращает все найденные ошибки.
+func (c Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: "+format, append([]any{ErrInvalidConfig}, args...)...))
+	}
+
+	if c.Log.OutputType != "console" && c.Log.OutputType != "json" {
+		invalid("OutputType must be console or json")
+	}
+	if c.Log.Dir == "" {
+		invalid("LogDir is required")
+	}
+	if c.Log.MaxSizeMB < 0 || c.Log.MaxAge < 0 || c.Log.MaxBackups < 0 {
+		invalid("LogMaxSizeMB, LogMaxAge and LogMaxBackups must not be negative")
+	}
+
+	if c.Server.Addr == "" {
+		invalid("ServerAddr is required")
+	}
+	if c.Server.ReadTimeout <= 0 || c.Server.WriteTimeout <= 0 || c.Server.IdleTimeout <= 0 {
+		invalid("ReadTimeout, WriteTimeout and IdleTimeout must be positive")
+	}
+	if c.Server.ShutdownGrace <= 0 {
+		invalid("ShutdownGrace must be positive")
+	}
+	if c.Server.DomainRefresh <= 0 {
+		invalid("DomainRefreshInterval must be positive")
+	}
+
+	switch c.Cache.Mode {
+	case "standalone":
+		if c.Cache.Addr == "" {
+			invalid("CacheAddr is required")
+		}
+	case "sentinel":
+		if len(c.Cache.Addrs) == 0 || c.Cache.MasterName == "" {
+			invalid("CacheAddrs and CacheMasterName are required in sentinel mode")
+		}
+	case "cluster":
+		if len(c.Cache.Addrs) == 0 && c.Cache.Addr == "" {
+			invalid("CacheAddrs is required in cluster mode")
+		}
+	default:
+		invalid("CacheMode must be standalone, sentinel or cluster")
+	}
+	if c.Cache.PoolSize <= 0 {
+		invalid("PoolSize must be positive")
+	}
+	if c.Cache.TTL <= 0 {
+		invalid("CacheTTL must be positive")
+	}
+	if c.Cache.PopularThreshold < 0 {
+		invalid("CachePopularThreshold must not be negative")
+	}
+	if c.Cache.PopularThreshold > 0 && (c.Cache.PopularTTL <= 0 || c.Cache.PopularWindow <= 0) {
+		invalid("CachePopularTTL and CachePopularWindow must be positive when CachePopularThreshold is set")
+	}
+	if c.Cache.Warmup != "" {
+		if c.Cache.Warmup != "recent" && c.Cache.Warmup != "clicks" {
+			invalid("CacheWarmup must be empty, recent or clicks")
+		}
+		if c.Cache.WarmupLimit <= 0 || c.Cache.WarmupBatch <= 0 {
+			invalid("CacheWarmupLimit and CacheWarmupBatch must be positive when CacheWarmup is set")
+		}
+	}
+	if (c.Cache.TLSCertFile == "") != (c.Cache.TLSKeyFile == "") {
+		invalid("CacheTLSCert and CacheTLSKey must be set together")
+	}
+	if c.Cache.TLS {
+		for _, file := range []string{c.Cache.TLSCAFile, c.Cache.TLSCertFile, c.Cache.TLSKeyFile} {
+			if _, err := os.Stat(file); file != "" && err != nil {
+				invalid("cache TLS file: %v", err)
+			}
+		}
+	}
+
+	if c.Storage.ConnString == "" {
+		invalid("SqlConnString is required")
+	}
+	for i, replica := range c.Storage.Replicas {
+		if strings.TrimSpace(replica) == "" {
+			invalid("SqlReplicaConnStrings[%d] is empty", i)
+		}
+	}
+	if c.Storage.MaxConns < 0 || c.Storage.MinConns < 0 || c.Storage.MaxConnLifetime < 0 ||
+		c.Storage.MaxConnIdleTime < 0 || c.Storage.HealthCheckPeriod < 0 {
+		invalid("Sql pool settings must not be negative")
+	}
+	if c.Storage.MaxConns > 0 && c.Storage.MinConns > c.Storage.MaxConns {
+		invalid("SqlMinConns must not exceed SqlMaxConns")
+	}
+	if c.Clicks.QueueSize <= 0 {
+		invalid("ClickQueueSize must be positive")
+	}
+	if c.Clicks.CountFlushInterval <= 0 || c.Clicks.CountFlushBatch <= 0 {
+		invalid("ClickCountFlushInterval and ClickCountFlushBatch must be positive")
+	}
+	if c.Clicks.RollupInterval <= 0 || c.Clicks.RollupBatch <= 0 {
+		invalid("ClickRollupInterval and ClickRollupBatch must be positive")
+	}
+	if c.Deadlines.Cache <= 0 || c.Deadlines.StoreRead <= 0 || c.Deadlines.StoreWrite <= 0 {
+		invalid("CacheTimeout, StoreReadTimeout and StoreWriteTimeout must be positive")
+	}
+	w := c.Webhooks
+	if w.Interval <= 0 || w.Batch <= 0 || w.MaxAttempts <= 0 || w.Backoff <= 0 || w.Timeout <= 0 {
+		invalid("WebhookInterval, WebhookBatch, WebhookMaxAttempts, WebhookBackoff and WebhookTimeout must be positive")
+	}
+	if w.MaxBackoff < w.Backoff {
+		invalid("WebhookMaxBackoff must not be less than WebhookBackoff")
+	}
+	switch c.Outbox.Publisher {
+	case "none", "stdout", "file":
+	case "nats":
+		if c.Outbox.NatsURL == "" || c.Outbox.NatsSubject == "" {
+			invalid("OutboxNatsURL and OutboxNatsSubject are required for the nats publisher")
+		}
+	default:
+		invalid("OutboxPublisher must be none, stdout, file or nats")
+	}
+	if c.Outbox.Publisher == "file" && c.Outbox.File == "" {
+		invalid("OutboxFile is required for the file publisher")
+	}
+	if c.Outbox.Interval <= 0 || c.Outbox.Batch <= 0 || c.Outbox.Timeout <= 0 {
+		invalid("OutboxInterval, OutboxBatch and OutboxTimeout must be positive")
+	}
+
+	return errors.Join(append(errs, c.Runtime.Validate())...)
+}
+
+// envName возвращает имя переменной окружения для ключа: ServerAddr -> SHORTENER_SERVER_ADDR.
+func envName(key string) string {
+	runes := []rune(key)
+	var b strings.Builder
+	b.WriteString(EnvPrefix)
+	b.WriteByte('_')
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prevLower := unicode.IsLower(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// durationHook декодирует длительности. Числа без единиц измерения считаются секундами,
+// как это было принято в файлах конфигурации раньше.
+func durationHook(from reflect.Type, to reflect.Type, data any) (any, error) {
+	if to != reflect.TypeOf(time.Duration(0)) {
+		return data, nil
+	}
+
+	switch v := data.(type) {
+	case int:
+		return time.Duration(v) * time.Second, nil
+	case int64:
+		return time.Duration(v) * time.Second, nil
+	case float64:
+		return time.Duration(v * float64(time.Second)), nil
+	case string:
+		if secs, err := strconv.ParseFloat(v, 64); err == nil {
+			return time.Duration(secs * float64(time.Second)), nil
+		}
+		return time.ParseDuration(v)
+	}
+	return data, nil
+}
+
+// fileModeHook декодирует права доступа, записанные строкой в восьмеричном виде, например "0640".
+func fileModeHook(from reflect.Type, to reflect.Type, data any) (any, error) {
+	if to != reflect.TypeOf(os.FileMode(0)) || from.Kind() != reflect.String {
+		return data, nil
+	}
+
+	mode, err := strconv.ParseUint(data.(string), 8, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file mode %q", data)
+	}
+	return os.FileMode(mode), nil
 }
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Logger - это интерфейс логера, в который пишутся применённые и отклонённые изменения конфигурации.
+type Logger interface {
+	Log(ctx context.Context, level string, msg string, fields ...logging.Field)
+}
+
+// Runtime - это настройки, которые безопасно менять без перезапуска сервиса.
+type Runtime struct {
+	LogLevel       string        `mapstructure:"LogLevel"`
+	RateLimit      float64       `mapstructure:"RateLimit"` // запросов в секунду с одного IP-адреса, 0 отключает ограничение.
+	RateBurst      int           `mapstructure:"RateBurst"`
+	RedirectStatus int           `mapstructure:"RedirectStatus"`
+	StickyMaxAge   time.Duration `mapstructure:"StickyMaxAge"`
+	Blocklist      []string      `mapstructure:"Blocklist"`
+}
+
+// Validate проверяет настройки и возвращает все найденные ошибки.
+func (r Runtime) Validate() error {
+	var errs []error
+	if _, err := logging.ParseLevel(r.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("%w: LogLevel %q", ErrInvalidConfig, r.LogLevel))
+	}
+	if r.RateLimit < 0 {
+		errs = append(errs, fmt.Errorf("%w: RateLimit must not be negative", ErrInvalidConfig))
+	}
+	if r.RateLimit > 0 && r.RateBurst < 1 {
+		errs = append(errs, fmt.Errorf("%w: RateBurst must be positive", ErrInvalidConfig))
+	}
+	switch r.RedirectStatus {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		errs = append(errs, fmt.Errorf("%w: RedirectStatus must be 301, 302, 307 or 308", ErrInvalidConfig))
+	}
+	if r.StickyMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("%w: StickyMaxAge must not be negative", ErrInvalidConfig))
+	}
+	for _, d := range r.Blocklist {
+		if strings.TrimSpace(d) == "" || strings.ContainsAny(d, "/: ") {
+			errs = append(errs, fmt.Errorf("%w: Blocklist entry %q is not a domain", ErrInvalidConfig, d))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Changes возвращает описания отличий r от prev в виде "поле: старое -> новое".
+func (r Runtime) Changes(prev Runtime) []string {
+	var changes []string
+	cur, old := reflect.ValueOf(r), reflect.ValueOf(prev)
+	for i := 0; i < cur.NumField(); i++ {
+		if !reflect.DeepEqual(cur.Field(i).Interface(), old.Field(i).Interface()) {
+			changes = append(changes, fmt.Sprintf("%s: %v -> %v", cur.Type().Field(i).Name, old.Field(i).Interface(), cur.Field(i).Interface()))
+		}
+	}
+	return changes
+}
+
+// Reloader перечитывает файл конфигурации и применяет изменившиеся настройки Runtime.
+// Некорректная конфигурация отклоняется, и продолжают действовать прежние настройки.
+type Reloader struct {
+	mu      sync.Mutex
+	path    string
+	current Runtime
+	apply   func(Runtime)
+	log     Logger
+}
+
+// NewReloader возвращает Reloader для файла path (см. Path) с уже применёнными настройками current.
+// apply вызывается с новыми настройками после каждой успешной перезагрузки.
+func NewReloader(path string, current Runtime, log Logger, apply func(Runtime)) *Reloader {
+	return &Reloader{path: path, current: current, apply: apply, log: log}
+}
+
+// Current возвращает действующие настройки.
+func (r *Reloader) Current() Runtime {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Reload перечитывает конфигурацию и применяет новые настройки Runtime, если вся конфигурация корректна.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ctx := context.Background()
+
+	conf, err := Load(r.path)
+	if err != nil {
+		r.log.Log(ctx, "error", "config reload rejected", logging.String("path", r.path), logging.Err(err))
+		return err
+	}
+	next := conf.Runtime
+
+	changes := next.Changes(r.current)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	r.apply(next)
+	r.current = next
+	for _, change := range changes {
+		r.log.Log(ctx, "info", "config change applied", logging.String("change", change))
+	}
+	return nil
+}
+
+// Watch перезагружает настройки при каждом изменении файла конфигурации.
+// Если сервис запущен без файла, следить не за чем и Watch ничего не делает.
+func (r *Reloader) Watch() {
+	if r.path == "" {
+		return
+	}
+
+	conf := viper.New()
+	conf.SetConfigFile(r.path)
+	conf.OnConfigChange(func(fsnotify.Event) {
+		_ = r.Reload()
+	})
+	conf.WatchConfig()
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRuntime_Validate(t *testing.T) {
+	valid := Runtime{LogLevel: "info", RedirectStatus: 302, RateLimit: 5, RateBurst: 10, Blocklist: []string{"evil.com"}}
+	assert.Nil(t, valid.Validate())
+
+	invalid := Runtime{LogLevel: "loud", RedirectStatus: 200, RateLimit: 5, StickyMaxAge: -time.Second, Blocklist: []string{"http://evil.com"}}
+	err := invalid.Validate()
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+	for _, field := range []string{"LogLevel", "RateBurst", "RedirectStatus", "StickyMaxAge", "Blocklist"} {
+		assert.Contains(t, err.Error(), field)
+	}
+}
+
+func TestReloader_Reload(t *testing.T) {
+	const base = "SqlConnString: postgres://localhost/service\n"
+	path := writeConfig(t, base+"LogLevel: info\n")
+
+	var out bytes.Buffer
+	var applied []Runtime
+	current := Runtime{LogLevel: "debug", RateBurst: 20, RedirectStatus: 307, StickyMaxAge: time.Hour * 24 * 30, Blocklist: []string{}}
+	r := NewReloader(path, current, logging.NewLogger("json", &out), func(next Runtime) {
+		applied = append(applied, next)
+	})
+
+	// применяется только изменившийся уровень логирования.
+	assert.Nil(t, r.Reload())
+	assert.Len(t, applied, 1)
+	assert.Equal(t, "info", r.Current().LogLevel)
+	assert.Contains(t, out.String(), "LogLevel: debug -> info")
+
+	// повторная перезагрузка без изменений ничего не применяет.
+	assert.Nil(t, r.Reload())
+	assert.Len(t, applied, 1)
+
+	// некорректная конфигурация отклоняется, действуют прежние настройки.
+	assert.Nil(t, os.WriteFile(path, []byte(base+"LogLevel: info\nRedirectStatus: 200\n"), 0600))
+	assert.ErrorIs(t, r.Reload(), ErrInvalidConfig)
+	assert.Len(t, applied, 1)
+	assert.Equal(t, 307, r.Current().RedirectStatus)
+	assert.Contains(t, out.String(), "config reload rejected")
+
+	assert.Nil(t, os.WriteFile(path, []byte("LogLevel: [\n"), 0600))
+	assert.ErrorIs(t, r.Reload(), ErrFileNotFound)
+	assert.Equal(t, "info", r.Current().LogLevel)
+}
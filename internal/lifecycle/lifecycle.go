@@ -0,0 +1,99 @@
+// Package lifecycle управляет фоновыми задачами сервиса и его упорядоченной остановкой.
+package lifecycle
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrGraceExpired = errors.New("shutdown grace period expired")
+
+type Logger interface {
+	Log(ctx context.Context, level string, msg string, fields ...logging.Field)
+}
+
+// stage - это шаг остановки сервиса.
+type stage struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Manager запускает фоновые задачи и останавливает сервис по шагам в порядке их регистрации.
+type Manager struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+
+	mu     sync.Mutex
+	stages []stage
+	log    Logger
+}
+
+// New возвращает Manager, логирующий ход остановки в log.
+func New(log Logger) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{ctx: ctx, cancel: cancel, log: log}
+}
+
+// Go запускает фоновую задачу. Задача должна завершиться после отмены ctx, что происходит на шаге StopWorkers.
+func (m *Manager) Go(name string, fn func(ctx context.Context)) {
+	m.workers.Add(1)
+	go func() {
+		defer m.workers.Done()
+		fn(m.ctx)
+	}()
+}
+
+// StopWorkers отменяет контекст фоновых задач и ждёт их завершения, но не дольше ctx.
+// Его можно зарегистрировать как обычный шаг остановки, чтобы выбрать момент остановки задач.
+func (m *Manager) StopWorkers(ctx context.Context) error {
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("background workers: %w", ErrGraceExpired)
+	}
+}
+
+// OnShutdown добавляет шаг остановки. Шаги выполняются в порядке добавления.
+func (m *Manager) OnShutdown(name string, fn func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stages = append(m.stages, stage{name: name, fn: fn})
+}
+
+// Shutdown выполняет все шаги остановки в пределах grace. Если время вышло, оставшиеся шаги
+// всё равно выполняются с уже отменённым контекстом, чтобы ресурсы были освобождены.
+// Ошибки шагов не логируются, а возвращаются все вместе: последним шагом обычно закрываются сами файлы логов.
+func (m *Manager) Shutdown(grace time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	m.mu.Lock()
+	stages := m.stages
+	m.mu.Unlock()
+
+	var errs []error
+	for _, s := range stages {
+		m.log.Log(ctx, "info", "shutdown stage", logging.String("stage", s.name))
+		if err := s.fn(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+		}
+	}
+
+	// задачи, не остановленные явным шагом, отменяются в конце.
+	m.cancel()
+	return errors.Join(errs...)
+}
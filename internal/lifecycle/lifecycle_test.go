@@ -0,0 +1,63 @@
+package lifecycle
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestManager_Shutdown(t *testing.T) {
+	m := New(logging.NewLogger("json", io.Discard))
+
+	var order []string
+	stopped := make(chan struct{})
+	m.Go("ping", func(ctx context.Context) {
+		<-ctx.Done()
+		order = append(order, "worker")
+		close(stopped)
+	})
+
+	m.OnShutdown("server", func(ctx context.Context) error {
+		order = append(order, "server")
+		return nil
+	})
+	m.OnShutdown("workers", m.StopWorkers)
+	m.OnShutdown("cache", func(ctx context.Context) error {
+		<-stopped
+		order = append(order, "cache")
+		return errors.New("close failed")
+	})
+	m.OnShutdown("logs", func(ctx context.Context) error {
+		order = append(order, "logs")
+		return nil
+	})
+
+	err := m.Shutdown(time.Second)
+	assert.ErrorContains(t, err, "cache: close failed")
+	assert.Equal(t, []string{"server", "worker", "cache", "logs"}, order)
+}
+
+func TestManager_Grace(t *testing.T) {
+	m := New(logging.NewLogger("json", io.Discard))
+
+	// задача игнорирует отмену дольше, чем длится grace.
+	release := make(chan struct{})
+	defer close(release)
+	m.Go("stuck", func(ctx context.Context) { <-release })
+
+	closed := false
+	m.OnShutdown("workers", m.StopWorkers)
+	m.OnShutdown("logs", func(ctx context.Context) error {
+		closed = true
+		return ctx.Err()
+	})
+
+	err := m.Shutdown(time.Millisecond * 50)
+	assert.ErrorIs(t, err, ErrGraceExpired)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, closed)
+}
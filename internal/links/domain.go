@@ -0,0 +1,88 @@
+package links
+
+import (
+	"errors"
+	"github.com/asaskevich/govalidator"
+	"net"
+	"strings"
+	"time"
+)
+
+var ErrInvalidDomain = errors.New("invalid domain")
+var ErrInvalidAlias = errors.New("alias should be 1 to 64 latin letters, digits, '-' or '_'")
+
+// MaxAliasLen - это максимальная длина псевдонима ссылки.
+const MaxAliasLen = 64
+
+// Domain - это собственный домен, на котором псевдонимы ссылок не пересекаются с псевдонимами других доменов.
+// Ссылки на домене может создавать только рабочее пространство, которому он принадлежит.
+type Domain struct {
+	Host        string    `json:"host"`
+	WorkspaceID int64     `json:"workspace_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Key возвращает ключ ссылки с псевдонимом alias на домене domain, под которым она хранится в SQL-базе данных и кэше,
+// а её переходы - в статистике. Ключ ссылки без домена совпадает с псевдонимом, ключ ссылки на собственном домене
+// имеет вид "host/alias": псевдонимы не содержат "/", поэтому ключи разных доменов не пересекаются.
+func Key(domain, alias string) string {
+	if domain == "" {
+		return alias
+	}
+	return domain + "/" + alias
+}
+
+// ParseKey возвращает домен и псевдоним ссылки по её ключу.
+func ParseKey(key string) (domain, alias string) {
+	if i := strings.LastIndexByte(key, '/'); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return "", key
+}
+
+// ValidAlias проверяет, что псевдоним непустой, не длиннее MaxAliasLen и состоит из латинских букв, цифр, '-' и '_'.
+// Такой псевдоним не содержит "/" и не ломает ключ ссылки на собственном домене.
+func ValidAlias(alias string) bool {
+	if alias == "" || len(alias) > MaxAliasLen {
+		return false
+	}
+	for i := 0; i < len(alias); i++ {
+		c := alias[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckKey проверяет ключ ссылки: псевдоним должен проходить ValidAlias, а домен, если он есть, быть нормализованным.
+func CheckKey(key string) error {
+	domain, alias := ParseKey(key)
+	if !ValidAlias(alias) {
+		return ErrInvalidAlias
+	}
+	if domain != "" {
+		if host, err := NormalizeDomain(domain); err != nil || host != domain {
+			return ErrInvalidDomain
+		}
+	}
+	return nil
+}
+
+// NormalizeDomain приводит имя домена к нижнему регистру без завершающей точки и проверяет его.
+func NormalizeDomain(raw string) (string, error) {
+	host := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(raw)), ".")
+	if !strings.Contains(host, ".") || !govalidator.IsDNSName(host) {
+		return "", ErrInvalidDomain
+	}
+	return host, nil
+}
+
+// RequestHost возвращает имя хоста из заголовка Host запроса без порта, в нижнем регистре и без завершающей точки.
+func RequestHost(hostport string) string {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
@@ -0,0 +1,105 @@
+package links
+
+import (
+	"Darkyfun/UrlShortener/internal/rules"
+	"Darkyfun/UrlShortener/internal/split"
+	"errors"
+	"github.com/asaskevich/govalidator"
+	"time"
+)
+
+var ErrInvalidUrl = errors.New("invalid url")
+var ErrInvalidRules = errors.New("invalid rules")
+var ErrInvalidSplit = errors.New("invalid split")
+var ErrInvalidMeta = errors.New("invalid meta")
+
+// Ограничения на метаданные ссылки.
+const (
+	maxTitleLen       = 256
+	maxDescriptionLen = 2048
+	maxTags           = 20
+	maxTagLen         = 64
+	maxOwnerLen       = 128
+	maxCampaignLen    = 128
+)
+
+// Draft - это новая ссылка вместе со всеми атрибутами, которые записываются одновременно с ней.
+// Пустые атрибуты не сохраняются, нулевой ExpiresAt означает бессрочную ссылку.
+type Draft struct {
+	Original  string
+	Rules     rules.Set
+	Split     split.Split
+	Meta      Meta
+	ExpiresAt time.Time
+}
+
+// NewDraft проверяет оригинальный URL, правила, A/B-распределение и метаданные ссылки и возвращает её черновик.
+// Так проверяются и ссылки, создаваемые через API, и загружаемые из файла.
+func NewDraft(original string, rr []rules.Rule, s *split.Split, meta Meta) (Draft, error) {
+	if !govalidator.IsURL(original) {
+		return Draft{}, ErrInvalidUrl
+	}
+	d := Draft{Original: original}
+
+	if len(rr) > 0 {
+		set, err := rules.Compile(rr)
+		if err != nil || !validDestinations(set) {
+			return Draft{}, ErrInvalidRules
+		}
+		d.Rules = set
+	}
+
+	if s != nil {
+		ab, err := split.Normalize(*s)
+		if err != nil || !validVariants(ab) {
+			return Draft{}, ErrInvalidSplit
+		}
+		d.Split = ab
+	}
+
+	if !meta.Empty() {
+		if err := meta.Validate(); err != nil {
+			return Draft{}, err
+		}
+		d.Meta = meta
+	}
+
+	return d, nil
+}
+
+// validDestinations проверяет, что все адреса назначения в правилах являются корректными URL.
+func validDestinations(set rules.Set) bool {
+	for _, r := range set {
+		if !govalidator.IsURL(r.Destination) {
+			return false
+		}
+	}
+	return true
+}
+
+// validVariants проверяет, что адреса всех вариантов A/B-распределения являются корректными URL.
+func validVariants(s split.Split) bool {
+	for _, v := range s.Variants {
+		if !govalidator.IsURL(v.Url) {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate проверяет длину метаданных ссылки.
+func (m Meta) Validate() error {
+	if len(m.Title) > maxTitleLen || len(m.Description) > maxDescriptionLen || len(m.Owner) > maxOwnerLen ||
+		len(m.Campaign) > maxCampaignLen {
+		return ErrInvalidMeta
+	}
+	if len(m.Tags) > maxTags {
+		return ErrInvalidMeta
+	}
+	for _, tag := range m.Tags {
+		if tag == "" || len(tag) > maxTagLen {
+			return ErrInvalidMeta
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+// Package links содержит описание коротких ссылок, их метаданных и параметров поиска по ним.
+package links
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+var ErrInvalidSort = errors.New("invalid sort")
+
+// Поля, по которым можно сортировать список ссылок.
+const (
+	SortCreated = "created"
+	SortAlias   = "alias"
+)
+
+// Порядок сортировки списка ссылок.
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+const DefaultLimit = 50
+const MaxLimit = 500
+
+// Meta - это метаданные ссылки.
+type Meta struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Owner       string   `json:"owner"`
+	// Campaign - имя кампании, по которой статистика переходов ссылок собирается вместе.
+	Campaign string `json:"campaign"`
+}
+
+// Empty сообщает, что у ссылки нет метаданных.
+func (m Meta) Empty() bool {
+	return m.Title == "" && m.Description == "" && len(m.Tags) == 0 && m.Owner == "" && m.Campaign == ""
+}
+
+// Link - это короткая ссылка вместе с её метаданными.
+type Link struct {
+	Alias     string    `json:"alias"`
+	Original  string    `json:"original"`
+	CreatedAt time.Time `json:"created_at"`
+	Meta
+}
+
+// Filter - это параметры поиска по ссылкам. Пустые поля не участвуют в фильтрации.
+type Filter struct {
+	Tag      string
+	Owner    string
+	Campaign string
+	From     time.Time
+	To       time.Time
+	Query    string
+	Sort     string
+	Order    string
+	Cursor   *Cursor
+	Limit    int
+}
+
+// Normalize проверяет параметры сортировки и выставляет значения по умолчанию.
+func (f Filter) Normalize() (Filter, error) {
+	switch f.Sort {
+	case "":
+		f.Sort = SortCreated
+	case SortCreated, SortAlias:
+	default:
+		return f, ErrInvalidSort
+	}
+
+	switch f.Order {
+	case "":
+		f.Order = OrderDesc
+	case OrderAsc, OrderDesc:
+	default:
+		return f, ErrInvalidSort
+	}
+
+	if f.Limit <= 0 {
+		f.Limit = DefaultLimit
+	}
+	if f.Limit > MaxLimit {
+		f.Limit = MaxLimit
+	}
+
+	return f, nil
+}
+
+// Cursor - это позиция последней выданной ссылки, с которой продолжается следующая страница.
+type Cursor struct {
+	CreatedAt time.Time `json:"c"`
+	Alias     string    `json:"a"`
+}
+
+// CursorAfter возвращает курсор, указывающий на ссылку l.
+func CursorAfter(l Link) *Cursor {
+	return &Cursor{CreatedAt: l.CreatedAt, Alias: l.Alias}
+}
+
+// Encode кодирует курсор в непрозрачную для клиента строку.
+func (c *Cursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor декодирует курсор, полученный от клиента.
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err = json.Unmarshal(raw, &c); err != nil || c.Alias == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
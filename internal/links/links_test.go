@@ -0,0 +1,121 @@
+package links
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilter_Normalize(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		exp    Filter
+		err    error
+	}{
+		{name: "defaults", filter: Filter{}, exp: Filter{Sort: SortCreated, Order: OrderDesc, Limit: DefaultLimit}, err: nil},
+		{name: "limit is capped", filter: Filter{Sort: SortAlias, Order: OrderAsc, Limit: 10000}, exp: Filter{Sort: SortAlias, Order: OrderAsc, Limit: MaxLimit}, err: nil},
+		{name: "unknown sort", filter: Filter{Sort: "original"}, err: ErrInvalidSort},
+		{name: "unknown order", filter: Filter{Order: "up"}, err: ErrInvalidSort},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.filter.Normalize()
+			assert.Equal(t, tt.err, err)
+			if err == nil {
+				assert.Equal(t, tt.exp, res)
+			}
+		})
+	}
+}
+
+func TestCursor(t *testing.T) {
+	created := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	c := CursorAfter(Link{Alias: "abc", CreatedAt: created})
+
+	res, err := DecodeCursor(c.Encode())
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", res.Alias)
+	assert.True(t, created.Equal(res.CreatedAt))
+
+	_, err = DecodeCursor("%%%")
+	assert.Equal(t, ErrInvalidCursor, err)
+
+	_, err = DecodeCursor("e30")
+	assert.Equal(t, ErrInvalidCursor, err)
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		domain string
+		alias  string
+		key    string
+	}{
+		{domain: "", alias: "abc", key: "abc"},
+		{domain: "go.brand-a.com", alias: "abc", key: "go.brand-a.com/abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			assert.Equal(t, tt.key, Key(tt.domain, tt.alias))
+			domain, alias := ParseKey(tt.key)
+			assert.Equal(t, tt.domain, domain)
+			assert.Equal(t, tt.alias, alias)
+		})
+	}
+}
+
+func TestCheckKey(t *testing.T) {
+	tests := []struct {
+		key string
+		err error
+	}{
+		{key: "abc_DEF-09"},
+		{key: "go.brand-a.com/abc"},
+		{key: "", err: ErrInvalidAlias},
+		{key: "/", err: ErrInvalidAlias},
+		{key: "a b", err: ErrInvalidAlias},
+		{key: "абв", err: ErrInvalidAlias},
+		{key: strings.Repeat("a", MaxAliasLen+1), err: ErrInvalidAlias},
+		{key: "go.brand-a.com/", err: ErrInvalidAlias},
+		{key: "localhost/abc", err: ErrInvalidDomain},
+		{key: "Go.Brand-A.com/abc", err: ErrInvalidDomain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			assert.Equal(t, tt.err, CheckKey(tt.key))
+		})
+	}
+}
+
+func TestNormalizeDomain(t *testing.T) {
+	tests := []struct {
+		raw  string
+		host string
+		err  error
+	}{
+		{raw: "go.brand-a.com", host: "go.brand-a.com"},
+		{raw: " Go.Brand-A.com. ", host: "go.brand-a.com"},
+		{raw: "localhost", err: ErrInvalidDomain},
+		{raw: "go.brand-a.com:8080", err: ErrInvalidDomain},
+		{raw: "go.brand-a.com/x", err: ErrInvalidDomain},
+		{raw: "", err: ErrInvalidDomain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			host, err := NormalizeDomain(tt.raw)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.host, host)
+		})
+	}
+}
+
+func TestRequestHost(t *testing.T) {
+	assert.Equal(t, "go.brand-a.com", RequestHost("Go.Brand-A.com:8080"))
+	assert.Equal(t, "go.brand-a.com", RequestHost("go.brand-a.com."))
+	assert.Equal(t, "::1", RequestHost("[::1]:8080"))
+}
@@ -4,51 +4,75 @@ package logpath
 import (
 	"errors"
 	"fmt"
-	"log"
 	"os"
+	"path/filepath"
+	"time"
 )
 
+// Options - настройки расположения, прав доступа и ротации файлов логов.
+type Options struct {
+	Dir      string
+	DirMode  os.FileMode
+	FileMode os.FileMode
+
+	// MaxSize - размер файла в байтах, после которого он ротируется, 0 отключает ротацию по размеру.
+	MaxSize int64
+	// MaxAge - возраст файла, после которого он ротируется, 0 отключает ротацию по возрасту.
+	MaxAge time.Duration
+	// MaxBackups - сколько ротированных файлов хранить, 0 хранит все.
+	MaxBackups int
+	// Compress включает сжатие ротированных файлов gzip.
+	Compress bool
+}
+
 // Logs - структура, представляющая файлы, в которые будут записываться логи.
 type Logs struct {
-	IncomeLog *os.File
-	ErrorLog  *os.File
+	IncomeLog *RotatingFile
+	ErrorLog  *RotatingFile
 }
 
-// DestinationLog создаёт файлы логов в указанной директории.
+// DestinationLog создаёт файлы логов в директории opts.Dir.
 // incoming.txt хранит логи входящих http-запросов.
 // logs.txt хранит внутренние логи сервиса.
-func DestinationLog(path string) Logs {
-	err := os.Mkdir(path, 0750)
-	if err != nil && errors.Is(err, os.ErrExist) {
-		fmt.Println("Directory already exists. Continue...")
+func DestinationLog(opts Options) (Logs, error) {
+	err := os.MkdirAll(opts.Dir, opts.DirMode)
+	if err != nil && !errors.Is(err, os.ErrExist) {
+		return Logs{}, fmt.Errorf("failed to create dir: %w", err)
 	}
 
-	incomeLog, err := os.OpenFile(path+"/incoming.txt", os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0777)
+	incomeLog, err := OpenRotating(filepath.Join(opts.Dir, "incoming.txt"), opts)
 	if err != nil {
-		log.Fatalf("failed to create dir: %v\n", err)
+		return Logs{}, err
 	}
 
-	errorLog, err := os.OpenFile(path+"/logs.txt", os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0777)
+	errorLog, err := OpenRotating(filepath.Join(opts.Dir, "logs.txt"), opts)
 	if err != nil {
-		log.Fatalf("failed to create dir: %v\n", err)
+		_ = incomeLog.Close()
+		return Logs{}, err
 	}
 
-	return Logs{ErrorLog: errorLog, IncomeLog: incomeLog}
+	return Logs{ErrorLog: errorLog, IncomeLog: incomeLog}, nil
+}
+
+// Reopen заново открывает файлы логов, например после внешнего logrotate.
+func (l Logs) Reopen() error {
+	return errors.Join(l.IncomeLog.Reopen(), l.ErrorLog.Reopen())
 }
 
-// CloseFiles закрывает дескрипторы файлов.
+// CloseFiles сбрасывает файлы на диск и закрывает их, дожидаясь сжатия ротированных файлов.
 func (l Logs) CloseFiles() error {
-	err := l.IncomeLog.Sync()
-	if err != nil {
-		if innErr := l.ErrorLog.Sync(); err != nil {
-			return fmt.Errorf("can not close http log: %v\n can not close inner error log: %v\n", err, innErr)
-		}
-		return fmt.Errorf("can not close http log: %v\n", err)
+	var errs []error
+	if err := l.IncomeLog.Sync(); err != nil {
+		errs = append(errs, fmt.Errorf("can not sync http log: %w", err))
 	}
-
-	err = l.ErrorLog.Sync()
-	if err != nil {
-		return fmt.Errorf("can not close inner error log: %v\n", err)
+	if err := l.IncomeLog.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("can not close http log: %w", err))
+	}
+	if err := l.ErrorLog.Sync(); err != nil {
+		errs = append(errs, fmt.Errorf("can not sync inner error log: %w", err))
+	}
+	if err := l.ErrorLog.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("can not close inner error log: %w", err))
 	}
-	return nil
+	return errors.Join(errs...)
 }
@@ -0,0 +1,245 @@
+package logpath
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupLayout - формат метки времени в имени ротированного файла, сортируется лексикографически.
+const backupLayout = "20060102T150405.000"
+
+var ErrClosed = errors.New("log file is closed")
+
+// RotatingFile - файл лога, который ротируется по размеру и возрасту.
+// Ротированные файлы получают метку времени в имени, при необходимости сжимаются gzip
+// и удаляются, если их больше, чем MaxBackups.
+type RotatingFile struct {
+	mu     sync.Mutex
+	path   string
+	opts   Options
+	file   *os.File
+	size   int64
+	opened time.Time
+	closed bool
+
+	// bg ждёт фоновые сжатие и очистку старых файлов, cleanMu не даёт им пересекаться.
+	bg      sync.WaitGroup
+	cleanMu sync.Mutex
+
+	now func() time.Time
+}
+
+// OpenRotating открывает файл лога path для дозаписи.
+func OpenRotating(path string, opts Options) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, opts: opts, now: time.Now}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open открывает файл по пути r.path, вызывается с захваченным r.mu.
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, r.opts.FileMode)
+	if err != nil {
+		return fmt.Errorf("can not open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("can not stat log file: %w", err)
+	}
+
+	r.file = file
+	r.size = info.Size()
+	r.opened = r.now()
+	return nil
+}
+
+// Write записывает p в файл, предварительно ротируя его, если превышен размер или возраст.
+// Если ротировать файл не удалось, p всё равно записывается в текущий файл, а ошибка ротации возвращается вместе с записанным
+// количеством байт. Ротация повторяется при следующей записи.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 0, ErrClosed
+	}
+
+	var rotateErr error
+	if r.needRotate(int64(len(p))) {
+		rotateErr = r.rotate()
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	if err == nil {
+		err = rotateErr
+	}
+	return n, err
+}
+
+// needRotate сообщает, нужно ли ротировать файл перед записью n байт.
+func (r *RotatingFile) needRotate(n int64) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.opts.MaxSize > 0 && r.size+n > r.opts.MaxSize {
+		return true
+	}
+	return r.opts.MaxAge > 0 && r.now().Sub(r.opened) >= r.opts.MaxAge
+}
+
+// rotate переименовывает текущий файл и открывает новый, вызывается с захваченным r.mu.
+// Старый файл закрывается только после того, как открыт новый, поэтому при любой ошибке запись продолжается в старый.
+func (r *RotatingFile) rotate() error {
+	ext := filepath.Ext(r.path)
+	backup := strings.TrimSuffix(r.path, ext) + "-" + r.now().Format(backupLayout) + ext
+	if err := os.Rename(r.path, backup); err != nil {
+		return fmt.Errorf("can not rename log file: %w", err)
+	}
+
+	old := r.file
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	r.bg.Add(1)
+	go func() {
+		defer r.bg.Done()
+		r.cleanup(backup)
+	}()
+
+	if err := old.Close(); err != nil {
+		return fmt.Errorf("can not close log file: %w", err)
+	}
+	return nil
+}
+
+// cleanup сжимает только что ротированный файл и удаляет лишние старые файлы.
+// Ошибки здесь не фатальны: писать их некуда, кроме самого лога, поэтому они игнорируются.
+func (r *RotatingFile) cleanup(backup string) {
+	r.cleanMu.Lock()
+	defer r.cleanMu.Unlock()
+
+	if r.opts.Compress {
+		_ = compress(backup, r.opts.FileMode)
+	}
+
+	if r.opts.MaxBackups <= 0 {
+		return
+	}
+
+	backups, err := r.Backups()
+	if err != nil || len(backups) <= r.opts.MaxBackups {
+		return
+	}
+	for _, old := range backups[:len(backups)-r.opts.MaxBackups] {
+		_ = os.Remove(old)
+	}
+}
+
+// Backups возвращает ротированные файлы лога от старых к новым.
+func (r *RotatingFile) Backups() ([]string, error) {
+	ext := filepath.Ext(r.path)
+	prefix := filepath.Base(strings.TrimSuffix(r.path, ext)) + "-"
+
+	entries, err := os.ReadDir(filepath.Dir(r.path))
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if !strings.HasSuffix(name, ext) && !strings.HasSuffix(name, ext+".gz") {
+			continue
+		}
+		backups = append(backups, filepath.Join(filepath.Dir(r.path), name))
+	}
+
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// compress сжимает файл path в path.gz и удаляет исходный файл.
+func compress(path string, mode os.FileMode) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path + ".gz")
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// Reopen закрывает и заново открывает файл по тому же пути.
+// Нужен для внешнего logrotate, который переименовывает файл и посылает сигнал.
+func (r *RotatingFile) Reopen() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return ErrClosed
+	}
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("can not close log file: %w", err)
+	}
+	return r.open()
+}
+
+// Sync сбрасывает содержимое файла на диск.
+func (r *RotatingFile) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return ErrClosed
+	}
+	return r.file.Sync()
+}
+
+// Close закрывает файл и дожидается фонового сжатия ротированных файлов.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	err := r.file.Close()
+	r.mu.Unlock()
+
+	r.bg.Wait()
+	return err
+}
@@ -0,0 +1,151 @@
+package logpath
+
+import (
+	"compress/gzip"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testOptions(dir string) Options {
+	return Options{Dir: dir, DirMode: 0750, FileMode: 0640}
+}
+
+func TestRotatingFile_Size(t *testing.T) {
+	dir := t.TempDir()
+	opts := testOptions(dir)
+	opts.MaxSize = 10
+	opts.Compress = true
+
+	path := filepath.Join(dir, "logs.txt")
+	r, err := OpenRotating(path, opts)
+	assert.Nil(t, err)
+
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { clock = clock.Add(time.Second); return clock }
+
+	_, err = r.Write([]byte("12345678;\n"))
+	assert.Nil(t, err)
+	_, err = r.Write([]byte("second;\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, r.Close())
+
+	current, _ := os.ReadFile(path)
+	assert.Equal(t, "second;\n", string(current))
+
+	backups, err := r.Backups()
+	assert.Nil(t, err)
+	assert.Len(t, backups, 1)
+	assert.True(t, strings.HasSuffix(backups[0], ".txt.gz"))
+
+	f, err := os.Open(backups[0])
+	assert.Nil(t, err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	assert.Nil(t, err)
+	old, _ := io.ReadAll(zr)
+	assert.Equal(t, "12345678;\n", string(old))
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+}
+
+func TestRotatingFile_RenameFails(t *testing.T) {
+	dir := t.TempDir()
+	opts := testOptions(dir)
+	opts.MaxSize = 10
+
+	path := filepath.Join(dir, "logs.txt")
+	r, err := OpenRotating(path, opts)
+	assert.Nil(t, err)
+
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return clock }
+
+	// непустой каталог на месте резервной копии не даёт переименовать файл.
+	blocker := filepath.Join(dir, "logs-"+clock.Format(backupLayout)+".txt")
+	assert.Nil(t, os.MkdirAll(filepath.Join(blocker, "busy"), 0750))
+
+	_, err = r.Write([]byte("12345678;\n"))
+	assert.Nil(t, err)
+	n, err := r.Write([]byte("second;\n"))
+	assert.NotNil(t, err)
+	assert.Equal(t, len("second;\n"), n)
+	_, err = r.Write([]byte("third;\n"))
+	assert.NotNil(t, err)
+
+	current, _ := os.ReadFile(path)
+	assert.Equal(t, "12345678;\nsecond;\nthird;\n", string(current))
+
+	// после устранения причины ротация проходит при следующей записи.
+	assert.Nil(t, os.RemoveAll(blocker))
+	_, err = r.Write([]byte("fourth;\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, r.Close())
+
+	current, _ = os.ReadFile(path)
+	assert.Equal(t, "fourth;\n", string(current))
+	old, _ := os.ReadFile(blocker)
+	assert.Equal(t, "12345678;\nsecond;\nthird;\n", string(old))
+}
+
+func TestRotatingFile_AgeAndRetention(t *testing.T) {
+	dir := t.TempDir()
+	opts := testOptions(dir)
+	opts.MaxAge = time.Hour
+	opts.MaxBackups = 2
+
+	path := filepath.Join(dir, "incoming.txt")
+	r, err := OpenRotating(path, opts)
+	assert.Nil(t, err)
+
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return clock }
+	r.opened = clock
+
+	for i := 0; i < 5; i++ {
+		_, err = r.Write([]byte("line;\n"))
+		assert.Nil(t, err)
+		clock = clock.Add(time.Hour)
+	}
+	assert.Nil(t, r.Close())
+
+	backups, err := r.Backups()
+	assert.Nil(t, err)
+	assert.Len(t, backups, 2)
+	assert.True(t, strings.HasSuffix(backups[1], "incoming-20260101T040000.000.txt"))
+
+	_, err = r.Write([]byte("after close"))
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestLogs_Reopen(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "nested", "logs")
+	logs, err := DestinationLog(testOptions(dir))
+	assert.Nil(t, err)
+
+	_, err = logs.ErrorLog.Write([]byte("before;\n"))
+	assert.Nil(t, err)
+
+	// внешний logrotate переименовывает файл, после чего сервис открывает его заново.
+	path := filepath.Join(dir, "logs.txt")
+	assert.Nil(t, os.Rename(path, path+".1"))
+	assert.Nil(t, logs.Reopen())
+
+	_, err = logs.ErrorLog.Write([]byte("after;\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, logs.CloseFiles())
+
+	_, err = logs.ErrorLog.Write([]byte("after close;\n"))
+	assert.ErrorIs(t, err, ErrClosed)
+
+	rotated, _ := os.ReadFile(path + ".1")
+	current, _ := os.ReadFile(path)
+	assert.Equal(t, "before;\n", string(rotated))
+	assert.Equal(t, "after;\n", string(current))
+}
@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// RequestIDKey - это имя поля записи лога с идентификатором запроса.
+const RequestIDKey = "request_id"
+
+// MaxRequestIDLen - это максимальная длина идентификатора запроса, принимаемого от клиента.
+const MaxRequestIDLen = 128
+
+// requestIDCtxKey - это ключ контекста, под которым хранится идентификатор запроса.
+type requestIDCtxKey struct{}
+
+// WithRequestID возвращает контекст, содержащий идентификатор запроса.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey{}, id)
+}
+
+// RequestID возвращает идентификатор запроса из контекста или пустую строку, если его нет.
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return id
+}
+
+// Detach возвращает новый контекст, не связанный с ctx, но сохраняющий идентификатор запроса.
+// Используется для фоновых операций, которые должны пережить исходный запрос.
+func Detach(ctx context.Context) context.Context {
+	if id := RequestID(ctx); id != "" {
+		return WithRequestID(context.Background(), id)
+	}
+	return context.Background()
+}
+
+// NewRequestID генерирует случайный идентификатор запроса.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ValidRequestID проверяет, что идентификатор запроса от клиента непустой, не слишком длинный
+// и состоит из печатных ASCII-символов, чтобы его можно было без экранирования писать в лог и заголовки ответа.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > MaxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
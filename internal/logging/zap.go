@@ -2,37 +2,81 @@
 package logging
 
 import (
+	"context"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"io"
 	"strings"
+	"time"
 )
 
+// Field - это поле структурированной записи лога.
+type Field = zap.Field
+
+// String возвращает строковое поле записи лога.
+func String(key, value string) Field {
+	return zap.String(key, value)
+}
+
+// Int возвращает целочисленное поле записи лога.
+func Int(key string, value int) Field {
+	return zap.Int(key, value)
+}
+
+// Duration возвращает поле записи лога с длительностью.
+func Duration(key string, value time.Duration) Field {
+	return zap.Duration(key, value)
+}
+
+// Err возвращает поле записи лога с ошибкой.
+func Err(err error) Field {
+	return zap.Error(err)
+}
+
+// Level - это уровень логирования, который можно менять во время работы.
+// Один Level можно разделить между несколькими логерами.
+type Level = zap.AtomicLevel
+
+// ParseLevel возвращает Level по названию: debug, info, warn, error, panic или fatal.
+func ParseLevel(name string) (Level, error) {
+	return zap.ParseAtomicLevel(strings.ToLower(name))
+}
+
 // EventLogger - это структура, предназначенная для логирования событий внутри приложения.
 type EventLogger struct {
 	logger *zap.Logger
 }
 
-// Log записывает в текстовый файл лога события, произошедшие в системе.
-func (c *EventLogger) Log(level string, msg string) {
+// Log записывает в текстовый файл лога событие, произошедшее в системе, вместе с дополнительными полями.
+// Если в ctx есть идентификатор запроса, он добавляется в запись полем request_id.
+func (c *EventLogger) Log(ctx context.Context, level string, msg string, fields ...Field) {
+	if id := RequestID(ctx); id != "" {
+		fields = append(fields, String(RequestIDKey, id))
+	}
+
 	switch strings.ToLower(level) {
 	case "debug":
-		c.logger.Log(zap.DebugLevel, msg)
+		c.logger.Log(zap.DebugLevel, msg, fields...)
 	case "info":
-		c.logger.Log(zap.InfoLevel, msg)
+		c.logger.Log(zap.InfoLevel, msg, fields...)
 	case "warn":
-		c.logger.Log(zap.WarnLevel, msg)
+		c.logger.Log(zap.WarnLevel, msg, fields...)
 	case "error":
-		c.logger.Log(zap.ErrorLevel, msg)
+		c.logger.Log(zap.ErrorLevel, msg, fields...)
 	case "panic":
-		c.logger.Log(zap.PanicLevel, msg)
+		c.logger.Log(zap.PanicLevel, msg, fields...)
 	case "fatal":
-		c.logger.Log(zap.FatalLevel, msg)
+		c.logger.Log(zap.FatalLevel, msg, fields...)
 	}
 }
 
-// NewLogger возвращает настроенный EventLogger.
+// NewLogger возвращает настроенный EventLogger, записывающий события всех уровней.
 func NewLogger(outputType string, file io.Writer) *EventLogger {
+	return NewLeveledLogger(outputType, file, zap.NewAtomicLevelAt(zap.DebugLevel))
+}
+
+// NewLeveledLogger возвращает настроенный EventLogger, записывающий события не ниже level.
+func NewLeveledLogger(outputType string, file io.Writer, level Level) *EventLogger {
 	var encType zapcore.Encoder
 
 	conf := zapcore.EncoderConfig{
@@ -56,7 +100,7 @@ func NewLogger(outputType string, file io.Writer) *EventLogger {
 		encType = zapcore.NewConsoleEncoder(conf)
 	}
 
-	core := zapcore.NewCore(encType, zapcore.AddSync(file), zapcore.DebugLevel)
+	core := zapcore.NewCore(encType, zapcore.AddSync(file), level)
 	logger := zap.New(core, zap.AddStacktrace(zapcore.PanicLevel))
 
 	return &EventLogger{logger: logger}
@@ -0,0 +1,44 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestEventLogger_Log(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger("json", &buf)
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	logger.Log(ctx, "error", "unable to save click", String("alias", "abc"), Err(errors.New("closed pool")))
+
+	var entry map[string]any
+	assert.Nil(t, json.Unmarshal([]byte(strings.TrimSuffix(buf.String(), ";\n")), &entry))
+	assert.Equal(t, "ERROR", entry["log_level"])
+	assert.Equal(t, "unable to save click", entry["message"])
+	assert.Equal(t, "abc", entry["alias"])
+	assert.Equal(t, "closed pool", entry["error"])
+	assert.Equal(t, "req-1", entry[RequestIDKey])
+
+	// без идентификатора запроса поле не добавляется.
+	buf.Reset()
+	logger.Log(context.Background(), "info", "started")
+	assert.NotContains(t, buf.String(), RequestIDKey)
+}
+
+func TestDetach(t *testing.T) {
+	ctx, cancel := context.WithCancel(WithRequestID(context.Background(), "req-2"))
+	cancel()
+
+	detached := Detach(ctx)
+	assert.Nil(t, detached.Err())
+	assert.Equal(t, "req-2", RequestID(detached))
+
+	assert.Equal(t, "", RequestID(Detach(context.Background())))
+	assert.NotEqual(t, NewRequestID(), NewRequestID())
+}
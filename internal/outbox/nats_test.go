@@ -0,0 +1,199 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// natsMessage - это сообщение, принятое тестовым сервером.
+type natsMessage struct {
+	subject string
+	data    []byte
+}
+
+// natsServer - это локальная замена сервера NATS, понимающая INFO, CONNECT, PUB и PING.
+type natsServer struct {
+	lis      net.Listener
+	user     string
+	password string
+
+	mu       sync.Mutex
+	messages []natsMessage
+	conns    int
+	// failPub - сколько следующих PUB отклонить ответом -ERR.
+	failPub int
+}
+
+func newNATSServer(t *testing.T, user, password string) *natsServer {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	s := &natsServer{lis: lis, user: user, password: password}
+	t.Cleanup(func() { _ = lis.Close() })
+
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns++
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *natsServer) url() string {
+	if s.user == "" {
+		return "nats://" + s.lis.Addr().String()
+	}
+	return fmt.Sprintf("nats://%s:%s@%s", s.user, s.password, s.lis.Addr())
+}
+
+func (s *natsServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	_, _ = fmt.Fprintf(conn, "INFO {\"server_id\":\"test\",\"auth_required\":%t}\r\n", s.user != "")
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd, args, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+
+		switch cmd {
+		case "CONNECT":
+			var opts struct {
+				User string `json:"user"`
+				Pass string `json:"pass"`
+			}
+			_ = json.Unmarshal([]byte(args), &opts)
+			if opts.User != s.user || opts.Pass != s.password {
+				_, _ = conn.Write([]byte("-ERR 'Authorization Violation'\r\n"))
+				return
+			}
+		case "PUB":
+			fields := strings.Fields(args)
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			data := make([]byte, size+2)
+			if _, err = io.ReadFull(r, data); err != nil {
+				return
+			}
+
+			s.mu.Lock()
+			fail := s.failPub > 0
+			if fail {
+				s.failPub--
+			} else {
+				s.messages = append(s.messages, natsMessage{subject: fields[0], data: data[:size]})
+			}
+			s.mu.Unlock()
+			if fail {
+				_, _ = conn.Write([]byte("-ERR 'Permissions Violation for Publish'\r\n"))
+				return
+			}
+		case "PING":
+			_, _ = conn.Write([]byte("PONG\r\n"))
+		}
+	}
+}
+
+func TestNATSPublish(t *testing.T) {
+	srv := newNATSServer(t, "outbox", "secret")
+	pub, err := NewNATS(srv.url(), "shortener", time.Second)
+	assert.NoError(t, err)
+	defer pub.Close()
+
+	events := []Event{
+		{ID: 1, Type: LinkCreated, Alias: "a", Data: json.RawMessage(`{"original":"https://example.com"}`)},
+		{ID: 2, Type: LinkDeleted, Alias: "a"},
+	}
+	assert.NoError(t, pub.Publish(context.Background(), events))
+	assert.NoError(t, pub.Publish(context.Background(), events[:1]))
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	assert.Equal(t, 1, srv.conns)
+	assert.Len(t, srv.messages, 3)
+	assert.Equal(t, "shortener.link.created", srv.messages[0].subject)
+	assert.Equal(t, "shortener.link.deleted", srv.messages[1].subject)
+
+	var got Event
+	assert.NoError(t, json.Unmarshal(srv.messages[0].data, &got))
+	assert.Equal(t, events[0].ID, got.ID)
+	assert.Equal(t, events[0].Alias, got.Alias)
+}
+
+func TestNATSErrors(t *testing.T) {
+	srv := newNATSServer(t, "outbox", "secret")
+
+	// неверный пароль.
+	wrong, err := NewNATS(strings.Replace(srv.url(), "secret", "wrong", 1), "shortener", time.Second)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, wrong.Publish(context.Background(), newEvents(1)), ErrNATS)
+
+	// сервер отклонил публикацию, следующая публикация подключается заново.
+	pub, err := NewNATS(srv.url(), "shortener", time.Second)
+	assert.NoError(t, err)
+	defer pub.Close()
+
+	srv.mu.Lock()
+	srv.failPub = 1
+	srv.mu.Unlock()
+	assert.ErrorIs(t, pub.Publish(context.Background(), newEvents(1)), ErrNATS)
+	assert.NoError(t, pub.Publish(context.Background(), newEvents(1)))
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	assert.Equal(t, 3, srv.conns)
+	assert.Len(t, srv.messages, 1)
+
+	// сервер недоступен.
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	_ = lis.Close()
+	down, err := NewNATS(addr, "shortener", time.Second)
+	assert.NoError(t, err)
+	assert.Error(t, down.Publish(context.Background(), newEvents(1)))
+}
+
+func TestNewNATS(t *testing.T) {
+	tests := []struct {
+		url  string
+		addr string
+		user string
+		err  bool
+	}{
+		{url: "nats://localhost:4222", addr: "localhost:4222"},
+		{url: "localhost", addr: "localhost:4222"},
+		{url: "nats://u:p@nats.local:5222", addr: "nats.local:5222", user: "u"},
+		{url: "nats://", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			n, err := NewNATS(tt.url, "shortener", time.Second)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.addr, n.addr)
+			assert.Equal(t, tt.user, n.user)
+		})
+	}
+}
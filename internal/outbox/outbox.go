@@ -0,0 +1,105 @@
+// Package outbox содержит ретрансляцию событий об изменении ссылок, записанных в таблицу outbox
+// в одной транзакции с самим изменением, во внешние системы.
+package outbox
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrUnknownPublisher = errors.New("unknown outbox publisher, should be none, stdout, file or nats")
+
+// Типы событий.
+const (
+	LinkCreated = "link.created"
+	LinkUpdated = "link.updated"
+	LinkDeleted = "link.deleted"
+)
+
+// Event - это событие об изменении ссылки.
+type Event struct {
+	ID    int64  `json:"id"`
+	Type  string `json:"type"`
+	Alias string `json:"alias"`
+	// Data содержит изменённые поля ссылки.
+	Data      json.RawMessage `json:"data,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Publisher - это внешняя система, в которую публикуются события.
+// Publish должен вернуть ошибку, если хотя бы одно событие не опубликовано: тогда пакет будет отправлен повторно.
+type Publisher interface {
+	Publish(ctx context.Context, events []Event) error
+	Close() error
+}
+
+// Discard - это Publisher, который отбрасывает события. Используется, когда поток изменений никому не нужен,
+// чтобы таблица outbox не росла.
+type Discard struct{}
+
+func (Discard) Publish(context.Context, []Event) error { return nil }
+
+func (Discard) Close() error { return nil }
+
+// Store - это SQL-база данных с таблицей outbox.
+type Store interface {
+	// RelayOutbox передаёт в fn не более n самых старых событий и удаляет их, если fn не вернула ошибку.
+	// Возвращает число переданных событий.
+	RelayOutbox(ctx context.Context, n int, fn func([]Event) error) (int, error)
+}
+
+type Logger interface {
+	Log(ctx context.Context, level string, msg string, fields ...logging.Field)
+}
+
+// Relay - это структура, которая периодически переносит события из таблицы outbox в Publisher.
+// Событие удаляется из таблицы только после успешной публикации, поэтому каждое событие
+// публикуется хотя бы один раз, а после сбоя публикации - возможно, повторно.
+type Relay struct {
+	store    Store
+	pub      Publisher
+	log      Logger
+	interval time.Duration
+	batch    int
+}
+
+// NewRelay возвращает Relay, переносящий события раз в interval пакетами по batch.
+func NewRelay(store Store, pub Publisher, log Logger, interval time.Duration, batch int) *Relay {
+	return &Relay{store: store, pub: pub, log: log, interval: interval, batch: batch}
+}
+
+// Run переносит события раз в интервал, пока не отменён ctx.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = r.Flush(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Flush переносит все накопленные события.
+func (r *Relay) Flush(ctx context.Context) error {
+	for {
+		n, err := r.store.RelayOutbox(ctx, r.batch, func(events []Event) error {
+			return r.pub.Publish(ctx, events)
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				r.log.Log(ctx, "error", "unable to relay outbox events", logging.Err(err))
+			}
+			return err
+		}
+		if n < r.batch {
+			return nil
+		}
+	}
+}
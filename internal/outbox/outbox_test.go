@@ -0,0 +1,143 @@
+package outbox
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// memoryOutbox - это таблица outbox в памяти.
+type memoryOutbox struct {
+	events []Event
+}
+
+func (m *memoryOutbox) RelayOutbox(_ context.Context, n int, fn func([]Event) error) (int, error) {
+	batch := m.events[:min(n, len(m.events))]
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	if err := fn(batch); err != nil {
+		return 0, err
+	}
+	m.events = m.events[len(batch):]
+	return len(batch), nil
+}
+
+// memoryPublisher запоминает опубликованные события.
+type memoryPublisher struct {
+	events []Event
+	err    error
+}
+
+func (m *memoryPublisher) Publish(_ context.Context, events []Event) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.events = append(m.events, events...)
+	return nil
+}
+
+func (m *memoryPublisher) Close() error { return nil }
+
+func newEvents(n int) []Event {
+	events := make([]Event, n)
+	for i := range events {
+		events[i] = Event{ID: int64(i + 1), Type: LinkCreated, Alias: "alias", CreatedAt: time.Now()}
+	}
+	return events
+}
+
+func TestRelayFlush(t *testing.T) {
+	log := logging.NewLogger("json", io.Discard)
+
+	tests := []struct {
+		name      string
+		events    int
+		pubErr    error
+		published int
+		left      int
+	}{
+		{name: "empty", events: 0},
+		{name: "one batch", events: 2, published: 2},
+		{name: "several batches", events: 7, published: 7},
+		{name: "exact batches", events: 6, published: 6},
+		{name: "publish failed", events: 4, pubErr: errors.New("down"), left: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memoryOutbox{events: newEvents(tt.events)}
+			pub := &memoryPublisher{err: tt.pubErr}
+
+			err := NewRelay(store, pub, log, time.Second, 3).Flush(context.Background())
+			assert.ErrorIs(t, err, tt.pubErr)
+			assert.Len(t, pub.events, tt.published)
+			assert.Len(t, store.events, tt.left)
+			for i, ev := range pub.events {
+				assert.Equal(t, int64(i+1), ev.ID)
+			}
+		})
+	}
+}
+
+func TestRelayRun(t *testing.T) {
+	store := &memoryOutbox{events: newEvents(5)}
+	pub := &memoryPublisher{}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		NewRelay(store, pub, logging.NewLogger("json", io.Discard), 10*time.Millisecond, 2).Run(ctx)
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+	assert.Len(t, pub.events, 5)
+	assert.Empty(t, store.events)
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	events := []Event{
+		{ID: 1, Type: LinkCreated, Alias: "a", Data: json.RawMessage(`{"original":"https://example.com"}`)},
+		{ID: 2, Type: LinkDeleted, Alias: "a"},
+	}
+	assert.NoError(t, w.Publish(context.Background(), events))
+	assert.NoError(t, w.Close())
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	assert.Len(t, lines, 2)
+
+	var got Event
+	assert.NoError(t, json.Unmarshal(lines[0], &got))
+	assert.Equal(t, events[0].Alias, got.Alias)
+	assert.JSONEq(t, string(events[0].Data), string(got.Data))
+	assert.NotContains(t, string(lines[1]), `"data"`)
+}
+
+func TestFileWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	for i := 0; i < 2; i++ {
+		w, err := NewFileWriter(path)
+		assert.NoError(t, err)
+		assert.NoError(t, w.Publish(context.Background(), newEvents(1)))
+		assert.NoError(t, w.Close())
+	}
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, bytes.Count(data, []byte("\n")))
+}
@@ -0,0 +1,54 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// Writer - это Publisher, который пишет события в поток построчно в формате JSON.
+type Writer struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+}
+
+// NewWriter возвращает Writer, пишущий в w. Если w реализует io.Closer, Close закрывает его.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w, enc: json.NewEncoder(w)}
+}
+
+// NewFileWriter возвращает Writer, дописывающий события в файл path. Пустой путь означает стандартный вывод.
+func NewFileWriter(path string) (*Writer, error) {
+	if path == "" {
+		return NewWriter(os.Stdout), nil
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriter(f), nil
+}
+
+func (w *Writer) Publish(_ context.Context, events []Event) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, ev := range events {
+		if err := w.enc.Encode(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close закрывает файл, в который пишутся события. Стандартный вывод не закрывается.
+func (w *Writer) Close() error {
+	if c, ok := w.w.(io.Closer); ok && w.w != os.Stdout {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// Package ratelimit ограничивает частоту запросов от одного клиента алгоритмом token bucket.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// idleTTL - время, после которого корзина неактивного клиента удаляется.
+const idleTTL = 10 * time.Minute
+
+// bucket - это корзина токенов одного клиента.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter - это ограничитель частоты запросов с отдельной корзиной токенов на каждый ключ.
+// Лимит можно менять во время работы, уже накопленные токены при этом сохраняются.
+type Limiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     int
+	buckets   map[string]*bucket
+	lastSweep time.Time
+
+	now func() time.Time
+}
+
+// NewLimiter возвращает Limiter, пропускающий rate запросов в секунду с пиком до burst запросов.
+// Нулевой rate отключает ограничение.
+func NewLimiter(rate float64, burst int) *Limiter {
+	return &Limiter{
+		rate:    rate,
+		burst:   burst,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// SetLimit меняет лимит для всех клиентов.
+func (l *Limiter) SetLimit(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.rate = rate
+	l.burst = burst
+	for _, b := range l.buckets {
+		if b.tokens > float64(burst) {
+			b.tokens = float64(burst)
+		}
+	}
+}
+
+// Allow сообщает, можно ли пропустить очередной запрос клиента key, и расходует на него токен.
+func (l *Limiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return true
+	}
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > float64(l.burst) {
+		b.tokens = float64(l.burst)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// sweep удаляет корзины клиентов, неактивных дольше idleTTL. Вызывается с захваченным l.mu.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleTTL {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= idleTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewLimiter(1, 2)
+	l.now = func() time.Time { return clock }
+
+	// пик.
+	assert.True(t, l.Allow("a"))
+	assert.True(t, l.Allow("a"))
+	assert.False(t, l.Allow("a"))
+
+	// у другого клиента своя корзина.
+	assert.True(t, l.Allow("b"))
+
+	// токены восстанавливаются со временем.
+	clock = clock.Add(time.Second)
+	assert.True(t, l.Allow("a"))
+	assert.False(t, l.Allow("a"))
+
+	// неактивные клиенты удаляются.
+	clock = clock.Add(idleTTL)
+	assert.True(t, l.Allow("a"))
+	assert.Len(t, l.buckets, 1)
+}
+
+func TestLimiter_SetLimit(t *testing.T) {
+	l := NewLimiter(0, 0)
+	for i := 0; i < 10; i++ {
+		assert.True(t, l.Allow("a"))
+	}
+
+	l.SetLimit(1, 1)
+	assert.True(t, l.Allow("a"))
+	assert.False(t, l.Allow("a"))
+
+	l.SetLimit(0, 0)
+	assert.True(t, l.Allow("a"))
+}
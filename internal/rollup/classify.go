@@ -0,0 +1,115 @@
+package rollup
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Значения измерений, которые не удалось определить.
+const (
+	Direct  = "direct"
+	Unknown = "unknown"
+	Other   = "other"
+)
+
+// Типы устройств.
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+)
+
+// botMarkers - это подстроки User-Agent роботов и консольных клиентов.
+var botMarkers = []string{"bot", "crawler", "spider", "slurp", "curl/", "wget/", "python-requests", "go-http-client"}
+
+// browsers - это подстроки User-Agent браузеров. Порядок важен: браузеры на Chromium упоминают Chrome и Safari,
+// а Chrome упоминает Safari, поэтому более частные признаки идут раньше.
+var browsers = []struct {
+	marker string
+	name   string
+}{
+	{"edg/", "Edge"},
+	{"edga/", "Edge"},
+	{"edgios/", "Edge"},
+	{"opr/", "Opera"},
+	{"opera", "Opera"},
+	{"samsungbrowser/", "Samsung Internet"},
+	{"yabrowser/", "Yandex"},
+	{"firefox/", "Firefox"},
+	{"fxios/", "Firefox"},
+	{"crios/", "Chrome"},
+	{"chrome/", "Chrome"},
+	{"safari/", "Safari"},
+}
+
+// ReferrerDomain возвращает домен источника перехода без "www." или Direct, если источника нет.
+func ReferrerDomain(referrer string) string {
+	if referrer == "" {
+		return Direct
+	}
+	u, err := url.Parse(referrer)
+	if err != nil || u.Hostname() == "" {
+		return Other
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// Country возвращает код страны ISO 3166-1 alpha-2 в верхнем регистре или Unknown,
+// если code не похож на код страны (например, "XX" и "T1", которыми CDN отмечают неизвестную страну и Tor).
+func Country(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 2 || code == "XX" {
+		return Unknown
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return Unknown
+		}
+	}
+	return code
+}
+
+// Device определяет тип устройства клиента по заголовку User-Agent.
+func Device(ua string) string {
+	ua = strings.ToLower(ua)
+	switch {
+	case ua == "":
+		return Unknown
+	case isBot(ua):
+		return DeviceBot
+	case strings.Contains(ua, "ipad"), strings.Contains(ua, "tablet"),
+		strings.Contains(ua, "android") && !strings.Contains(ua, "mobile"):
+		return DeviceTablet
+	case strings.Contains(ua, "mobi"), strings.Contains(ua, "iphone"), strings.Contains(ua, "ipod"):
+		return DeviceMobile
+	}
+	return DeviceDesktop
+}
+
+// Browser определяет браузер клиента по заголовку User-Agent.
+func Browser(ua string) string {
+	ua = strings.ToLower(ua)
+	switch {
+	case ua == "":
+		return Unknown
+	case isBot(ua):
+		return DeviceBot
+	}
+	for _, b := range browsers {
+		if strings.Contains(ua, b.marker) {
+			return b.name
+		}
+	}
+	return Other
+}
+
+// isBot сообщает, что User-Agent в нижнем регистре принадлежит роботу.
+func isBot(ua string) bool {
+	for _, m := range botMarkers {
+		if strings.Contains(ua, m) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+// Package rollup содержит почасовую свёртку записанных переходов по ссылкам и статистику переходов по интервалам,
+// которая строится по свёрнутым данным, а не по самим переходам.
+package rollup
+
+import (
+	"Darkyfun/UrlShortener/internal/clicks"
+	"Darkyfun/UrlShortener/internal/logging"
+	"context"
+	"time"
+)
+
+// Измерения, по которым переходы разбиваются при свёртке. Строки с пустым измерением содержат общее число переходов.
+const (
+	DimTotal    = ""
+	DimReferrer = "referrer"
+	DimCountry  = "country"
+	DimDevice   = "device"
+	DimBrowser  = "browser"
+)
+
+// Dimensions - это измерения разбивки переходов в порядке вывода.
+var Dimensions = []string{DimReferrer, DimCountry, DimDevice, DimBrowser}
+
+// Granularity - это шаг свёртки. Интервалы статистики собираются из часов, поэтому границы интервалов
+// в часовых поясах со смещением не на целое число часов сдвинуты на долю часа.
+const Granularity = time.Hour
+
+// Row - это число переходов по ссылке Alias за час, начинающийся в Bucket, со значением Value измерения Dimension.
+type Row struct {
+	Alias     string
+	Bucket    time.Time
+	Dimension string
+	Value     string
+	Clicks    int64
+}
+
+// Aggregate сворачивает переходы в почасовые строки: общее число переходов и разбивку по каждому измерению.
+func Aggregate(cs []clicks.Click) []Row {
+	type key struct {
+		alias     string
+		bucket    int64
+		dimension string
+		value     string
+	}
+
+	counts := make(map[key]int64)
+	var order []key
+	add := func(k key) {
+		if _, ok := counts[k]; !ok {
+			order = append(order, k)
+		}
+		counts[k]++
+	}
+
+	for _, c := range cs {
+		bucket := c.Time.UTC().Truncate(Granularity).Unix()
+		add(key{alias: c.Alias, bucket: bucket})
+		add(key{alias: c.Alias, bucket: bucket, dimension: DimReferrer, value: ReferrerDomain(c.Referrer)})
+		add(key{alias: c.Alias, bucket: bucket, dimension: DimCountry, value: Country(c.Country)})
+		add(key{alias: c.Alias, bucket: bucket, dimension: DimDevice, value: Device(c.UserAgent)})
+		add(key{alias: c.Alias, bucket: bucket, dimension: DimBrowser, value: Browser(c.UserAgent)})
+	}
+
+	rows := make([]Row, 0, len(order))
+	for _, k := range order {
+		rows = append(rows, Row{
+			Alias:     k.alias,
+			Bucket:    time.Unix(k.bucket, 0).UTC(),
+			Dimension: k.dimension,
+			Value:     k.value,
+			Clicks:    counts[k],
+		})
+	}
+	return rows
+}
+
+// Store - это SQL-база данных с записанными переходами и таблицей свёртки.
+type Store interface {
+	// RollupClicks передаёт в fn не более n самых старых ещё не свёрнутых переходов, прибавляет к свёртке
+	// возвращённые fn строки и отмечает переходы свёрнутыми в одной транзакции. Возвращает число переданных переходов.
+	RollupClicks(ctx context.Context, n int, fn func([]clicks.Click) []Row) (int, error)
+}
+
+type Logger interface {
+	Log(ctx context.Context, level string, msg string, fields ...logging.Field)
+}
+
+// Aggregator - это структура, которая периодически сворачивает новые записанные переходы.
+type Aggregator struct {
+	store    Store
+	log      Logger
+	interval time.Duration
+	batch    int
+}
+
+// NewAggregator возвращает Aggregator, сворачивающий переходы раз в interval пакетами по batch.
+func NewAggregator(store Store, log Logger, interval time.Duration, batch int) *Aggregator {
+	return &Aggregator{store: store, log: log, interval: interval, batch: batch}
+}
+
+// Run сворачивает переходы раз в интервал, пока не отменён ctx.
+func (a *Aggregator) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = a.Flush(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Flush сворачивает все накопившиеся переходы.
+func (a *Aggregator) Flush(ctx context.Context) error {
+	for {
+		n, err := a.store.RollupClicks(ctx, a.batch, Aggregate)
+		if err != nil {
+			if ctx.Err() == nil {
+				a.log.Log(ctx, "error", "unable to roll up clicks", logging.Err(err))
+			}
+			return err
+		}
+		if n < a.batch {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package rollup
+
+import (
+	"Darkyfun/UrlShortener/internal/clicks"
+	"Darkyfun/UrlShortener/internal/logging"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+const (
+	iphoneUA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 16_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.0 Mobile/15E148 Safari/604.1"
+	chromeUA  = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	edgeUA    = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0"
+	androidUA = "Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	botUA     = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		ua      string
+		device  string
+		browser string
+	}{
+		{ua: iphoneUA, device: DeviceMobile, browser: "Safari"},
+		{ua: chromeUA, device: DeviceDesktop, browser: "Chrome"},
+		{ua: edgeUA, device: DeviceDesktop, browser: "Edge"},
+		{ua: androidUA, device: DeviceTablet, browser: "Chrome"},
+		{ua: botUA, device: DeviceBot, browser: DeviceBot},
+		{ua: "", device: Unknown, browser: Unknown},
+		{ua: "Lynx/2.8.9", device: DeviceDesktop, browser: Other},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.device+" "+tt.browser, func(t *testing.T) {
+			assert.Equal(t, tt.device, Device(tt.ua))
+			assert.Equal(t, tt.browser, Browser(tt.ua))
+		})
+	}
+
+	assert.Equal(t, "google.com", ReferrerDomain("https://www.Google.com/search?q=x"))
+	assert.Equal(t, Direct, ReferrerDomain(""))
+	assert.Equal(t, Other, ReferrerDomain("android-app"))
+
+	assert.Equal(t, "DE", Country(" de "))
+	assert.Equal(t, Unknown, Country("XX"))
+	assert.Equal(t, Unknown, Country("T1"))
+	assert.Equal(t, Unknown, Country(""))
+}
+
+func TestAggregate(t *testing.T) {
+	hour := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	rows := Aggregate([]clicks.Click{
+		{Alias: "abc", Referrer: "https://t.co/x", UserAgent: iphoneUA, Country: "DE", Time: hour.Add(5 * time.Minute)},
+		{Alias: "abc", UserAgent: iphoneUA, Country: "DE", Time: hour.Add(50 * time.Minute)},
+		{Alias: "abc", UserAgent: chromeUA, Time: hour.Add(70 * time.Minute)},
+	})
+
+	counts := make(map[string]int64)
+	for _, r := range rows {
+		counts[r.Bucket.Format("15")+" "+r.Dimension+" "+r.Value] = r.Clicks
+	}
+	assert.Equal(t, map[string]int64{
+		"10  ": 2, "10 referrer t.co": 1, "10 referrer direct": 1, "10 country DE": 2, "10 device mobile": 2, "10 browser Safari": 2,
+		"11  ": 1, "11 referrer direct": 1, "11 country unknown": 1, "11 device desktop": 1, "11 browser Chrome": 1,
+	}, counts)
+}
+
+// memoryStore хранит переходы и свёртку в памяти.
+type memoryStore struct {
+	clicks []clicks.Click
+	rows   []Row
+	err    error
+}
+
+func (s *memoryStore) RollupClicks(_ context.Context, n int, fn func([]clicks.Click) []Row) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	if n > len(s.clicks) {
+		n = len(s.clicks)
+	}
+	s.rows = append(s.rows, fn(s.clicks[:n])...)
+	s.clicks = s.clicks[n:]
+	return n, nil
+}
+
+func TestAggregatorFlush(t *testing.T) {
+	now := time.Now()
+	store := &memoryStore{}
+	for i := 0; i < 5; i++ {
+		store.clicks = append(store.clicks, clicks.Click{Alias: "abc", Time: now})
+	}
+
+	a := NewAggregator(store, logging.NewLogger("json", io.Discard), time.Hour, 2)
+	assert.Nil(t, a.Flush(context.Background()))
+	assert.Empty(t, store.clicks)
+
+	var total int64
+	for _, r := range store.rows {
+		if r.Dimension == DimTotal {
+			total += r.Clicks
+		}
+	}
+	assert.Equal(t, int64(5), total)
+
+	store.err = errors.New("connection refused")
+	assert.Equal(t, store.err, a.Flush(context.Background()))
+}
+
+func TestAggregatorRun(t *testing.T) {
+	store := &memoryStore{clicks: []clicks.Click{{Alias: "abc", Time: time.Now()}}}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		NewAggregator(store, logging.NewLogger("json", io.Discard), 10*time.Millisecond, 100).Run(ctx)
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+	assert.Empty(t, store.clicks)
+	assert.NotEmpty(t, store.rows)
+}
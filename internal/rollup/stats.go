@@ -0,0 +1,227 @@
+package rollup
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	// база часовых поясов встроена, чтобы параметр tz работал и в образах без системной tzdata.
+	_ "time/tzdata"
+)
+
+var ErrInvalidInterval = errors.New("interval should be hour, day or week")
+var ErrInvalidPeriod = errors.New("invalid stats period")
+var ErrInvalidTimezone = errors.New("invalid time zone")
+
+// Интервалы статистики.
+const (
+	IntervalHour = "hour"
+	IntervalDay  = "day"
+	IntervalWeek = "week"
+)
+
+// Ограничения на период статистики.
+const (
+	DefaultPeriod = 30 * 24 * time.Hour
+	MaxPeriod     = 366 * 24 * time.Hour
+	MaxBuckets    = 2000
+)
+
+// Target - это ссылка или кампания, по переходам которой строится статистика. Заполнено ровно одно поле.
+type Target struct {
+	// Alias - ключ ссылки (см. links.Key).
+	Alias    string
+	Campaign string
+}
+
+// Bucket - это число переходов за интервал, начинающийся в Start.
+type Bucket struct {
+	Start  time.Time `json:"start"`
+	Clicks int64     `json:"clicks"`
+}
+
+// Count - это число переходов со значением Value одного измерения.
+type Count struct {
+	Value  string `json:"value"`
+	Clicks int64  `json:"clicks"`
+}
+
+// LinkCount - это число переходов по одной ссылке.
+type LinkCount struct {
+	Alias  string `json:"alias"`
+	Clicks int64  `json:"clicks"`
+}
+
+// Hours - это свёрнутые переходы по ссылкам цели за период, из которых строится статистика.
+// Links и Count считаются по всем ссылкам цели, остальные поля - по свёртке за период.
+type Hours struct {
+	Links int64
+	Count int64
+	// Aliases - ключи всех ссылок цели, по которым к Count прибавляются ещё не перенесённые из кэша переходы.
+	Aliases []string
+	// Series - почасовые числа переходов, часы без переходов пропущены.
+	Series []Bucket
+	// Breakdowns - самые частые значения каждого измерения за период.
+	Breakdowns map[string][]Count
+	Top        []LinkCount
+}
+
+// Stats - это статистика переходов по ссылке или кампании за период [From, To) по интервалам Interval в поясе TZ.
+// Links и Count (счётчик переходов, который ведётся и без их записи) считаются по всем ссылкам,
+// Total, Series, разбивки и Top - по записанным за период переходам.
+type Stats struct {
+	Alias     string      `json:"alias,omitempty"`
+	Campaign  string      `json:"campaign,omitempty"`
+	Interval  string      `json:"interval"`
+	TZ        string      `json:"tz"`
+	From      time.Time   `json:"from"`
+	To        time.Time   `json:"to"`
+	Links     int64       `json:"links"`
+	Count     int64       `json:"count"`
+	Total     int64       `json:"total"`
+	Series    []Bucket    `json:"series"`
+	Referrers []Count     `json:"referrers"`
+	Countries []Count     `json:"countries"`
+	Devices   []Count     `json:"devices"`
+	Browsers  []Count     `json:"browsers"`
+	Top       []LinkCount `json:"top,omitempty"`
+}
+
+// Query - это параметры статистики. Пустые поля получают значения по умолчанию в Normalize.
+type Query struct {
+	Interval string
+	From     time.Time
+	To       time.Time
+	TZ       string
+
+	loc *time.Location
+}
+
+// Normalize проверяет параметры статистики и выставляет значения по умолчанию: интервал - день, пояс - UTC,
+// период - DefaultPeriod до now. Границы периода расширяются до целых часов, потому что свёртка почасовая,
+// и по той же причине пояса, смещение которых за период хоть раз не равно целому числу часов, отклоняются.
+func (q Query) Normalize(now time.Time) (Query, error) {
+	var step time.Duration
+	switch q.Interval {
+	case "":
+		q.Interval = IntervalDay
+		step = 24 * time.Hour
+	case IntervalHour:
+		step = time.Hour
+	case IntervalDay:
+		step = 24 * time.Hour
+	case IntervalWeek:
+		step = 7 * 24 * time.Hour
+	default:
+		return q, ErrInvalidInterval
+	}
+
+	if q.TZ == "" {
+		q.TZ = "UTC"
+	}
+	loc, err := time.LoadLocation(q.TZ)
+	if err != nil {
+		return q, fmt.Errorf("%w: unknown zone %s", ErrInvalidTimezone, q.TZ)
+	}
+	q.loc = loc
+
+	if q.To.IsZero() {
+		q.To = now
+	}
+	if q.From.IsZero() {
+		q.From = q.To.Add(-DefaultPeriod)
+	}
+	q.From = q.From.UTC().Truncate(Granularity)
+	if to := q.To.UTC().Truncate(Granularity); to.Before(q.To) {
+		q.To = to.Add(Granularity)
+	} else {
+		q.To = to
+	}
+
+	switch {
+	case !q.From.Before(q.To):
+		return q, fmt.Errorf("%w: from should be before to", ErrInvalidPeriod)
+	case q.To.Sub(q.From) > MaxPeriod:
+		return q, fmt.Errorf("%w: the period should not exceed 366 days", ErrInvalidPeriod)
+	case q.To.Sub(q.From)/step > MaxBuckets:
+		return q, fmt.Errorf("%w: the period should not exceed %d intervals", ErrInvalidPeriod, MaxBuckets)
+	}
+
+	// свёртка почасовая, поэтому в поясе со смещением не на целое число часов интервалы не совпали бы с его сутками.
+	for t := q.From; ; t = t.Add(24 * time.Hour) {
+		if t.After(q.To) {
+			t = q.To
+		}
+		if _, offset := t.In(loc).Zone(); offset%int(Granularity/time.Second) != 0 {
+			return q, fmt.Errorf("%w: %s has an offset that is not a whole number of hours", ErrInvalidTimezone, q.TZ)
+		}
+		if t.Equal(q.To) {
+			break
+		}
+	}
+	return q, nil
+}
+
+// Stats строит статистику по свёртке h. Интервалы без переходов входят в ряд с нулём.
+// Query должен быть получен из Normalize.
+func (q Query) Stats(h Hours) Stats {
+	stats := Stats{
+		Interval:  q.Interval,
+		TZ:        q.TZ,
+		From:      q.From.In(q.loc),
+		To:        q.To.In(q.loc),
+		Links:     h.Links,
+		Count:     h.Count,
+		Series:    []Bucket{},
+		Referrers: breakdown(h, DimReferrer),
+		Countries: breakdown(h, DimCountry),
+		Devices:   breakdown(h, DimDevice),
+		Browsers:  breakdown(h, DimBrowser),
+		Top:       h.Top,
+	}
+
+	index := make(map[int64]int)
+	for t := q.start(q.From); t.Before(q.To); t = q.next(t) {
+		index[t.Unix()] = len(stats.Series)
+		stats.Series = append(stats.Series, Bucket{Start: t})
+	}
+	for _, b := range h.Series {
+		if i, ok := index[q.start(b.Start).Unix()]; ok {
+			stats.Series[i].Clicks += b.Clicks
+			stats.Total += b.Clicks
+		}
+	}
+
+	return stats
+}
+
+// start возвращает начало интервала, в который попадает t. Недели начинаются с понедельника.
+func (q Query) start(t time.Time) time.Time {
+	t = t.In(q.loc)
+	y, m, d := t.Date()
+	switch q.Interval {
+	case IntervalHour:
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, q.loc)
+	case IntervalWeek:
+		return time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, q.loc)
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, q.loc)
+}
+
+// next возвращает начало интервала, следующего за интервалом, начинающимся в t.
+func (q Query) next(t time.Time) time.Time {
+	switch q.Interval {
+	case IntervalHour:
+		return t.Add(time.Hour)
+	case IntervalWeek:
+		return t.AddDate(0, 0, 7)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// breakdown возвращает значения измерения dim, пустой список, если их нет.
+func breakdown(h Hours, dim string) []Count {
+	if counts := h.Breakdowns[dim]; counts != nil {
+		return counts
+	}
+	return []Count{}
+}
@@ -0,0 +1,77 @@
+package rollup
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestQuery_Normalize(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 30, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name string
+		q    Query
+		from time.Time
+		to   time.Time
+		err  error
+	}{
+		{name: "defaults", q: Query{}, from: now.Add(-DefaultPeriod).Truncate(time.Hour), to: now.Truncate(time.Hour).Add(time.Hour)},
+		{name: "period", q: Query{Interval: IntervalHour, From: day(1), To: day(2), TZ: "Asia/Tokyo"}, from: day(1), to: day(2)},
+		{name: "invalid interval", q: Query{Interval: "month"}, err: ErrInvalidInterval},
+		{name: "invalid tz", q: Query{TZ: "Mars/Olympus"}, err: ErrInvalidTimezone},
+		{name: "half hour tz", q: Query{TZ: "Asia/Kolkata"}, err: ErrInvalidTimezone},
+		{name: "quarter hour tz", q: Query{TZ: "Asia/Kathmandu"}, err: ErrInvalidTimezone},
+		{name: "half hour dst", q: Query{From: day(1).AddDate(0, -6, 0), To: day(1), TZ: "Australia/Lord_Howe"}, err: ErrInvalidTimezone},
+		{name: "reversed period", q: Query{From: day(2), To: day(1)}, err: ErrInvalidPeriod},
+		{name: "long period", q: Query{From: day(1).AddDate(-2, 0, 0), To: day(1)}, err: ErrInvalidPeriod},
+		{name: "too many hours", q: Query{Interval: IntervalHour, From: day(1).AddDate(0, -6, 0), To: day(1)}, err: ErrInvalidPeriod},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := tt.q.Normalize(now)
+			assert.ErrorIs(t, err, tt.err)
+			if tt.err == nil {
+				assert.Equal(t, tt.from, q.From)
+				assert.Equal(t, tt.to, q.To)
+			}
+		})
+	}
+}
+
+func TestQuery_Stats(t *testing.T) {
+	hours := Hours{
+		Links: 2,
+		Count: 10,
+		Series: []Bucket{
+			// 23:00 UTC 10 марта - это уже 11 марта в Берлине.
+			{Start: time.Date(2024, 3, 10, 22, 0, 0, 0, time.UTC), Clicks: 1},
+			{Start: time.Date(2024, 3, 10, 23, 0, 0, 0, time.UTC), Clicks: 2},
+			{Start: time.Date(2024, 3, 12, 8, 0, 0, 0, time.UTC), Clicks: 3},
+		},
+		Breakdowns: map[string][]Count{DimCountry: {{Value: "DE", Clicks: 6}}},
+	}
+
+	q, err := Query{Interval: IntervalDay, From: time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC),
+		To: time.Date(2024, 3, 13, 0, 0, 0, 0, time.UTC), TZ: "Europe/Berlin"}.Normalize(time.Now())
+	assert.Nil(t, err)
+
+	stats := q.Stats(hours)
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	assert.Equal(t, int64(6), stats.Total)
+	assert.Equal(t, "Europe/Berlin", stats.TZ)
+	assert.Len(t, stats.Series, 4)
+	assert.Equal(t, time.Date(2024, 3, 10, 0, 0, 0, 0, berlin).Unix(), stats.Series[0].Start.Unix())
+	assert.Equal(t, []int64{1, 2, 3, 0}, []int64{stats.Series[0].Clicks, stats.Series[1].Clicks, stats.Series[2].Clicks, stats.Series[3].Clicks})
+	assert.Equal(t, []Count{{Value: "DE", Clicks: 6}}, stats.Countries)
+	assert.Equal(t, []Count{}, stats.Referrers)
+
+	// недели начинаются с понедельника: 10 марта 2024 года - воскресенье.
+	q.Interval = IntervalWeek
+	stats = q.Stats(hours)
+	assert.Len(t, stats.Series, 2)
+	assert.Equal(t, time.Monday, stats.Series[1].Start.Weekday())
+	assert.Equal(t, []int64{1, 5}, []int64{stats.Series[0].Clicks, stats.Series[1].Clicks})
+}
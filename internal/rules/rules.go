@@ -0,0 +1,190 @@
+// Package rules содержит правила условного перенаправления по платформе, языку и времени суток.
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrNoDestination = errors.New("rule has no destination")
+var ErrNoCondition = errors.New("rule has no condition")
+var ErrInvalidPlatform = errors.New("unknown platform")
+var ErrInvalidTime = errors.New("invalid time window")
+var ErrInvalidTimezone = errors.New("unknown timezone")
+
+// Платформы, которые распознаются по заголовку User-Agent.
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformWindows = "windows"
+	PlatformMacOS   = "macos"
+	PlatformLinux   = "linux"
+)
+
+// Rule - это одно правило перенаправления. Правило срабатывает, если выполнены все заданные в нём условия.
+type Rule struct {
+	Platform    string `json:"platform,omitempty"`
+	Language    string `json:"language,omitempty"`
+	From        string `json:"from,omitempty"`
+	To          string `json:"to,omitempty"`
+	Timezone    string `json:"tz,omitempty"`
+	Destination string `json:"destination"`
+
+	from, to int
+	loc      *time.Location
+}
+
+// Set - это упорядоченный набор правил. Срабатывает первое подходящее правило.
+type Set []Rule
+
+// Compile проверяет правила и приводит их к виду, пригодному для быстрой проверки запросов.
+func Compile(rules []Rule) (Set, error) {
+	set := make(Set, 0, len(rules))
+	for _, r := range rules {
+		if r.Destination == "" {
+			return nil, ErrNoDestination
+		}
+		if r.Platform == "" && r.Language == "" && r.From == "" && r.To == "" {
+			return nil, ErrNoCondition
+		}
+
+		r.Platform = strings.ToLower(r.Platform)
+		switch r.Platform {
+		case "", PlatformIOS, PlatformAndroid, PlatformWindows, PlatformMacOS, PlatformLinux:
+		default:
+			return nil, ErrInvalidPlatform
+		}
+		r.Language = strings.ToLower(r.Language)
+
+		r.from, r.to = -1, -1
+		if r.From != "" || r.To != "" {
+			from, err := parseClock(r.From)
+			if err != nil {
+				return nil, err
+			}
+			to, err := parseClock(r.To)
+			if err != nil {
+				return nil, err
+			}
+			r.from, r.to = from, to
+		}
+
+		r.loc = time.UTC
+		if r.Timezone != "" {
+			loc, err := time.LoadLocation(r.Timezone)
+			if err != nil {
+				return nil, ErrInvalidTimezone
+			}
+			r.loc = loc
+		}
+
+		set = append(set, r)
+	}
+
+	return set, nil
+}
+
+// UnmarshalJSON декодирует набор правил и сразу компилирует его.
+func (s *Set) UnmarshalJSON(data []byte) error {
+	var raw []Rule
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	set, err := Compile(raw)
+	if err != nil {
+		return err
+	}
+	*s = set
+	return nil
+}
+
+// Match возвращает адрес назначения первого подходящего правила для клиента
+// с указанными заголовками User-Agent и Accept-Language.
+// Если ни одно правило не подошло, возвращается пустая строка.
+func (s Set) Match(userAgent, acceptLanguage string, now time.Time) string {
+	if len(s) == 0 {
+		return ""
+	}
+
+	platform := Platform(userAgent)
+	languages := languageTags(acceptLanguage)
+
+	for _, rule := range s {
+		if rule.Platform != "" && rule.Platform != platform {
+			continue
+		}
+		if rule.Language != "" && !hasLanguage(languages, rule.Language) {
+			continue
+		}
+		if rule.from >= 0 && !rule.inWindow(now) {
+			continue
+		}
+		return rule.Destination
+	}
+
+	return ""
+}
+
+// inWindow проверяет, попадает ли время now в окно правила. Окно может переходить через полночь.
+func (r Rule) inWindow(now time.Time) bool {
+	t := now.In(r.loc)
+	m := t.Hour()*60 + t.Minute()
+
+	if r.from <= r.to {
+		return m >= r.from && m < r.to
+	}
+	return m >= r.from || m < r.to
+}
+
+// Platform определяет платформу клиента по заголовку User-Agent.
+func Platform(ua string) string {
+	ua = strings.ToLower(ua)
+	switch {
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"):
+		return PlatformIOS
+	case strings.Contains(ua, "android"):
+		return PlatformAndroid
+	case strings.Contains(ua, "windows"):
+		return PlatformWindows
+	case strings.Contains(ua, "macintosh"), strings.Contains(ua, "mac os x"):
+		return PlatformMacOS
+	case strings.Contains(ua, "linux"):
+		return PlatformLinux
+	}
+	return ""
+}
+
+// languageTags разбирает заголовок Accept-Language в список языковых тегов без весов.
+func languageTags(header string) []string {
+	var tags []string
+	for _, part := range strings.Split(header, ",") {
+		tag, _, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// hasLanguage проверяет, есть ли среди тегов язык с указанным префиксом (например, "en" для "en-US").
+func hasLanguage(tags []string, prefix string) bool {
+	for _, tag := range tags {
+		if tag == prefix || strings.HasPrefix(tag, prefix+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock переводит время в формате "HH:MM" в количество минут от начала суток.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, ErrInvalidTime
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
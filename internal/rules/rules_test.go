@@ -0,0 +1,81 @@
+package rules
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+const iphoneUA = "Mozilla/5.0 (iPhone; CPU iPhone OS 16_0 like Mac OS X) AppleWebKit/605.1.15"
+const androidUA = "Mozilla/5.0 (Linux; Android 13; Pixel 7) AppleWebKit/537.36"
+const windowsUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36"
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+		err   error
+	}{
+		{name: "valid", rules: []Rule{{Platform: "iOS", Destination: "https://apple.com"}}, err: nil},
+		{name: "no destination", rules: []Rule{{Platform: "ios"}}, err: ErrNoDestination},
+		{name: "no condition", rules: []Rule{{Destination: "https://apple.com"}}, err: ErrNoCondition},
+		{name: "unknown platform", rules: []Rule{{Platform: "symbian", Destination: "https://nokia.com"}}, err: ErrInvalidPlatform},
+		{name: "half window", rules: []Rule{{From: "09:00", Destination: "https://day.com"}}, err: ErrInvalidTime},
+		{name: "bad clock", rules: []Rule{{From: "9am", To: "18:00", Destination: "https://day.com"}}, err: ErrInvalidTime},
+		{name: "bad timezone", rules: []Rule{{From: "09:00", To: "18:00", Timezone: "Mars/Base", Destination: "https://day.com"}}, err: ErrInvalidTimezone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.rules)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
+
+func TestSet_Match(t *testing.T) {
+	set, err := Compile([]Rule{
+		{Platform: "ios", Destination: "https://apps.apple.com"},
+		{Platform: "android", Destination: "https://play.google.com"},
+		{Language: "de", Destination: "https://example.de"},
+		{From: "22:00", To: "06:00", Destination: "https://night.example.com"},
+	})
+	assert.Nil(t, err)
+
+	noon := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	midnight := time.Date(2023, 9, 1, 23, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		ua   string
+		lang string
+		now  time.Time
+		exp  string
+	}{
+		{name: "ios", ua: iphoneUA, now: noon, exp: "https://apps.apple.com"},
+		{name: "android", ua: androidUA, now: noon, exp: "https://play.google.com"},
+		{name: "language prefix", ua: windowsUA, lang: "de-AT,de;q=0.9,en;q=0.8", now: noon, exp: "https://example.de"},
+		{name: "language is not a prefix of another one", ua: windowsUA, lang: "dek", now: noon, exp: ""},
+		{name: "night window", ua: windowsUA, lang: "en-US", now: midnight, exp: "https://night.example.com"},
+		{name: "fallback", ua: windowsUA, lang: "en-US", now: noon, exp: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.exp, set.Match(tt.ua, tt.lang, tt.now))
+		})
+	}
+}
+
+func TestSet_UnmarshalJSON(t *testing.T) {
+	var set Set
+	err := set.UnmarshalJSON([]byte(`[{"from":"09:00","to":"18:00","tz":"Europe/Moscow","destination":"https://day.example.com"}]`))
+	assert.Nil(t, err)
+
+	// 07:00 UTC - это 10:00 по Москве.
+	assert.Equal(t, "https://day.example.com", set.Match("", "", time.Date(2023, 9, 1, 7, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "", set.Match("", "", time.Date(2023, 9, 1, 16, 0, 0, 0, time.UTC)))
+
+	err = set.UnmarshalJSON([]byte(`[{"platform":"ios"}]`))
+	assert.Equal(t, ErrNoDestination, err)
+}
@@ -0,0 +1,75 @@
+package grpcapi
+
+import (
+	"Darkyfun/UrlShortener/internal/server/grpcapi/pb"
+	"Darkyfun/UrlShortener/internal/storage"
+	"Darkyfun/UrlShortener/internal/workspace"
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"strings"
+)
+
+// apiKeyMetadata - это ключ метаданных gRPC, в котором можно передать ключ API вместо authorization: Bearer.
+const apiKeyMetadata = "x-api-key"
+
+// publicMethods - это вызовы, которые, как и перенаправление /redirect/:alias, не требуют ключа API и не ограничиваются
+// рабочим пространством: псевдоним разрешается одинаково для любого клиента, из кэша и из SQL-базы данных.
+var publicMethods = map[string]bool{
+	pb.Shortener_Resolve_FullMethodName: true,
+}
+
+// KeyStore находит рабочее пространство по хэшу ключа API.
+type KeyStore interface {
+	WorkspaceByKey(ctx context.Context, keyHash []byte) (int64, error)
+}
+
+// AuthInterceptor возвращает интерсептор, который, как и HTTP middleware Authenticate, определяет рабочее пространство
+// по ключу API из метаданных authorization: Bearer или x-api-key и ограничивает им вызов. Вызов без ключа попадает
+// в workspace.Default, если require не выставлен, иначе, как и вызов с неизвестным ключом, получает Unauthenticated.
+// Вызовы из publicMethods пропускаются без проверки ключа и без ограничения рабочим пространством.
+func AuthInterceptor(keys KeyStore, require bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if publicMethods[info.FullMethod] {
+			return handler(workspace.Unscoped(ctx), req)
+		}
+
+		key := apiKey(ctx)
+
+		id := workspace.Default
+		switch {
+		case key != "":
+			var err error
+			id, err = keys.WorkspaceByKey(ctx, workspace.HashKey(key))
+			if errors.Is(err, storage.ErrNotFound) {
+				err = workspace.ErrUnauthorized
+			}
+			if err != nil {
+				return nil, toStatus(err)
+			}
+		case require:
+			return nil, toStatus(workspace.ErrUnauthorized)
+		}
+
+		return handler(workspace.WithID(ctx, id), req)
+	}
+}
+
+// apiKey возвращает ключ API из метаданных вызова или пустую строку.
+func apiKey(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get("authorization"); len(values) > 0 {
+		scheme, token, ok := strings.Cut(values[0], " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	if values := md.Get(apiKeyMetadata); len(values) > 0 {
+		return strings.TrimSpace(values[0])
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+package grpcapi
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/server/grpcapi/pb"
+	"Darkyfun/UrlShortener/internal/service"
+	"Darkyfun/UrlShortener/internal/service/servicetest"
+	"Darkyfun/UrlShortener/internal/storage"
+	"Darkyfun/UrlShortener/internal/workspace"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+	"testing"
+)
+
+// stubKeys знает только ключ "sk_good" рабочего пространства 7.
+type stubKeys struct{}
+
+func (stubKeys) WorkspaceByKey(_ context.Context, keyHash []byte) (int64, error) {
+	if bytes.Equal(keyHash, workspace.HashKey("sk_good")) {
+		return 7, nil
+	}
+	return 0, fmt.Errorf("%w: no rows", storage.ErrNotFound)
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	tests := []struct {
+		name    string
+		md      metadata.MD
+		require bool
+		scope   int64
+		code    codes.Code
+	}{
+		{name: "bearer", md: metadata.Pairs("authorization", "Bearer sk_good"), scope: 7},
+		{name: "api key", md: metadata.Pairs(apiKeyMetadata, "sk_good"), require: true, scope: 7},
+		{name: "no key", md: metadata.MD{}, scope: workspace.Default},
+		{name: "required", md: metadata.MD{}, require: true, code: codes.Unauthenticated},
+		{name: "unknown key", md: metadata.Pairs(apiKeyMetadata, "sk_bad"), code: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var scope *int64
+			handler := func(ctx context.Context, _ any) (any, error) {
+				scope = workspace.Scope(ctx)
+				return nil, nil
+			}
+
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+			_, err := AuthInterceptor(stubKeys{}, tt.require)(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+			assert.Equal(t, tt.code, status.Code(err))
+			if tt.code == codes.OK {
+				assert.Equal(t, tt.scope, *scope)
+			}
+		})
+	}
+
+	assert.Equal(t, codes.ResourceExhausted, status.Code(toStatus(workspace.ErrQuotaExceeded)))
+}
+
+func TestAuthInterceptor_Resolve(t *testing.T) {
+	store := servicetest.NewStore()
+	logger := logging.NewLogger("json", io.Discard)
+	owner := service.NewShortener(servicetest.NewCache(), store, logger, servicetest.Recorder{Store: store}, ":5050")
+
+	// ссылка рабочего пространства 5 создаётся вместе с записью кэша.
+	link, err := owner.Shorten(workspace.WithID(context.Background(), 5), service.ShortenRequest{Url: "https://www.google.com"})
+	assert.Nil(t, err)
+
+	// первый сервер делит кэш с создавшим ссылку сервисом, у второго кэш пуст и ссылка читается из базы.
+	paths := map[string]*service.Shortener{
+		"cache hit":  owner,
+		"cache miss": service.NewShortener(servicetest.NewCache(), store, logger, servicetest.Recorder{Store: store}, ":5050"),
+	}
+	for name, svc := range paths {
+		t.Run(name, func(t *testing.T) {
+			client := serve(t, svc, grpc.UnaryInterceptor(AuthInterceptor(stubKeys{}, true)))
+
+			// ключ другого рабочего пространства и отсутствие ключа разрешают псевдоним одинаково.
+			for _, md := range []metadata.MD{metadata.Pairs(apiKeyMetadata, "sk_good"), {}} {
+				ctx := metadata.NewOutgoingContext(context.Background(), md)
+				res, err := client.Resolve(ctx, &pb.ResolveRequest{Alias: link.Alias})
+				assert.Nil(t, err)
+				assert.Equal(t, "https://www.google.com", res.GetDestination())
+			}
+
+			_, err = client.GetStats(context.Background(), &pb.GetStatsRequest{Alias: link.Alias})
+			assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package grpcapi
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// requestIDMetadata - это ключ метаданных gRPC, в котором передаётся идентификатор запроса.
+const requestIDMetadata = "x-request-id"
+
+// RequestIDInterceptor принимает идентификатор запроса из метаданных x-request-id или генерирует новый,
+// если метаданных нет или идентификатор некорректен по тем же правилам, что и в HTTP middleware RequestID.
+// Идентификатор кладётся в контекст вызова и возвращается клиенту в заголовке ответа.
+func RequestIDInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIDMetadata); len(values) > 0 && logging.ValidRequestID(values[0]) {
+			id = values[0]
+		}
+	}
+	if id == "" {
+		id = logging.NewRequestID()
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, id))
+	return handler(logging.WithRequestID(ctx, id), req)
+}
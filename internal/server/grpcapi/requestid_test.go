@@ -0,0 +1,50 @@
+package grpcapi
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDInterceptor(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       string
+		accepted bool
+	}{
+		{name: "accepted", id: "client-id-1", accepted: true},
+		{name: "generated", id: "", accepted: false},
+		{name: "too long", id: strings.Repeat("a", logging.MaxRequestIDLen+1), accepted: false},
+		{name: "not printable", id: "id\nforged: entry", accepted: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fromCtx string
+			handler := func(ctx context.Context, _ any) (any, error) {
+				fromCtx = logging.RequestID(ctx)
+				return nil, nil
+			}
+
+			md := metadata.MD{}
+			if tt.id != "" {
+				md = metadata.Pairs(requestIDMetadata, tt.id)
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+			_, err := RequestIDInterceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+			assert.Nil(t, err)
+
+			assert.NotEmpty(t, fromCtx)
+			if tt.accepted {
+				assert.Equal(t, tt.id, fromCtx)
+			} else {
+				assert.NotEqual(t, tt.id, fromCtx)
+				assert.True(t, logging.ValidRequestID(fromCtx))
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+// Package grpcapi содержит gRPC-транспорт сокращателя ссылок поверх service.Shortener.
+package grpcapi
+
+//go:generate protoc -I ../../../api/proto --go_out=pb --go_opt=paths=source_relative --go-grpc_out=pb --go-grpc_opt=paths=source_relative shortener.proto
+
+import (
+	"Darkyfun/UrlShortener/internal/links"
+	"Darkyfun/UrlShortener/internal/rules"
+	"Darkyfun/UrlShortener/internal/server/grpcapi/pb"
+	"Darkyfun/UrlShortener/internal/service"
+	"Darkyfun/UrlShortener/internal/split"
+	"Darkyfun/UrlShortener/internal/storage"
+	"Darkyfun/UrlShortener/internal/workspace"
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"time"
+)
+
+// maxBatch - это максимальное количество ссылок в одном вызове BatchShorten.
+const maxBatch = 1000
+
+// Server - это реализация gRPC-сервиса Shortener.
+type Server struct {
+	pb.UnimplementedShortenerServer
+	svc *service.Shortener
+}
+
+// NewServer возвращает gRPC-сервис, использующий svc.
+func NewServer(svc *service.Shortener) *Server {
+	return &Server{svc: svc}
+}
+
+// Register регистрирует сервис Shortener на gRPC-сервере.
+func Register(g *grpc.Server, svc *service.Shortener) {
+	pb.RegisterShortenerServer(g, NewServer(svc))
+}
+
+// Shorten создаёт короткую ссылку.
+func (s *Server) Shorten(ctx context.Context, in *pb.ShortenRequest) (*pb.ShortenResponse, error) {
+	req, err := shortenRequest(in)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	link, err := s.svc.Shorten(ctx, req)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &pb.ShortenResponse{Alias: link.Alias, ShortUrl: link.ShortUrl}, nil
+}
+
+// BatchShorten создаёт короткие ссылки для пакета запросов.
+func (s *Server) BatchShorten(ctx context.Context, in *pb.BatchShortenRequest) (*pb.BatchShortenResponse, error) {
+	if len(in.GetItems()) > maxBatch {
+		return nil, status.Errorf(codes.InvalidArgument, "batch should contain at most %d items", maxBatch)
+	}
+
+	results := make([]*pb.BatchShortenResult, len(in.GetItems()))
+	reqs := make([]service.ShortenRequest, 0, len(in.GetItems()))
+	index := make([]int, 0, len(in.GetItems()))
+
+	for i, item := range in.GetItems() {
+		req, err := shortenRequest(item)
+		if err != nil {
+			results[i] = &pb.BatchShortenResult{Error: err.Error()}
+			continue
+		}
+		reqs = append(reqs, req)
+		index = append(index, i)
+	}
+
+	for j, res := range s.svc.BatchShorten(ctx, reqs) {
+		if res.Err != nil {
+			results[index[j]] = &pb.BatchShortenResult{Error: res.Err.Error()}
+			continue
+		}
+		results[index[j]] = &pb.BatchShortenResult{Link: &pb.ShortenResponse{Alias: res.Link.Alias, ShortUrl: res.Link.ShortUrl}}
+	}
+
+	return &pb.BatchShortenResponse{Results: results}, nil
+}
+
+// Resolve возвращает адрес, на который ведёт псевдоним. Как и /redirect/:alias, вызов не ограничен рабочим пространством.
+func (s *Server) Resolve(ctx context.Context, in *pb.ResolveRequest) (*pb.ResolveResponse, error) {
+	if in.GetAlias() == "" {
+		return nil, toStatus(service.ErrInvalidRequest)
+	}
+
+	res, err := s.svc.Resolve(ctx, in.GetAlias(), service.Visitor{
+		UserAgent:      in.GetUserAgent(),
+		AcceptLanguage: in.GetAcceptLanguage(),
+		Referrer:       in.GetReferrer(),
+		Variant:        in.GetVariant(),
+	}, in.GetRecordClick())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &pb.ResolveResponse{Destination: res.Destination, Variant: res.Variant, Sticky: res.Sticky}, nil
+}
+
+// GetStats возвращает сводную статистику переходов по псевдониму.
+func (s *Server) GetStats(ctx context.Context, in *pb.GetStatsRequest) (*pb.GetStatsResponse, error) {
+	if in.GetAlias() == "" {
+		return nil, toStatus(service.ErrInvalidRequest)
+	}
+
+	stats, err := s.svc.Stats(ctx, in.GetAlias())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	res := &pb.GetStatsResponse{Alias: stats.Alias, Total: stats.Total, Variants: stats.Variants, Count: stats.Count}
+	if !stats.First.IsZero() {
+		res.First = timestamppb.New(stats.First)
+	}
+	if !stats.Last.IsZero() {
+		res.Last = timestamppb.New(stats.Last)
+	}
+	return res, nil
+}
+
+// shortenRequest переводит gRPC-запрос в проверенный запрос сервиса.
+func shortenRequest(in *pb.ShortenRequest) (service.ShortenRequest, error) {
+	rr := make([]rules.Rule, len(in.GetRules()))
+	for i, r := range in.GetRules() {
+		rr[i] = rules.Rule{
+			Platform:    r.GetPlatform(),
+			Language:    r.GetLanguage(),
+			From:        r.GetFrom(),
+			To:          r.GetTo(),
+			Timezone:    r.GetTz(),
+			Destination: r.GetDestination(),
+		}
+	}
+
+	var ab *split.Split
+	if in.GetSplit() != nil {
+		ab = &split.Split{Sticky: in.GetSplit().GetSticky()}
+		for _, v := range in.GetSplit().GetVariants() {
+			ab.Variants = append(ab.Variants, split.Variant{Name: v.GetName(), Url: v.GetUrl(), Weight: int(v.GetWeight())})
+		}
+	}
+
+	meta := links.Meta{
+		Title:       in.GetTitle(),
+		Description: in.GetDescription(),
+		Tags:        in.GetTags(),
+		Owner:       in.GetOwner(),
+		Campaign:    in.GetCampaign(),
+	}
+
+	req, err := service.NewShortenRequest(in.GetUrl(), rr, ab, meta)
+	if err == nil && in.GetDomain() != "" {
+		req, err = req.WithDomain(in.GetDomain())
+	}
+	if err != nil {
+		return service.ShortenRequest{}, err
+	}
+
+	switch {
+	case in.GetExpiresAt() != nil && in.GetTtl() != nil:
+		return service.ShortenRequest{}, fmt.Errorf("%w: expires_at and ttl are mutually exclusive", service.ErrInvalidExpiry)
+	case in.GetExpiresAt() != nil:
+		return req.WithExpiry(in.GetExpiresAt().AsTime())
+	case in.GetTtl() != nil:
+		return req.WithExpiry(time.Now().Add(in.GetTtl().AsDuration()))
+	}
+	return req, nil
+}
+
+// toStatus переводит ошибку сервиса или хранилища в gRPC-статус.
+// Для недоступного или не ответившего вовремя хранилища текст исходной ошибки не раскрывается.
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidRequest), errors.Is(err, service.ErrInvalidUrl),
+		errors.Is(err, service.ErrInvalidRules), errors.Is(err, service.ErrInvalidSplit), errors.Is(err, service.ErrInvalidMeta),
+		errors.Is(err, service.ErrInvalidExpiry):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, links.ErrInvalidDomain):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrBlockedUrl):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, workspace.ErrQuotaExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, workspace.ErrUnauthorized):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, service.ErrUnknownDomain):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, storage.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, storage.ErrConflict):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, storage.ErrUnavailable):
+		return status.Error(codes.Unavailable, storage.ErrUnavailable.Error())
+	case errors.Is(err, storage.ErrTimeout):
+		return status.Error(codes.DeadlineExceeded, storage.ErrTimeout.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,127 @@
+package grpcapi
+
+import (
+	"Darkyfun/UrlShortener/internal/links"
+	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/server/grpcapi/pb"
+	"Darkyfun/UrlShortener/internal/service"
+	"Darkyfun/UrlShortener/internal/service/servicetest"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T) pb.ShortenerClient {
+	store := servicetest.NewStore()
+	svc := service.NewShortener(servicetest.NewCache(), store, logging.NewLogger("json", io.Discard), servicetest.Recorder{Store: store}, ":5050")
+	return serve(t, svc)
+}
+
+// serve запускает gRPC-сервер поверх svc в памяти и возвращает подключённого к нему клиента.
+func serve(t *testing.T, svc *service.Shortener, opts ...grpc.ServerOption) pb.ShortenerClient {
+	lis := bufconn.Listen(1024 * 1024)
+	g := grpc.NewServer(opts...)
+	Register(g, svc)
+	go func() { _ = g.Serve(lis) }()
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return pb.NewShortenerClient(conn)
+}
+
+func TestServer(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	link, err := client.Shorten(ctx, &pb.ShortenRequest{Url: "https://www.google.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, "http://localhost:5050/redirect/"+link.GetAlias(), link.GetShortUrl())
+
+	_, err = client.Shorten(ctx, &pb.ShortenRequest{Url: "not a url"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.Shorten(ctx, &pb.ShortenRequest{Url: "https://www.google.com", Domain: "unknown.example.com"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	res, err := client.Resolve(ctx, &pb.ResolveRequest{Alias: link.GetAlias(), RecordClick: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "https://www.google.com", res.GetDestination())
+
+	_, err = client.Resolve(ctx, &pb.ResolveRequest{Alias: "unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	batch, err := client.BatchShorten(ctx, &pb.BatchShortenRequest{Items: []*pb.ShortenRequest{
+		{Url: "https://a.com"},
+		{Url: "invalid"},
+		{Url: "https://b.com", Split: &pb.Split{Variants: []*pb.Variant{{Url: "https://b1.com", Weight: 1}}}},
+	}})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(batch.GetResults()))
+	assert.NotEmpty(t, batch.GetResults()[0].GetLink().GetAlias())
+	assert.Equal(t, service.ErrInvalidUrl.Error(), batch.GetResults()[1].GetError())
+	assert.NotEmpty(t, batch.GetResults()[2].GetLink().GetAlias())
+
+	stats, err := client.GetStats(ctx, &pb.GetStatsRequest{Alias: link.GetAlias()})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), stats.GetTotal())
+}
+
+func TestShortenRequest(t *testing.T) {
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name string
+		in   *pb.ShortenRequest
+		err  error
+	}{
+		{name: "plain", in: &pb.ShortenRequest{Url: "https://a.com"}},
+		{name: "expires at", in: &pb.ShortenRequest{Url: "https://a.com", ExpiresAt: timestamppb.New(expires)}},
+		{name: "ttl", in: &pb.ShortenRequest{Url: "https://a.com", Ttl: durationpb.New(time.Hour)}},
+		{name: "expired", in: &pb.ShortenRequest{Url: "https://a.com", ExpiresAt: timestamppb.New(time.Now().Add(-time.Hour))}, err: service.ErrInvalidExpiry},
+		{name: "negative ttl", in: &pb.ShortenRequest{Url: "https://a.com", Ttl: durationpb.New(-time.Hour)}, err: service.ErrInvalidExpiry},
+		{name: "expires at and ttl", in: &pb.ShortenRequest{Url: "https://a.com", ExpiresAt: timestamppb.New(expires),
+			Ttl: durationpb.New(time.Hour)}, err: service.ErrInvalidExpiry},
+		{name: "invalid url", in: &pb.ShortenRequest{Url: "not a url"}, err: service.ErrInvalidUrl},
+		{name: "domain", in: &pb.ShortenRequest{Url: "https://a.com", Domain: "Go.Brand.com"}},
+		{name: "campaign", in: &pb.ShortenRequest{Url: "https://a.com", Campaign: "spring"}},
+		{name: "long campaign", in: &pb.ShortenRequest{Url: "https://a.com", Campaign: strings.Repeat("a", 129)}, err: service.ErrInvalidMeta},
+		{name: "invalid domain", in: &pb.ShortenRequest{Url: "https://a.com", Domain: "localhost"}, err: links.ErrInvalidDomain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := shortenRequest(tt.in)
+			assert.ErrorIs(t, err, tt.err)
+			if tt.err != nil {
+				return
+			}
+			assert.Equal(t, strings.ToLower(tt.in.GetDomain()), req.Domain)
+			assert.Equal(t, tt.in.GetCampaign(), req.Meta.Campaign)
+			switch {
+			case tt.in.GetExpiresAt() != nil:
+				assert.True(t, expires.Equal(req.ExpiresAt))
+			case tt.in.GetTtl() != nil:
+				assert.WithinDuration(t, time.Now().Add(time.Hour), req.ExpiresAt, time.Minute)
+			default:
+				assert.True(t, req.ExpiresAt.IsZero())
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"Darkyfun/UrlShortener/internal/storage"
+	"Darkyfun/UrlShortener/internal/workspace"
+	"crypto/subtle"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+// APIKeyHeader - это заголовок, в котором можно передать ключ API вместо Authorization: Bearer.
+const APIKeyHeader = "X-API-Key"
+
+// Authenticate возвращает gin middleware, который определяет рабочее пространство по ключу API из заголовка
+// Authorization: Bearer или X-API-Key и ограничивает им запрос. Запрос без ключа попадает в workspace.Default,
+// если require не выставлен, иначе, как и запрос с неизвестным ключом, получает ответ с кодом 401.
+func Authenticate(keys KeyStore, require bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := bearer(c)
+		if key == "" {
+			key = c.GetHeader(APIKeyHeader)
+		}
+
+		id := workspace.Default
+		switch {
+		case key != "":
+			var err error
+			id, err = keys.WorkspaceByKey(c.Request.Context(), workspace.HashKey(key))
+			if errors.Is(err, storage.ErrNotFound) {
+				err = workspace.ErrUnauthorized
+			}
+			if err != nil {
+				abortWithError(c, err)
+				return
+			}
+		case require:
+			abortWithError(c, workspace.ErrUnauthorized)
+			return
+		}
+
+		c.Set("workspace", id)
+		c.Request = c.Request.WithContext(workspace.WithID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// AdminAuth возвращает gin middleware, который пропускает только запросы с токеном администратора
+// в заголовке Authorization: Bearer. Остальные запросы получают ответ с кодом 401.
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" || subtle.ConstantTimeCompare([]byte(bearer(c)), []byte(token)) != 1 {
+			abortWithProblem(c, http.StatusUnauthorized, CodeUnauthorized, "admin token is required")
+			return
+		}
+		c.Next()
+	}
+}
+
+// bearer возвращает токен из заголовка Authorization: Bearer или пустую строку.
+func bearer(c *gin.Context) string {
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"Darkyfun/UrlShortener/internal/rollup"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// Число самых популярных ссылок в статистике кампании.
+const (
+	defaultCampaignTop = 10
+	maxCampaignTop     = 100
+)
+
+// campaignStatsRequest - это параметры статистики кампании.
+type campaignStatsRequest struct {
+	statsRequest
+	Top int `form:"top"`
+}
+
+// CampaignLinks возвращает страницу ссылок кампании из пути запроса. Параметры те же, что у ListLinks.
+func CampaignLinks(store Lister, timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var q listRequest
+		if err := c.ShouldBindQuery(&q); err != nil {
+			abortWithProblem(c, http.StatusBadRequest, CodeInvalidQuery, err.Error())
+			return
+		}
+		q.Campaign = c.Param("name")
+		listLinks(c, store, timeout, q)
+	}
+}
+
+// CampaignStats возвращает статистику переходов по всем ссылкам кампании из пути запроса с теми же параметрами, что у LinkStats,
+// и top ссылок с наибольшим числом переходов за период.
+// timeout ограничивает время запроса к store, 0 снимает ограничение.
+func CampaignStats(store StatsStore, pending PendingCounter, timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var q campaignStatsRequest
+		if err := c.ShouldBindQuery(&q); err != nil {
+			abortWithProblem(c, http.StatusBadRequest, CodeInvalidQuery, err.Error())
+			return
+		}
+
+		switch {
+		case q.Top == 0:
+			q.Top = defaultCampaignTop
+		case q.Top < 0 || q.Top > maxCampaignTop:
+			abortWithProblem(c, http.StatusBadRequest, CodeInvalidQuery, "top should be between 1 and 100")
+			return
+		}
+
+		stats, ok := clickStats(c, store, pending, timeout, rollup.Target{Campaign: c.Param("name")}, q.statsRequest, q.Top)
+		if !ok {
+			return
+		}
+		stats.Campaign = c.Param("name")
+
+		c.Set("status code", http.StatusOK)
+		c.JSON(http.StatusOK, stats)
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"Darkyfun/UrlShortener/internal/rollup"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCampaignStats(t *testing.T) {
+	store := &stubRollups{}
+	router := gin.New()
+	router.GET("/api/campaigns/:name/stats", CampaignStats(store, stubPending{}, time.Second))
+
+	tests := []struct {
+		name       string
+		url        string
+		statusCode int
+	}{
+		{name: "defaults", url: "/api/campaigns/spring/stats", statusCode: http.StatusOK},
+		{name: "top", url: "/api/campaigns/spring/stats?interval=week&top=3", statusCode: http.StatusOK},
+		{name: "unknown campaign", url: "/api/campaigns/autumn/stats", statusCode: http.StatusNotFound},
+		{name: "invalid top", url: "/api/campaigns/spring/stats?top=1000", statusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+			router.ServeHTTP(w, r)
+			assert.Equal(t, tt.statusCode, w.Result().StatusCode)
+		})
+	}
+
+	// без параметра top возвращаются 10 самых популярных ссылок.
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodGet, "/api/campaigns/spring/stats", nil)
+	router.ServeHTTP(w, r)
+	assert.Equal(t, rollup.Target{Campaign: "spring"}, store.target)
+	assert.Equal(t, defaultCampaignTop, store.top)
+
+	var stats rollup.Stats
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, "spring", stats.Campaign)
+	assert.Equal(t, "abc", stats.Top[0].Alias)
+}
+
+func TestCampaignLinks(t *testing.T) {
+	store := &stubLister{}
+	router := gin.New()
+	router.GET("/api/campaigns/:name/links", CampaignLinks(store, time.Second))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodGet, "/api/campaigns/spring/links?campaign=autumn&tag=promo&limit=5", nil)
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.Equal(t, "spring", store.filter.Campaign)
+	assert.Equal(t, "promo", store.filter.Tag)
+	assert.Equal(t, 5, store.filter.Limit)
+}
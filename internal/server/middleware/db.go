@@ -1,14 +1,45 @@
 package middleware
 
-import "context"
+import (
+	"Darkyfun/UrlShortener/internal/links"
+	"Darkyfun/UrlShortener/internal/rollup"
+	"Darkyfun/UrlShortener/internal/webhooks"
+	"Darkyfun/UrlShortener/internal/workspace"
+	"context"
+	"time"
+)
 
-type Cacher interface {
-	Set(ctx context.Context, key string, value any) error
-	Get(ctx context.Context, key string) (string, error)
+type Lister interface {
+	ListLinks(ctx context.Context, f links.Filter) ([]links.Link, *links.Cursor, error)
 }
 
-type Storager interface {
-	GetAlias(ctx context.Context, orig string) (string, error)
-	GetOriginal(ctx context.Context, alias string) (string, error)
-	Set(ctx context.Context, alias string, orig string) error
+type StatsStore interface {
+	ClickRollups(ctx context.Context, t rollup.Target, from, to time.Time, top int) (rollup.Hours, error)
+}
+
+type PendingCounter interface {
+	PendingClicks(ctx context.Context, aliases []string) (int64, error)
+}
+
+type HookStore interface {
+	CreateHook(ctx context.Context, h webhooks.Hook) (webhooks.Hook, error)
+	ListHooks(ctx context.Context) ([]webhooks.Hook, error)
+	DeleteHook(ctx context.Context, id int64) error
+	HookDeliveries(ctx context.Context, id int64, limit int) ([]webhooks.Delivery, error)
+}
+
+type DomainStore interface {
+	AddDomain(ctx context.Context, host string) (links.Domain, error)
+	ListDomains(ctx context.Context) ([]links.Domain, error)
+	DeleteDomain(ctx context.Context, host string) error
+}
+
+type KeyStore interface {
+	WorkspaceByKey(ctx context.Context, keyHash []byte) (int64, error)
+}
+
+type WorkspaceStore interface {
+	CreateWorkspace(ctx context.Context, w workspace.Workspace) (workspace.Workspace, error)
+	ListWorkspaces(ctx context.Context) ([]workspace.Workspace, error)
+	AddMember(ctx context.Context, m workspace.Member, keyHash []byte) (workspace.Member, error)
 }
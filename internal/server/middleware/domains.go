@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"Darkyfun/UrlShortener/internal/links"
+	"Darkyfun/UrlShortener/internal/service"
+	"Darkyfun/UrlShortener/internal/workspace"
+	"context"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// domainRequest - это структура, предназначенная для парсинга запроса на регистрацию домена.
+type domainRequest struct {
+	Host string `json:"host"`
+}
+
+// CreateDomain регистрирует собственный домен в рабочем пространстве запроса и сразу начинает разрешать псевдонимы его ссылок.
+// Другие экземпляры сервиса узнают о домене при следующем обновлении списка доменов.
+func CreateDomain(store DomainStore, svc *service.Shortener) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req domainRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortWithProblem(c, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+
+		host, err := links.NormalizeDomain(req.Host)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		dom, err := store.AddDomain(c.Request.Context(), host)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		reloadDomains(c.Request.Context(), store, svc)
+
+		c.Set("status code", http.StatusCreated)
+		c.JSON(http.StatusCreated, dom)
+	}
+}
+
+// ListDomains возвращает домены рабочего пространства запроса.
+func ListDomains(store DomainStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		domains, err := store.ListDomains(c.Request.Context())
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Set("status code", http.StatusOK)
+		c.JSON(http.StatusOK, gin.H{"domains": domains})
+	}
+}
+
+// DeleteDomain удаляет домен. Ссылки домена перестают открываться, но не удаляются.
+func DeleteDomain(store DomainStore, svc *service.Shortener) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		host, err := links.NormalizeDomain(c.Param("host"))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		if err = store.DeleteDomain(c.Request.Context(), host); err != nil {
+			abortWithError(c, err)
+			return
+		}
+		reloadDomains(c.Request.Context(), store, svc)
+
+		c.Set("status code", http.StatusNoContent)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// reloadDomains заново загружает в svc список доменов всех рабочих пространств. Ошибка не мешает ответу:
+// список обновится при следующем фоновом обновлении.
+func reloadDomains(ctx context.Context, store DomainStore, svc *service.Shortener) {
+	domains, err := store.ListDomains(workspace.Unscoped(ctx))
+	if err != nil {
+		return
+	}
+	svc.SetDomains(domains)
+}
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"Darkyfun/UrlShortener/internal/links"
+	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/service"
+	"Darkyfun/UrlShortener/internal/service/servicetest"
+	"Darkyfun/UrlShortener/internal/storage/persistent"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// stubDomains хранит домены в памяти.
+type stubDomains struct {
+	domains []links.Domain
+}
+
+func (s *stubDomains) AddDomain(_ context.Context, host string) (links.Domain, error) {
+	for _, d := range s.domains {
+		if d.Host == host {
+			return links.Domain{}, persistent.ErrAlreadyExists
+		}
+	}
+	s.domains = append(s.domains, links.Domain{Host: host})
+	return s.domains[len(s.domains)-1], nil
+}
+
+func (s *stubDomains) ListDomains(context.Context) ([]links.Domain, error) {
+	return s.domains, nil
+}
+
+func (s *stubDomains) DeleteDomain(_ context.Context, host string) error {
+	for i, d := range s.domains {
+		if d.Host == host {
+			s.domains = append(s.domains[:i], s.domains[i+1:]...)
+			return nil
+		}
+	}
+	return persistent.ErrNoRows
+}
+
+func TestDomains(t *testing.T) {
+	store := servicetest.NewStore()
+	svc := service.NewShortener(servicetest.NewCache(), store, logging.NewLogger("json", io.Discard), servicetest.Recorder{Store: store}, ":5050")
+	domains := &stubDomains{}
+
+	router := gin.New()
+	router.POST("/api/domains", CreateDomain(domains, svc))
+	router.GET("/api/domains", ListDomains(domains))
+	router.DELETE("/api/domains/:host", DeleteDomain(domains, svc))
+	router.POST("/receive", Validate(), Saver(svc))
+	router.GET("/redirect/:alias", Redirect(svc, nil))
+
+	do := func(method, host, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		r.Host = host
+		router.ServeHTTP(w, r)
+		return w
+	}
+	problem := func(w *httptest.ResponseRecorder) string {
+		var p Problem
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &p))
+		return p.Code
+	}
+
+	// домен должен быть зарегистрирован до создания ссылок на нём.
+	w := do(http.MethodPost, "", "/receive", `{"url": "https://brand-a.com", "domain": "go.brand-a.com"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, CodeUnknownDomain, problem(w))
+
+	w = do(http.MethodPost, "", "/api/domains", `{"host": "localhost"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, CodeInvalidDomain, problem(w))
+
+	for _, host := range []string{"Go.Brand-A.com", "go.brand-b.com"} {
+		w = do(http.MethodPost, "", "/api/domains", `{"host": "`+host+`"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+	w = do(http.MethodPost, "", "/api/domains", `{"host": "go.brand-a.com"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.True(t, svc.HasDomain("go.brand-a.com"))
+
+	w = do(http.MethodGet, "", "/api/domains", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"host":"go.brand-b.com"`)
+
+	// одинаковые псевдонимы на разных доменах - разные ссылки.
+	assert.Nil(t, store.Set(context.Background(), links.Key("go.brand-a.com", "x"), "https://brand-a.com/sale"))
+	assert.Nil(t, store.Set(context.Background(), links.Key("go.brand-b.com", "x"), "https://brand-b.com/sale"))
+
+	w = do(http.MethodGet, "go.brand-a.com", "/redirect/x", "")
+	assert.Equal(t, "https://brand-a.com/sale", w.Header().Get("Location"))
+	w = do(http.MethodGet, "GO.BRAND-B.COM:443", "/redirect/x", "")
+	assert.Equal(t, "https://brand-b.com/sale", w.Header().Get("Location"))
+	w = do(http.MethodGet, "localhost:5050", "/redirect/x", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// короткая ссылка ведёт на домен ссылки.
+	w = do(http.MethodPost, "", "/receive", `{"url": "https://brand-a.com", "domain": "go.brand-a.com"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var res struct{ Short_url string }
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Regexp(t, `^https://go\.brand-a\.com/redirect/\w+$`, res.Short_url)
+
+	w = do(http.MethodDelete, "", "/api/domains/go.brand-a.com", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.False(t, svc.HasDomain("go.brand-a.com"))
+	w = do(http.MethodDelete, "", "/api/domains/go.brand-a.com", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
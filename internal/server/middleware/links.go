@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"Darkyfun/UrlShortener/internal/links"
+	"Darkyfun/UrlShortener/internal/service"
+	"context"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// listRequest - это структура, предназначенная для парсинга параметров запроса списка ссылок.
+type listRequest struct {
+	Tag      string    `form:"tag"`
+	Owner    string    `form:"owner"`
+	Campaign string    `form:"campaign"`
+	From     time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00" time_utc:"1"`
+	To       time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00" time_utc:"1"`
+	Query    string    `form:"q"`
+	Sort     string    `form:"sort"`
+	Order    string    `form:"order"`
+	Cursor   string    `form:"cursor"`
+	Limit    int       `form:"limit"`
+}
+
+// ListLinks возвращает страницу ссылок, отфильтрованных по тегу, владельцу, кампании, дате создания и подстроке оригинального URL.
+// Для получения следующей страницы клиент передаёт полученный next_cursor в параметре cursor.
+// timeout ограничивает время запроса к store, 0 снимает ограничение.
+func ListLinks(store Lister, timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var q listRequest
+		if err := c.ShouldBindQuery(&q); err != nil {
+			abortWithProblem(c, http.StatusBadRequest, CodeInvalidQuery, err.Error())
+			return
+		}
+		listLinks(c, store, timeout, q)
+	}
+}
+
+// listLinks отвечает страницей ссылок, подходящих под параметры запроса q.
+func listLinks(c *gin.Context, store Lister, timeout time.Duration, q listRequest) {
+	f, err := links.Filter{
+		Tag:      q.Tag,
+		Owner:    q.Owner,
+		Campaign: q.Campaign,
+		From:     q.From,
+		To:       q.To,
+		Query:    q.Query,
+		Sort:     q.Sort,
+		Order:    q.Order,
+		Limit:    q.Limit,
+	}.Normalize()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	if q.Cursor != "" {
+		if f.Cursor, err = links.DecodeCursor(q.Cursor); err != nil {
+			abortWithError(c, err)
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	res, next, err := store.ListLinks(ctx, f)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	body := gin.H{"links": res}
+	if next != nil {
+		body["next_cursor"] = next.Encode()
+	}
+
+	c.Set("status code", http.StatusOK)
+	c.JSON(http.StatusOK, body)
+}
+
+// UpdateLinkMeta заменяет метаданные ссылки из пути запроса (с domain= для ссылки на собственном домене)
+// в рабочем пространстве запроса и уведомляет подписчиков событием link.updated.
+func UpdateLinkMeta(svc *service.Shortener) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, ok := linkKey(c)
+		if !ok {
+			return
+		}
+
+		var meta links.Meta
+		if err := c.ShouldBindJSON(&meta); err != nil {
+			abortWithProblem(c, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+
+		if err := svc.UpdateMeta(c.Request.Context(), key, meta); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Set("status code", http.StatusNoContent)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// DeleteLink удаляет ссылку из пути запроса (с domain= для ссылки на собственном домене) в рабочем пространстве запроса
+// вместе с её записями кэша и уведомляет подписчиков событием link.deleted. История переходов сохраняется.
+func DeleteLink(svc *service.Shortener) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, ok := linkKey(c)
+		if !ok {
+			return
+		}
+
+		if err := svc.Delete(c.Request.Context(), key); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Set("status code", http.StatusNoContent)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// linkKey возвращает ключ ссылки по псевдониму из пути запроса и собственному домену из параметра domain.
+// Возвращает false, если домен некорректен и ответ клиенту уже отправлен.
+func linkKey(c *gin.Context) (string, bool) {
+	key := c.Param("alias")
+	if domain := c.Query("domain"); domain != "" {
+		host, err := links.NormalizeDomain(domain)
+		if err != nil {
+			abortWithError(c, err)
+			return "", false
+		}
+		key = links.Key(host, key)
+	}
+	return key, true
+}
@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"Darkyfun/UrlShortener/internal/links"
+	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/service"
+	"Darkyfun/UrlShortener/internal/service/servicetest"
+	"Darkyfun/UrlShortener/internal/storage/cache"
+	"Darkyfun/UrlShortener/internal/webhooks"
+	"Darkyfun/UrlShortener/internal/workspace"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// stubLister запоминает последний фильтр и возвращает заранее заданную страницу.
+type stubLister struct {
+	filter links.Filter
+	page   []links.Link
+	next   *links.Cursor
+}
+
+func (s *stubLister) ListLinks(_ context.Context, f links.Filter) ([]links.Link, *links.Cursor, error) {
+	s.filter = f
+	return s.page, s.next, nil
+}
+
+func TestListLinks(t *testing.T) {
+	created := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	store := &stubLister{
+		page: []links.Link{{Alias: "abc", Original: "https://www.google.com", CreatedAt: created, Meta: links.Meta{Tags: []string{"promo"}}}},
+		next: &links.Cursor{CreatedAt: created, Alias: "abc"},
+	}
+
+	router := gin.New()
+	router.GET("/api/links", ListLinks(store, time.Second))
+
+	tests := []struct {
+		name       string
+		url        string
+		statusCode int
+	}{
+		{name: "filters", url: "/api/links?tag=promo&owner=growth&q=google&from=2023-09-01T00:00:00Z&sort=alias&order=asc&limit=10", statusCode: http.StatusOK},
+		{name: "invalid sort", url: "/api/links?sort=original", statusCode: http.StatusBadRequest},
+		{name: "invalid date", url: "/api/links?from=yesterday", statusCode: http.StatusBadRequest},
+		{name: "invalid cursor", url: "/api/links?cursor=%25%25", statusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+			router.ServeHTTP(w, r)
+			assert.Equal(t, tt.statusCode, w.Result().StatusCode)
+		})
+	}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodGet, "/api/links?tag=promo&owner=growth&q=google&from=2023-09-01T00:00:00Z&sort=alias&order=asc&limit=10", nil)
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, links.Filter{
+		Tag: "promo", Owner: "growth", Query: "google", From: time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC),
+		Sort: links.SortAlias, Order: links.OrderAsc, Limit: 10,
+	}, store.filter)
+
+	var body struct {
+		Links      []links.Link `json:"links"`
+		NextCursor string       `json:"next_cursor"`
+	}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "abc", body.Links[0].Alias)
+
+	// следующая страница запрашивается с полученным курсором.
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodGet, "/api/links?cursor="+body.NextCursor, nil)
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.Equal(t, "abc", store.filter.Cursor.Alias)
+}
+
+// stubPublisher запоминает опубликованные события.
+type stubPublisher struct {
+	events []webhooks.Event
+}
+
+func (p *stubPublisher) Publish(_ context.Context, ev webhooks.Event) {
+	p.events = append(p.events, ev)
+}
+
+func TestLinkManagement(t *testing.T) {
+	store := servicetest.NewStore()
+	rdb := servicetest.NewCache()
+	svc := service.NewShortener(rdb, store, logging.NewLogger("json", io.Discard), servicetest.Recorder{Store: store}, ":5050")
+	published := &stubPublisher{}
+	svc.SetPublisher(published)
+
+	keys := &stubWorkspaces{keys: map[string]int64{string(workspace.HashKey("sk_five")): 5, string(workspace.HashKey("sk_six")): 6}}
+	router := gin.New()
+	scoped := router.Group("", Authenticate(keys, true))
+	scoped.PUT("/api/links/:alias/meta", UpdateLinkMeta(svc))
+	scoped.DELETE("/api/links/:alias", DeleteLink(svc))
+
+	ctx := workspace.WithID(context.Background(), 5)
+	link, err := svc.Shorten(ctx, service.ShortenRequest{Url: "https://www.google.com", Meta: links.Meta{Title: "old"}})
+	assert.Nil(t, err)
+	published.events = nil
+
+	do := func(method, url, key, body string) int {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		r.Header.Set("Authorization", "Bearer "+key)
+		router.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		key    string
+		body   string
+		code   int
+	}{
+		{name: "invalid meta", method: http.MethodPut, url: "/api/links/" + link.Alias + "/meta", key: "sk_five",
+			body: `{"tags": [""]}`, code: http.StatusBadRequest},
+		{name: "invalid domain", method: http.MethodPut, url: "/api/links/" + link.Alias + "/meta?domain=localhost", key: "sk_five",
+			body: `{"title": "new"}`, code: http.StatusBadRequest},
+		{name: "update in other workspace", method: http.MethodPut, url: "/api/links/" + link.Alias + "/meta", key: "sk_six",
+			body: `{"title": "new"}`, code: http.StatusNotFound},
+		{name: "update", method: http.MethodPut, url: "/api/links/" + link.Alias + "/meta", key: "sk_five",
+			body: `{"title": "new", "tags": ["promo"]}`, code: http.StatusNoContent},
+		{name: "delete in other workspace", method: http.MethodDelete, url: "/api/links/" + link.Alias, key: "sk_six",
+			code: http.StatusNotFound},
+		{name: "delete", method: http.MethodDelete, url: "/api/links/" + link.Alias, key: "sk_five", code: http.StatusNoContent},
+		{name: "delete again", method: http.MethodDelete, url: "/api/links/" + link.Alias, key: "sk_five", code: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.code, do(tt.method, tt.url, tt.key, tt.body))
+		})
+	}
+
+	// события публикуются только об успешных изменениях, удаление очищает кэш ссылки.
+	assert.Len(t, published.events, 2)
+	assert.Equal(t, webhooks.Event{Type: webhooks.EventLinkUpdated, Alias: link.Alias}, published.events[0])
+	assert.Equal(t, webhooks.Event{Type: webhooks.EventLinkDeleted, Alias: link.Alias}, published.events[1])
+
+	_, err = rdb.Get(context.Background(), link.Alias)
+	assert.Equal(t, cache.ErrCacheMiss, err)
+	_, err = svc.Resolve(context.Background(), link.Alias, service.Visitor{}, false)
+	assert.Equal(t, service.ErrNotFound, err)
+}
@@ -2,6 +2,7 @@
 package middleware
 
 import (
+	"Darkyfun/UrlShortener/internal/logging"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -15,7 +16,8 @@ type LogHandler struct {
 }
 
 // NewLogHandler возвращает логер, служащий основой для логирования входящих запросов.
-func NewLogHandler(file io.Writer) *LogHandler {
+// Запросы логируются с уровнем info, поэтому уровень warn и выше отключает их логирование.
+func NewLogHandler(file io.Writer, level logging.Level) *LogHandler {
 	conf := zapcore.EncoderConfig{
 		MessageKey:     "message",
 		TimeKey:        "time",
@@ -24,18 +26,26 @@ func NewLogHandler(file io.Writer) *LogHandler {
 		EncodeDuration: zapcore.MillisDurationEncoder,
 	}
 
-	core := zapcore.NewCore(zapcore.NewConsoleEncoder(conf), zapcore.Lock(zapcore.AddSync(file)), zapcore.DebugLevel)
+	core := zapcore.NewCore(zapcore.NewConsoleEncoder(conf), zapcore.Lock(zapcore.AddSync(file)), level)
 	logger := zap.New(core).Sugar()
 	return &LogHandler{ZapLog: logger}
 }
 
 // Logger возвращает gin middleware, используемый для логирования входящих запросов.
+// Каждая запись содержит идентификатор запроса, выставленный middleware RequestID.
 func (l *LogHandler) Logger() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		defer func() {
 			code, _ := c.Get("status code")
-			l.ZapLog.Debugf("%v %v %v %v %v", c.Request.Method, c.Request.URL, c.Request.ContentLength, code, time.Since(start))
+			l.ZapLog.Infow("request",
+				logging.RequestIDKey, c.GetString("request id"),
+				"method", c.Request.Method,
+				"url", c.Request.URL.String(),
+				"length", c.Request.ContentLength,
+				"status", code,
+				"latency", time.Since(start),
+			)
 		}()
 		c.Next()
 	}
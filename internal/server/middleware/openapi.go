@@ -0,0 +1,14 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// OpenAPI отдаёт машиночитаемое описание HTTP-API.
+func OpenAPI(doc []byte) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("status code", http.StatusOK)
+		c.Data(http.StatusOK, "application/json", doc)
+	}
+}
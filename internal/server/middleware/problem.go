@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"Darkyfun/UrlShortener/internal/links"
+	"Darkyfun/UrlShortener/internal/rollup"
+	"Darkyfun/UrlShortener/internal/service"
+	"Darkyfun/UrlShortener/internal/storage"
+	"Darkyfun/UrlShortener/internal/webhooks"
+	"Darkyfun/UrlShortener/internal/workspace"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// problemContentType - это тип содержимого ответа с ошибкой по RFC 7807.
+const problemContentType = "application/problem+json"
+
+// problemTypePrefix - это префикс поля type ответа с ошибкой. После него следует код ошибки.
+const problemTypePrefix = "urn:shortener:problem:"
+
+// Стабильные коды ошибок HTTP-API. Клиенты могут полагаться на них вместо текста ошибки.
+const (
+	CodeInvalidRequest   = "invalid_request"
+	CodeInvalidUrl       = "invalid_url"
+	CodeInvalidRules     = "invalid_rules"
+	CodeInvalidSplit     = "invalid_split"
+	CodeInvalidMeta      = "invalid_meta"
+	CodeInvalidExpiry    = "invalid_expiry"
+	CodeBlockedUrl       = "blocked_url"
+	CodeInvalidQuery     = "invalid_query"
+	CodeInvalidCursor    = "invalid_cursor"
+	CodeInvalidWebhook   = "invalid_webhook"
+	CodeInvalidDomain    = "invalid_domain"
+	CodeUnknownDomain    = "unknown_domain"
+	CodeInvalidWorkspace = "invalid_workspace"
+	CodeInvalidMember    = "invalid_member"
+	CodeQuotaExceeded    = "quota_exceeded"
+	CodeUnauthorized     = "unauthorized"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeRateLimited      = "rate_limited"
+	CodeUnavailable      = "storage_unavailable"
+	CodeTimeout          = "storage_timeout"
+	CodeInternal         = "internal_error"
+)
+
+// Problem - это тело ответа с ошибкой в формате RFC 7807 (application/problem+json).
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// abortWithProblem прерывает обработку запроса и отвечает ошибкой в формате RFC 7807.
+func abortWithProblem(c *gin.Context, status int, code string, detail string) {
+	c.Set("status code", status)
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(status, Problem{
+		Type:      problemTypePrefix + code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  c.Request.URL.Path,
+		Code:      code,
+		RequestID: c.GetString("request id"),
+	})
+}
+
+// abortWithError отвечает ошибкой, соответствующей ошибке сервиса или хранилища.
+// Для ошибок сервера текст исходной ошибки не раскрывается: в ответ попадает только класс ошибки.
+func abortWithError(c *gin.Context, err error) {
+	p := problemFor(err)
+	detail := err.Error()
+	if p.status >= http.StatusInternalServerError {
+		detail = p.err.Error()
+	}
+	abortWithProblem(c, p.status, p.code, detail)
+}
+
+// problemMapping - это соответствие ошибки HTTP-статусу и коду ошибки.
+type problemMapping struct {
+	err    error
+	status int
+	code   string
+}
+
+// problems - это единое для всех обработчиков соответствие ошибок сервиса и хранилищ HTTP-статусам.
+// Ошибки проверяются по порядку через errors.Is, поэтому более частные ошибки должны идти раньше общих.
+var problems = []problemMapping{
+	{err: service.ErrInvalidRequest, status: http.StatusBadRequest, code: CodeInvalidRequest},
+	{err: service.ErrInvalidUrl, status: http.StatusBadRequest, code: CodeInvalidUrl},
+	{err: service.ErrInvalidRules, status: http.StatusBadRequest, code: CodeInvalidRules},
+	{err: service.ErrInvalidSplit, status: http.StatusBadRequest, code: CodeInvalidSplit},
+	{err: service.ErrInvalidMeta, status: http.StatusBadRequest, code: CodeInvalidMeta},
+	{err: service.ErrInvalidExpiry, status: http.StatusBadRequest, code: CodeInvalidExpiry},
+	{err: service.ErrBlockedUrl, status: http.StatusUnprocessableEntity, code: CodeBlockedUrl},
+	{err: links.ErrInvalidDomain, status: http.StatusBadRequest, code: CodeInvalidDomain},
+	{err: service.ErrUnknownDomain, status: http.StatusBadRequest, code: CodeUnknownDomain},
+	{err: links.ErrInvalidSort, status: http.StatusBadRequest, code: CodeInvalidQuery},
+	{err: links.ErrInvalidCursor, status: http.StatusBadRequest, code: CodeInvalidCursor},
+	{err: rollup.ErrInvalidInterval, status: http.StatusBadRequest, code: CodeInvalidQuery},
+	{err: rollup.ErrInvalidPeriod, status: http.StatusBadRequest, code: CodeInvalidQuery},
+	{err: rollup.ErrInvalidTimezone, status: http.StatusBadRequest, code: CodeInvalidQuery},
+	{err: webhooks.ErrInvalidHook, status: http.StatusBadRequest, code: CodeInvalidWebhook},
+	{err: workspace.ErrInvalidWorkspace, status: http.StatusBadRequest, code: CodeInvalidWorkspace},
+	{err: workspace.ErrInvalidMember, status: http.StatusBadRequest, code: CodeInvalidMember},
+	{err: workspace.ErrQuotaExceeded, status: http.StatusForbidden, code: CodeQuotaExceeded},
+	{err: workspace.ErrUnauthorized, status: http.StatusUnauthorized, code: CodeUnauthorized},
+	{err: storage.ErrNotFound, status: http.StatusNotFound, code: CodeNotFound},
+	{err: storage.ErrConflict, status: http.StatusConflict, code: CodeConflict},
+	{err: storage.ErrUnavailable, status: http.StatusServiceUnavailable, code: CodeUnavailable},
+	{err: storage.ErrTimeout, status: http.StatusGatewayTimeout, code: CodeTimeout},
+}
+
+// internalProblem - это ответ на ошибку, не попавшую в problems.
+var internalProblem = problemMapping{err: errors.New("internal error"), status: http.StatusInternalServerError, code: CodeInternal}
+
+// problemFor возвращает HTTP-статус и код ошибки для ошибки сервиса или хранилища.
+func problemFor(err error) problemMapping {
+	for _, p := range problems {
+		if errors.Is(err, p.err) {
+			return p
+		}
+	}
+	return internalProblem
+}
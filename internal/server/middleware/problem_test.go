@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"Darkyfun/UrlShortener/api"
+	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/ratelimit"
+	"Darkyfun/UrlShortener/internal/service"
+	"Darkyfun/UrlShortener/internal/service/servicetest"
+	"Darkyfun/UrlShortener/internal/storage/cache"
+	"Darkyfun/UrlShortener/internal/storage/persistent"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProblems(t *testing.T) {
+	store := servicetest.NewStore()
+	svc := service.NewShortener(servicetest.NewCache(), store, logging.NewLogger("json", io.Discard), servicetest.Recorder{Store: store}, ":5050")
+	svc.SetBlocklist([]string{"evil.com"})
+
+	router := gin.New()
+	router.GET("/redirect/:alias", Redirect(svc, nil))
+	router.POST("/receive", Validate(), Saver(svc))
+	router.GET("/openapi.json", OpenAPI(api.OpenAPI))
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		statusCode int
+		code       string
+	}{
+		{name: "unknown alias", method: http.MethodGet, url: "/redirect/unknown", statusCode: http.StatusNotFound, code: CodeNotFound},
+		{name: "invalid url", method: http.MethodPost, url: "/receive", body: `{"url":"https:/www"}`, statusCode: http.StatusBadRequest, code: CodeInvalidUrl},
+		{name: "blocked url", method: http.MethodPost, url: "/receive", body: `{"url":"https://evil.com"}`, statusCode: http.StatusUnprocessableEntity, code: CodeBlockedUrl},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.statusCode, w.Result().StatusCode)
+			assert.Equal(t, problemContentType, w.Header().Get("Content-Type"))
+
+			var p Problem
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &p))
+			assert.Equal(t, tt.code, p.Code)
+			assert.Equal(t, problemTypePrefix+tt.code, p.Type)
+			assert.Equal(t, tt.url, p.Instance)
+		})
+	}
+
+	// документ OpenAPI должен быть корректным JSON и описывать все маршруты.
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodGet, "/openapi.json", nil)
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	var doc struct {
+		Paths map[string]any `json:"paths"`
+	}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	for _, route := range []string{"/receive", "/redirect/{alias}", "/api/links", "/openapi.json"} {
+		assert.Contains(t, doc.Paths, route)
+	}
+}
+
+func TestProblemFor(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		statusCode int
+		code       string
+		detail     string
+	}{
+		{name: "not found", err: service.ErrNotFound, statusCode: http.StatusNotFound, code: CodeNotFound, detail: "alias not found"},
+		{name: "conflict", err: persistent.ErrAlreadyExists, statusCode: http.StatusConflict, code: CodeConflict, detail: "alias already exists: conflict"},
+		{name: "pool closed", err: fmt.Errorf("saving link: %w", persistent.ErrConnClosed), statusCode: http.StatusServiceUnavailable, code: CodeUnavailable, detail: "storage unavailable"},
+		{name: "cache timeout", err: fmt.Errorf("caching link: %w", cache.ErrTimeout), statusCode: http.StatusGatewayTimeout, code: CodeTimeout, detail: "storage timeout"},
+		{name: "unknown", err: errors.New("syntax error at or near"), statusCode: http.StatusInternalServerError, code: CodeInternal, detail: "internal error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest(http.MethodGet, "/", nil)
+			abortWithError(c, tt.err)
+
+			var p Problem
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &p))
+			assert.Equal(t, tt.statusCode, w.Code)
+			assert.Equal(t, tt.code, p.Code)
+			assert.Equal(t, tt.detail, p.Detail)
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	router := gin.New()
+	router.Use(RateLimit(ratelimit.NewLimiter(1, 2)))
+	router.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+
+	codes := make([]int, 3)
+	for i := range codes {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodGet, "/ping", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		router.ServeHTTP(w, r)
+		codes[i] = w.Result().StatusCode
+		if codes[i] == http.StatusTooManyRequests {
+			assert.Equal(t, "1", w.Header().Get("Retry-After"))
+			assert.Contains(t, w.Body.String(), CodeRateLimited)
+		}
+	}
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, codes)
+}
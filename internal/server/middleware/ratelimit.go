@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"Darkyfun/UrlShortener/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// RateLimit возвращает gin middleware, ограничивающий частоту запросов с одного IP-адреса.
+// Запросы сверх лимита получают ответ с кодом 429.
+func RateLimit(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !limiter.Allow(c.ClientIP()) {
+			c.Header("Retry-After", "1")
+			abortWithProblem(c, http.StatusTooManyRequests, CodeRateLimited, "too many requests")
+			return
+		}
+		c.Next()
+	}
+}
@@ -1,59 +1,81 @@
 package middleware
 
 import (
-	"context"
+	"Darkyfun/UrlShortener/internal/links"
+	"Darkyfun/UrlShortener/internal/service"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"time"
 )
 
-type Logger interface {
-	Log(string, string)
+// RedirectOptions - это настройки перенаправления, которые можно менять во время работы.
+type RedirectOptions struct {
+	// Status - код ответа перенаправления: 301, 302, 307 или 308.
+	Status int
+	// StickyMaxAge - время жизни cookie, закрепляющей за клиентом вариант A/B-распределения.
+	StickyMaxAge time.Duration
+	// CountryHeader - заголовок, в котором прокси или CDN передают код страны клиента, пустая строка отключает его чтение.
+	// Задавать его можно только за прокси, который перезаписывает или удаляет этот заголовок из запроса клиента.
+	CountryHeader string
 }
 
+// DefaultRedirectOptions - это настройки перенаправления по умолчанию.
+var DefaultRedirectOptions = RedirectOptions{Status: http.StatusTemporaryRedirect, StickyMaxAge: 30 * 24 * time.Hour}
+
 // aliasRequest - это структура, предназначенная для парсинга URL-параметра входящего запроса.
 type aliasRequest struct {
 	Alias string `uri:"alias" binding:"required"`
 }
 
-// Redirect парсит входящий запрос с псевдонимом и перенаправляет клиент на оригинальный URL с кодом ответа 307.
-// Сначала Redirect проверят кэш на наличие записи. Если данная запись есть, то осуществляется перенаправление.
-// Если в кэше записи нет, то запрос на выборку отправляется в SQL-базу данных, после чего в кэш вносится данная пара значений и клиента перенаправляют на оригинальный URL.
-func Redirect(cache Cacher, store Storager, logger Logger) gin.HandlerFunc {
+// Redirect парсит входящий запрос с псевдонимом и перенаправляет клиент на адрес, выбранный service.Shortener.
+// Код ответа и время жизни cookie берутся из opts при каждом запросе, nil означает DefaultRedirectOptions.
+// Если у псевдонима закрепляемое A/B-распределение, выбранный вариант сохраняется в cookie.
+// На зарегистрированном собственном домене псевдоним ищется среди ссылок домена из заголовка Host.
+// На неизвестный псевдоним отвечает кодом 404.
+func Redirect(svc *service.Shortener, opts func() RedirectOptions) gin.HandlerFunc {
+	if opts == nil {
+		opts = func() RedirectOptions { return DefaultRedirectOptions }
+	}
+
 	return func(c *gin.Context) {
 		var q aliasRequest
 		err := c.ShouldBindUri(&q)
 		if err != nil {
-			c.Set("status code", http.StatusBadRequest)
-			c.String(http.StatusBadRequest, "invalid request")
+			abortWithProblem(c, http.StatusBadRequest, CodeInvalidRequest, "alias is required")
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
-		defer cancel()
-
-		orig, err := cache.Get(ctx, q.Alias)
-		if err != nil && err.Error() == "cache miss" {
+		visitor := service.Visitor{
+			UserAgent:      c.Request.UserAgent(),
+			AcceptLanguage: c.GetHeader("Accept-Language"),
+			Referrer:       c.Request.Referer(),
+		}
+		if variant, err := c.Cookie(stickyCookie(q.Alias)); err == nil {
+			visitor.Variant = variant
+		}
 
-			orig, err = store.GetOriginal(ctx, q.Alias)
-			if orig != "" && err == nil {
+		o := opts()
+		if o.CountryHeader != "" {
+			visitor.Country = c.GetHeader(o.CountryHeader)
+		}
 
-				if err = cache.Set(ctx, q.Alias, orig); err != nil {
-					logger.Log("error", "reading and writing to cache failed: "+err.Error())
-				}
+		key := svc.LinkKey(links.RequestHost(c.Request.Host), q.Alias)
+		res, err := svc.Resolve(c.Request.Context(), key, visitor, true)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
 
-				c.Set("status code", http.StatusTemporaryRedirect)
-				c.Redirect(http.StatusTemporaryRedirect, orig)
-				return
-			}
-			if orig == "" {
-				c.Set("status code", http.StatusBadRequest)
-				c.String(http.StatusBadRequest, "Not found")
-				return
-			}
+		if res.Sticky {
+			c.SetCookie(stickyCookie(q.Alias), res.Variant, int(o.StickyMaxAge.Seconds()), "/redirect/"+q.Alias, "", false, true)
 		}
 
-		c.Set("status code", http.StatusTemporaryRedirect)
-		c.Redirect(http.StatusTemporaryRedirect, orig)
+		c.Set("status code", o.Status)
+		c.Redirect(o.Status, res.Destination)
 	}
 }
+
+// stickyCookie возвращает имя cookie, в которой хранится закреплённый за клиентом вариант псевдонима.
+func stickyCookie(alias string) string {
+	return "variant_" + alias
+}
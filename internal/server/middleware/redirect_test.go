@@ -1,7 +1,12 @@
 package middleware
 
 import (
+	"Darkyfun/UrlShortener/internal/clicks"
+	"Darkyfun/UrlShortener/internal/links"
 	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/service"
+	"Darkyfun/UrlShortener/internal/service/servicetest"
+	"Darkyfun/UrlShortener/internal/split"
 	"Darkyfun/UrlShortener/internal/storage/cache"
 	"Darkyfun/UrlShortener/internal/storage/persistent"
 	"context"
@@ -24,15 +29,18 @@ func TestRedirect(t *testing.T) {
 	}{
 		{name: "valid request", url: "/redirect/googlealias", resp: http.StatusTemporaryRedirect},
 		{name: "no alias", url: "/redirect", resp: http.StatusBadRequest},
-		{name: "invalid alias", url: "/redirect/invalidalias", resp: http.StatusBadRequest},
+		{name: "invalid alias", url: "/redirect/invalidalias", resp: http.StatusNotFound},
 	}
 
 	logger := logging.NewLogger("json", io.Discard)
 	db := persistent.NewDb(context.Background(), logger, TestBase)
 	rdb := cache.NewCacheDb(cache.Opts{Addr: redisAddr}, logger)
 
+	recorder := clicks.NewRecorder(&db, logger, 16)
+	go recorder.Run(context.Background())
+
 	router := gin.New()
-	router.GET("/redirect/:alias", Redirect(rdb, &db, logger))
+	router.GET("/redirect/:alias", Redirect(service.NewShortener(rdb, &db, logger, recorder, ":5050"), nil))
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -44,3 +52,45 @@ func TestRedirect(t *testing.T) {
 		})
 	}
 }
+
+func TestRedirect_Sticky(t *testing.T) {
+	store := servicetest.NewStore()
+	svc := service.NewShortener(servicetest.NewCache(), store, logging.NewLogger("json", io.Discard), servicetest.Recorder{Store: store}, ":5050")
+
+	req, err := service.NewShortenRequest("https://www.google.com", nil, &split.Split{Sticky: true, Variants: []split.Variant{
+		{Name: "A", Url: "https://a.com", Weight: 1}, {Name: "B", Url: "https://b.com", Weight: 1}}}, links.Meta{})
+	assert.Nil(t, err)
+	link, err := svc.Shorten(context.Background(), req)
+	assert.Nil(t, err)
+
+	router := gin.New()
+	router.GET("/redirect/:alias", Redirect(svc, nil))
+
+	// первый переход закрепляет за клиентом вариант в cookie.
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodGet, "/redirect/"+link.Alias, nil)
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+
+	cookies := w.Result().Cookies()
+	assert.Len(t, cookies, 1)
+	assert.Equal(t, stickyCookie(link.Alias), cookies[0].Name)
+	variant := cookies[0].Value
+	urls := map[string]string{"A": "https://a.com", "B": "https://b.com"}
+	assert.Equal(t, urls[variant], w.Header().Get("Location"))
+
+	// вернувшийся с cookie клиент всегда получает тот же вариант, и переход записывается на него.
+	for i := 0; i < 20; i++ {
+		w = httptest.NewRecorder()
+		r, _ = http.NewRequest(http.MethodGet, "/redirect/"+link.Alias, nil)
+		r.AddCookie(cookies[0])
+		router.ServeHTTP(w, r)
+		assert.Equal(t, urls[variant], w.Header().Get("Location"))
+		assert.Empty(t, w.Result().Cookies())
+	}
+
+	stats, err := store.ClickStats(context.Background(), link.Alias)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(21), stats.Total)
+	assert.Equal(t, map[string]int64{variant: 21}, stats.Variants)
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader - это заголовок, в котором передаётся идентификатор запроса.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID возвращает gin middleware, который принимает идентификатор запроса из заголовка X-Request-ID
+// или генерирует новый, если заголовка нет или он некорректен.
+// Идентификатор кладётся в контекст запроса и возвращается клиенту в том же заголовке.
+// Middleware должен стоять перед LogHandler.Logger, чтобы идентификатор попал в лог входящих запросов.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !logging.ValidRequestID(id) {
+			id = logging.NewRequestID()
+		}
+
+		c.Set("request id", id)
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	var access bytes.Buffer
+	var fromCtx string
+
+	router := gin.New()
+	router.Use(RequestID())
+	router.Use(NewLogHandler(&access, zap.NewAtomicLevel()).Logger())
+	router.GET("/ping", func(c *gin.Context) {
+		fromCtx = logging.RequestID(c.Request.Context())
+		c.String(http.StatusOK, "pong")
+	})
+
+	tests := []struct {
+		name     string
+		header   string
+		accepted bool
+	}{
+		{name: "accepted", header: "client-id-1", accepted: true},
+		{name: "generated", header: "", accepted: false},
+		{name: "too long", header: strings.Repeat("a", logging.MaxRequestIDLen+1), accepted: false},
+		{name: "not printable", header: "id with spaces", accepted: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			access.Reset()
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest(http.MethodGet, "/ping", nil)
+			if tt.header != "" {
+				r.Header.Set(RequestIDHeader, tt.header)
+			}
+			router.ServeHTTP(w, r)
+
+			id := w.Header().Get(RequestIDHeader)
+			assert.NotEmpty(t, id)
+			assert.Equal(t, id, fromCtx)
+			assert.Contains(t, access.String(), id)
+			if tt.accepted {
+				assert.Equal(t, tt.header, id)
+			} else {
+				assert.NotEqual(t, tt.header, id)
+			}
+		})
+	}
+}
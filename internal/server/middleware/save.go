@@ -1,63 +1,30 @@
 package middleware
 
 import (
-	"Darkyfun/UrlShortener/internal/aliasname"
-	"Darkyfun/UrlShortener/internal/storage/persistent"
-	"context"
-	"errors"
-	"fmt"
+	"Darkyfun/UrlShortener/internal/service"
 	"github.com/gin-gonic/gin"
 	"net/http"
-	"time"
 )
 
 // Saver принимает оригинальный URL от клиента и возвращает ему ссылку с псевдонимом.
-func Saver(cache Cacher, store Storager, addr string) gin.HandlerFunc {
+// Запрос берётся из Validate, если он стоит перед Saver, иначе проверяется здесь же.
+// Если в запросе переданы правила перенаправления, A/B-распределение или метаданные, для ссылки всегда создаётся новый псевдоним.
+func Saver(svc *service.Shortener) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		origUrl := c.Request.Context().Value("IncomeUrl").(string)
-
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
-		defer cancel()
-
-		alias, err := store.GetAlias(ctx, origUrl)
-		if alias != "" && err == nil {
-			c.Set("status code", http.StatusOK)
-			c.JSON(http.StatusOK, gin.H{
-				"Short_url": "http://" + "localhost" + addr + "/redirect/" + alias,
-			})
+		req, ok := shortenRequest(c)
+		if !ok {
 			return
 		}
 
+		link, err := svc.Shorten(c.Request.Context(), req)
 		if err != nil {
-			if errors.Is(err, persistent.ErrConnClosed) || errors.Is(err, persistent.ErrConnect) {
-				c.Set("status code", http.StatusInternalServerError)
-				c.String(http.StatusInternalServerError, "internal server error")
-				return
-			}
-		}
-
-		alias = aliasname.GetRandomAlias(10)
-		for {
-			err = store.Set(ctx, alias, origUrl)
-			if err == nil {
-				break
-			} else if errors.Is(err, persistent.ErrConnClosed) || errors.Is(err, persistent.ErrConnect) {
-				c.Set("status code", http.StatusInternalServerError)
-				c.String(http.StatusInternalServerError, "internal server error")
-				return
-			}
-		}
-
-		err = cache.Set(ctx, alias, origUrl)
-		if err != nil {
-			c.Set("status code", http.StatusInternalServerError)
-			fmt.Println(err)
+			abortWithError(c, err)
 			return
 		}
 
 		c.Set("status code", http.StatusOK)
 		c.JSON(http.StatusOK, gin.H{
-			"Short_url": "http://" + "localhost" + addr + "/redirect/" + alias,
+			"Short_url": link.ShortUrl,
 		})
 	}
 }
@@ -1,7 +1,9 @@
 package middleware
 
 import (
+	"Darkyfun/UrlShortener/internal/clicks"
 	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/service"
 	"Darkyfun/UrlShortener/internal/storage/cache"
 	"Darkyfun/UrlShortener/internal/storage/persistent"
 	"context"
@@ -30,14 +32,14 @@ func TestSaver(t *testing.T) {
 
 	// настраиваем gin.
 	router := gin.New()
-	router.Use(Saver(rdb, &db, ":5050"))
+	recorder := clicks.NewRecorder(&db, logger, 16)
+	router.Use(Saver(service.NewShortener(rdb, &db, logger, recorder, ":5050")))
 	router.POST("/receive")
 
 	// happy path.
 	w := httptest.NewRecorder()
 	r, err := http.NewRequest(http.MethodPost, "/receive", strings.NewReader("{\"url\":\"https://www.google.com\"}"))
 	assert.Nil(t, err)
-	r = r.WithContext(context.WithValue(context.Background(), "IncomeUrl", "https://www.google.com"))
 	router.ServeHTTP(w, r)
 	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
 
@@ -49,7 +51,6 @@ func TestSaver(t *testing.T) {
 	w = httptest.NewRecorder()
 	r, err = http.NewRequest(http.MethodPost, "/receive", strings.NewReader("{\"url\":\"https://www.google.com\"}"))
 	assert.Nil(t, err)
-	r = r.WithContext(context.WithValue(context.Background(), "IncomeUrl", "https://www.google.com"))
 	router.ServeHTTP(w, r)
-	assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Result().StatusCode)
 }
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"Darkyfun/UrlShortener/internal/rollup"
+	"context"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// statsRequest - это структура, предназначенная для парсинга параметров запроса статистики переходов.
+type statsRequest struct {
+	Interval string    `form:"interval"`
+	From     time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00" time_utc:"1"`
+	To       time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00" time_utc:"1"`
+	TZ       string    `form:"tz"`
+}
+
+// LinkStats возвращает статистику переходов по ссылке из пути запроса за период [from, to), по умолчанию - за последние 30 дней,
+// по интервалам interval (hour, day или week) в часовом поясе tz вместе с разбивкой по источникам, странам, устройствам и браузерам.
+// Статистика строится по почасовой свёртке переходов, поэтому переходы последних минут в неё ещё не попадают,
+// а счётчик count за всё время, как и в gRPC GetStats, включает переходы, которые pending ещё не перенёс в SQL-базу данных.
+// timeout ограничивает время запроса к store, 0 снимает ограничение.
+func LinkStats(store StatsStore, pending PendingCounter, timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var q statsRequest
+		if err := c.ShouldBindQuery(&q); err != nil {
+			abortWithProblem(c, http.StatusBadRequest, CodeInvalidQuery, err.Error())
+			return
+		}
+
+		key, ok := linkKey(c)
+		if !ok {
+			return
+		}
+
+		stats, ok := clickStats(c, store, pending, timeout, rollup.Target{Alias: key}, q, 0)
+		if !ok {
+			return
+		}
+		stats.Alias = c.Param("alias")
+
+		c.Set("status code", http.StatusOK)
+		c.JSON(http.StatusOK, stats)
+	}
+}
+
+// clickStats проверяет параметры q и строит статистику переходов по цели t вместе с top ссылок.
+// Возвращает false, если запрос некорректен или не выполнен и ответ клиенту уже отправлен.
+func clickStats(c *gin.Context, store StatsStore, pending PendingCounter, timeout time.Duration, t rollup.Target, q statsRequest,
+	top int) (rollup.Stats, bool) {
+	query, err := rollup.Query{Interval: q.Interval, From: q.From, To: q.To, TZ: q.TZ}.Normalize(time.Now())
+	if err != nil {
+		abortWithError(c, err)
+		return rollup.Stats{}, false
+	}
+
+	ctx := c.Request.Context()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	hours, err := store.ClickRollups(ctx, t, query.From, query.To, top)
+	if err != nil {
+		abortWithError(c, err)
+		return rollup.Stats{}, false
+	}
+	// без кэша счётчик отстаёт на ещё не перенесённые переходы, но статистика остаётся доступной.
+	if n, err := pending.PendingClicks(ctx, hours.Aliases); err == nil {
+		hours.Count += n
+	}
+
+	return query.Stats(hours), true
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"Darkyfun/UrlShortener/internal/links"
+	"Darkyfun/UrlShortener/internal/rollup"
+	"Darkyfun/UrlShortener/internal/storage/persistent"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// stubRollups запоминает параметры последнего запроса свёртки и знает только ссылку "abc" на любом домене и кампанию "spring".
+type stubRollups struct {
+	target   rollup.Target
+	from, to time.Time
+	top      int
+}
+
+func (s *stubRollups) ClickRollups(_ context.Context, t rollup.Target, from, to time.Time, top int) (rollup.Hours, error) {
+	s.target, s.from, s.to, s.top = t, from, to, top
+	if _, alias := links.ParseKey(t.Alias); alias != "abc" && t.Campaign != "spring" {
+		return rollup.Hours{}, persistent.ErrNoRows
+	}
+	return rollup.Hours{
+		Links:      1,
+		Count:      7,
+		Aliases:    []string{"abc"},
+		Series:     []rollup.Bucket{{Start: from, Clicks: 4}, {Start: from.Add(time.Hour), Clicks: 1}},
+		Breakdowns: map[string][]rollup.Count{rollup.DimDevice: {{Value: rollup.DeviceMobile, Clicks: 5}}},
+		Top:        []rollup.LinkCount{{Alias: "abc", Clicks: 5}},
+	}, nil
+}
+
+// stubPending считает, что у каждой ссылки 2 перехода ещё не перенесены из кэша.
+type stubPending struct{}
+
+func (stubPending) PendingClicks(_ context.Context, aliases []string) (int64, error) {
+	return 2 * int64(len(aliases)), nil
+}
+
+func TestLinkStats(t *testing.T) {
+	store := &stubRollups{}
+	router := gin.New()
+	router.GET("/api/links/:alias/stats", LinkStats(store, stubPending{}, time.Second))
+
+	tests := []struct {
+		name       string
+		url        string
+		statusCode int
+	}{
+		{name: "defaults", url: "/api/links/abc/stats", statusCode: http.StatusOK},
+		{name: "hourly", url: "/api/links/abc/stats?interval=hour&from=2024-03-01T00:00:00Z&to=2024-03-02T00:00:00Z&tz=Europe/Berlin", statusCode: http.StatusOK},
+		{name: "unknown alias", url: "/api/links/xyz/stats", statusCode: http.StatusNotFound},
+		{name: "invalid interval", url: "/api/links/abc/stats?interval=month", statusCode: http.StatusBadRequest},
+		{name: "invalid tz", url: "/api/links/abc/stats?tz=Mars/Olympus", statusCode: http.StatusBadRequest},
+		{name: "half hour tz", url: "/api/links/abc/stats?tz=Australia/Adelaide", statusCode: http.StatusBadRequest},
+		{name: "reversed period", url: "/api/links/abc/stats?from=2024-04-01T00:00:00Z&to=2024-03-01T00:00:00Z", statusCode: http.StatusBadRequest},
+		{name: "invalid date", url: "/api/links/abc/stats?from=yesterday", statusCode: http.StatusBadRequest},
+		{name: "invalid domain", url: "/api/links/abc/stats?domain=localhost", statusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+			router.ServeHTTP(w, r)
+			assert.Equal(t, tt.statusCode, w.Result().StatusCode)
+		})
+	}
+
+	// ссылка на собственном домене ищется по ключу с доменом, ряд строится по часам в поясе запроса.
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodGet, "/api/links/abc/stats?domain=Go.Brand.com&interval=hour&from=2024-03-01T00:00:00Z&to=2024-03-01T03:00:00Z&tz=Europe/Berlin", nil)
+	router.ServeHTTP(w, r)
+	assert.Equal(t, rollup.Target{Alias: "go.brand.com/abc"}, store.target)
+	assert.Equal(t, 0, store.top)
+
+	var stats rollup.Stats
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, "abc", stats.Alias)
+	assert.Equal(t, int64(5), stats.Total)
+	assert.Equal(t, int64(9), stats.Count)
+	assert.Len(t, stats.Series, 3)
+	assert.Equal(t, []rollup.Count{{Value: rollup.DeviceMobile, Clicks: 5}}, stats.Devices)
+	assert.Equal(t, []rollup.Count{}, stats.Browsers)
+}
@@ -1,52 +1,79 @@
 package middleware
 
 import (
-	"context"
-	"errors"
-	"github.com/asaskevich/govalidator"
+	"Darkyfun/UrlShortener/internal/links"
+	"Darkyfun/UrlShortener/internal/rules"
+	"Darkyfun/UrlShortener/internal/service"
+	"Darkyfun/UrlShortener/internal/split"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"time"
 )
 
-var ErrInvalidRequest = errors.New("invalid request")
-var ErrInvalidUrl = errors.New("invalid url")
+var ErrInvalidRequest = service.ErrInvalidRequest
+var ErrInvalidUrl = service.ErrInvalidUrl
+var ErrInvalidRules = service.ErrInvalidRules
+var ErrInvalidSplit = service.ErrInvalidSplit
+var ErrInvalidMeta = service.ErrInvalidMeta
+var ErrInvalidExpiry = service.ErrInvalidExpiry
 
 // request - структура, предназначенная для парсинга JSON входящего запроса.
 type request struct {
-	Url string `json:"url"`
+	Url   string       `json:"url"`
+	Rules []rules.Rule `json:"rules"`
+	Split *split.Split `json:"split"`
+	links.Meta
+	ExpiresAt *time.Time `json:"expires_at"`
+	Domain    string     `json:"domain"`
 }
 
+// shortenRequestKey - это ключ gin.Context, под которым Validate передаёт следующим обработчикам проверенный запрос.
+const shortenRequestKey = "shorten request"
+
 // Validate валидирует содержимное входящего http-запроса.
+// Некорректный запрос отклоняется с кодом 400 и телом в формате RFC 7807.
 func Validate() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var r request
-		err := c.BindJSON(&r)
-		if err != nil {
-			c.Set("status code", http.StatusBadRequest)
-			c.String(http.StatusBadRequest, "invalid request")
-			c.Abort()
+		req, ok := bindShortenRequest(c)
+		if !ok {
 			return
 		}
 
-		if r.Url == "" {
-			c.String(http.StatusBadRequest, "%s", ErrInvalidRequest)
-			c.Set("status code", http.StatusBadRequest)
-			c.Abort()
-			return
-		}
+		c.Set(shortenRequestKey, req)
+		c.Next()
+	}
+}
 
-		if ok := govalidator.IsURL(r.Url); ok == false {
-			c.String(http.StatusBadRequest, "%s", ErrInvalidUrl)
-			c.Set("status code", http.StatusBadRequest)
-			c.Abort()
-			return
+// shortenRequest возвращает запрос, проверенный Validate, а если Validate не вызывался - разбирает и проверяет тело сам.
+// Возвращает false, если запрос некорректен и ответ клиенту уже отправлен.
+func shortenRequest(c *gin.Context) (service.ShortenRequest, bool) {
+	if v, ok := c.Get(shortenRequestKey); ok {
+		if req, ok := v.(service.ShortenRequest); ok {
+			return req, true
 		}
+	}
+	return bindShortenRequest(c)
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
-		defer cancel()
+// bindShortenRequest разбирает тело запроса и проверяет его.
+// Некорректный запрос отклоняется с кодом 400 и телом в формате RFC 7807.
+func bindShortenRequest(c *gin.Context) (service.ShortenRequest, bool) {
+	var r request
+	if err := c.ShouldBindJSON(&r); err != nil {
+		abortWithProblem(c, http.StatusBadRequest, CodeInvalidRequest, "request body should be a JSON object")
+		return service.ShortenRequest{}, false
+	}
 
-		c.Request = c.Request.WithContext(context.WithValue(ctx, "IncomeUrl", r.Url))
-		c.Next()
+	req, err := service.NewShortenRequest(r.Url, r.Rules, r.Split, r.Meta)
+	if err == nil && r.ExpiresAt != nil {
+		req, err = req.WithExpiry(*r.ExpiresAt)
+	}
+	if err == nil && r.Domain != "" {
+		req, err = req.WithDomain(r.Domain)
+	}
+	if err != nil {
+		abortWithError(c, err)
+		return service.ShortenRequest{}, false
 	}
+	return req, true
 }
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"net/http"
@@ -18,9 +19,18 @@ func TestValidate(t *testing.T) {
 		exp        string
 	}{
 		{name: "correct request", method: http.MethodPost, statusCode: http.StatusOK, body: `{"url":"https://www.google.com"}`, exp: "OK"},
-		{name: "invalid body request", method: http.MethodPost, statusCode: http.StatusBadRequest, body: "invalid body", exp: "invalid request"},
-		{name: "invalid json key request", method: http.MethodPost, statusCode: http.StatusBadRequest, body: `{"code":"https://www.google.com"}`, exp: "invalid request"},
-		{name: "invalid url", method: http.MethodPost, statusCode: http.StatusBadRequest, body: `{"code":"https:/www.google.com"}`, exp: "invalid request"},
+		{name: "invalid body request", method: http.MethodPost, statusCode: http.StatusBadRequest, body: "invalid body", exp: CodeInvalidRequest},
+		{name: "invalid json key request", method: http.MethodPost, statusCode: http.StatusBadRequest, body: `{"code":"https://www.google.com"}`, exp: CodeInvalidRequest},
+		{name: "invalid url", method: http.MethodPost, statusCode: http.StatusBadRequest, body: `{"code":"https:/www.google.com"}`, exp: CodeInvalidRequest},
+		{name: "correct rules", method: http.MethodPost, statusCode: http.StatusOK, body: `{"url":"https://www.google.com","rules":[{"platform":"ios","destination":"https://apps.apple.com"}]}`, exp: "OK"},
+		{name: "rule without condition", method: http.MethodPost, statusCode: http.StatusBadRequest, body: `{"url":"https://www.google.com","rules":[{"destination":"https://apps.apple.com"}]}`, exp: CodeInvalidRules},
+		{name: "correct split", method: http.MethodPost, statusCode: http.StatusOK, body: `{"url":"https://www.google.com","split":{"variants":[{"url":"https://a.com","weight":70},{"url":"https://b.com","weight":30}],"sticky":true}}`, exp: "OK"},
+		{name: "split with zero weight", method: http.MethodPost, statusCode: http.StatusBadRequest, body: `{"url":"https://www.google.com","split":{"variants":[{"url":"https://a.com","weight":0}]}}`, exp: CodeInvalidSplit},
+		{name: "correct meta", method: http.MethodPost, statusCode: http.StatusOK, body: `{"url":"https://www.google.com","title":"Search","tags":["promo","autumn"],"owner":"growth"}`, exp: "OK"},
+		{name: "empty tag", method: http.MethodPost, statusCode: http.StatusBadRequest, body: `{"url":"https://www.google.com","tags":[""]}`, exp: CodeInvalidMeta},
+		{name: "correct expiry", method: http.MethodPost, statusCode: http.StatusOK, body: `{"url":"https://www.google.com","expires_at":"2999-01-01T00:00:00Z"}`, exp: "OK"},
+		{name: "expiry in the past", method: http.MethodPost, statusCode: http.StatusBadRequest, body: `{"url":"https://www.google.com","expires_at":"2000-01-01T00:00:00Z"}`, exp: CodeInvalidExpiry},
+		{name: "rule with invalid destination", method: http.MethodPost, statusCode: http.StatusBadRequest, body: `{"url":"https://www.google.com","rules":[{"platform":"ios","destination":"not a url"}]}`, exp: CodeInvalidRules},
 	}
 
 	router := gin.New()
@@ -36,7 +46,16 @@ func TestValidate(t *testing.T) {
 			router.ServeHTTP(w, r)
 			assert.Nil(t, err)
 			assert.Equal(t, tt.statusCode, w.Result().StatusCode)
-			assert.Equal(t, tt.exp, w.Body.String())
+			if tt.statusCode == http.StatusOK {
+				assert.Equal(t, tt.exp, w.Body.String())
+				return
+			}
+
+			var p Problem
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &p))
+			assert.Equal(t, problemContentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, tt.exp, p.Code)
+			assert.Equal(t, tt.statusCode, p.Status)
 		})
 	}
 }
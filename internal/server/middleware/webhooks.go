@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"Darkyfun/UrlShortener/internal/webhooks"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// maxDeliveries - это наибольшее число доставок в ответе журнала.
+const maxDeliveries = 100
+
+// hookRequest - это структура, предназначенная для парсинга запроса на создание подписки.
+type hookRequest struct {
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	Secret    string   `json:"secret"`
+	Threshold int64    `json:"threshold"`
+}
+
+// deliveriesQuery - это параметры запроса журнала доставок.
+type deliveriesQuery struct {
+	Limit int `form:"limit"`
+}
+
+// CreateHook регистрирует подписку на события. Если секрет не передан, он генерируется.
+// Секрет возвращается только в ответе на создание: им подписчик проверяет заголовок webhooks.SignatureHeader.
+// Адрес подписчика должен быть https, http принимается только с allowHTTP.
+func CreateHook(store HookStore, allowHTTP bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req hookRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortWithProblem(c, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+
+		hook := webhooks.Hook{URL: req.URL, Events: req.Events, Secret: req.Secret, Threshold: req.Threshold}
+		if err := hook.Validate(allowHTTP); err != nil {
+			abortWithError(c, err)
+			return
+		}
+		if hook.Secret == "" {
+			hook.Secret = webhooks.NewSecret()
+		}
+
+		hook, err := store.CreateHook(c.Request.Context(), hook)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Set("status code", http.StatusCreated)
+		c.JSON(http.StatusCreated, hook)
+	}
+}
+
+// ListHooks возвращает все подписки без секретов.
+func ListHooks(store HookStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hooks, err := store.ListHooks(c.Request.Context())
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Set("status code", http.StatusOK)
+		c.JSON(http.StatusOK, gin.H{"webhooks": hooks})
+	}
+}
+
+// DeleteHook удаляет подписку вместе с журналом её доставок.
+func DeleteHook(store HookStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := hookID(c)
+		if !ok {
+			return
+		}
+
+		if err := store.DeleteHook(c.Request.Context(), id); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Set("status code", http.StatusNoContent)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// HookDeliveries возвращает журнал последних доставок подписки, включая недоставленные события.
+func HookDeliveries(store HookStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := hookID(c)
+		if !ok {
+			return
+		}
+
+		var q deliveriesQuery
+		if err := c.ShouldBindQuery(&q); err != nil {
+			abortWithProblem(c, http.StatusBadRequest, CodeInvalidQuery, err.Error())
+			return
+		}
+		if q.Limit <= 0 || q.Limit > maxDeliveries {
+			q.Limit = maxDeliveries
+		}
+
+		deliveries, err := store.HookDeliveries(c.Request.Context(), id, q.Limit)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Set("status code", http.StatusOK)
+		c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+	}
+}
+
+// hookID разбирает идентификатор подписки из пути и отвечает ошибкой, если он некорректен.
+func hookID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		abortWithProblem(c, http.StatusBadRequest, CodeInvalidRequest, "webhook id must be a positive integer")
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"Darkyfun/UrlShortener/internal/storage/persistent"
+	"Darkyfun/UrlShortener/internal/webhooks"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// stubHooks хранит подписки в памяти.
+type stubHooks struct {
+	hooks []webhooks.Hook
+	limit int
+}
+
+func (s *stubHooks) CreateHook(_ context.Context, h webhooks.Hook) (webhooks.Hook, error) {
+	h.ID = int64(len(s.hooks) + 1)
+	s.hooks = append(s.hooks, h)
+	return h, nil
+}
+
+func (s *stubHooks) ListHooks(context.Context) ([]webhooks.Hook, error) {
+	return s.hooks, nil
+}
+
+func (s *stubHooks) DeleteHook(_ context.Context, id int64) error {
+	if id > int64(len(s.hooks)) {
+		return persistent.ErrNoRows
+	}
+	return nil
+}
+
+func (s *stubHooks) HookDeliveries(_ context.Context, id int64, limit int) ([]webhooks.Delivery, error) {
+	if id > int64(len(s.hooks)) {
+		return nil, persistent.ErrNoRows
+	}
+	s.limit = limit
+	return []webhooks.Delivery{{ID: 1, HookID: id, Event: webhooks.EventLinkCreated, Status: webhooks.StatusDelivered}}, nil
+}
+
+func TestWebhooks(t *testing.T) {
+	store := &stubHooks{}
+	router := gin.New()
+	router.POST("/api/webhooks", CreateHook(store, false))
+	router.GET("/api/webhooks", ListHooks(store))
+	router.DELETE("/api/webhooks/:id", DeleteHook(store))
+	router.GET("/api/webhooks/:id/deliveries", HookDeliveries(store))
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		statusCode int
+		code       string
+	}{
+		{name: "create", method: http.MethodPost, url: "/api/webhooks", body: `{"url": "https://crm.example.com/hook", "events": ["link.click_threshold"], "threshold": 1}`, statusCode: http.StatusCreated},
+		{name: "invalid event", method: http.MethodPost, url: "/api/webhooks", body: `{"url": "https://crm.example.com/hook", "events": ["link.visited"]}`, statusCode: http.StatusBadRequest, code: CodeInvalidWebhook},
+		{name: "invalid json", method: http.MethodPost, url: "/api/webhooks", body: `{`, statusCode: http.StatusBadRequest, code: CodeInvalidRequest},
+		{name: "list", method: http.MethodGet, url: "/api/webhooks", statusCode: http.StatusOK},
+		{name: "deliveries", method: http.MethodGet, url: "/api/webhooks/1/deliveries?limit=1000", statusCode: http.StatusOK},
+		{name: "unknown hook deliveries", method: http.MethodGet, url: "/api/webhooks/7/deliveries", statusCode: http.StatusNotFound, code: CodeNotFound},
+		{name: "invalid id", method: http.MethodDelete, url: "/api/webhooks/first", statusCode: http.StatusBadRequest, code: CodeInvalidRequest},
+		{name: "delete unknown", method: http.MethodDelete, url: "/api/webhooks/7", statusCode: http.StatusNotFound, code: CodeNotFound},
+		{name: "delete", method: http.MethodDelete, url: "/api/webhooks/1", statusCode: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest(tt.method, tt.url, bytes.NewBufferString(tt.body))
+			router.ServeHTTP(w, r)
+			assert.Equal(t, tt.statusCode, w.Result().StatusCode)
+
+			if tt.code != "" {
+				var p Problem
+				assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &p))
+				assert.Equal(t, tt.code, p.Code)
+			}
+		})
+	}
+
+	// секрет генерируется, если клиент его не передал, а журнал доставок ограничен maxDeliveries.
+	assert.Len(t, store.hooks[0].Secret, 64)
+	assert.Equal(t, maxDeliveries, store.limit)
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"Darkyfun/UrlShortener/internal/workspace"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// workspaceRequest - это структура, предназначенная для парсинга запроса на создание рабочего пространства.
+type workspaceRequest struct {
+	Name     string `json:"name"`
+	MaxLinks int64  `json:"max_links"`
+}
+
+// memberRequest - это структура, предназначенная для парсинга запроса на добавление участника.
+type memberRequest struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// CreateWorkspace создаёт рабочее пространство с квотой ссылок.
+func CreateWorkspace(store WorkspaceStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req workspaceRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortWithProblem(c, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+
+		w := workspace.Workspace{Name: req.Name, MaxLinks: req.MaxLinks}
+		if err := w.Validate(); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		w, err := store.CreateWorkspace(c.Request.Context(), w)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Set("status code", http.StatusCreated)
+		c.JSON(http.StatusCreated, w)
+	}
+}
+
+// ListWorkspaces возвращает все рабочие пространства.
+func ListWorkspaces(store WorkspaceStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := store.ListWorkspaces(c.Request.Context())
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Set("status code", http.StatusOK)
+		c.JSON(http.StatusOK, gin.H{"workspaces": list})
+	}
+}
+
+// AddMember добавляет участника в рабочее пространство и выдаёт ему ключ API.
+// Ключ возвращается только в ответе на добавление: в базе данных хранится лишь его хэш.
+func AddMember(store WorkspaceStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil || id <= 0 {
+			abortWithProblem(c, http.StatusBadRequest, CodeInvalidRequest, "workspace id must be a positive integer")
+			return
+		}
+
+		var req memberRequest
+		if err = c.ShouldBindJSON(&req); err != nil {
+			abortWithProblem(c, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+
+		m := workspace.Member{WorkspaceID: id, Name: req.Name, Role: req.Role}
+		if err = m.Validate(); err != nil {
+			abortWithError(c, err)
+			return
+		}
+		if m.Role == "" {
+			m.Role = workspace.RoleMember
+		}
+
+		key := workspace.NewKey()
+		m, err = store.AddMember(c.Request.Context(), m, workspace.HashKey(key))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		m.Key = key
+
+		c.Set("status code", http.StatusCreated)
+		c.JSON(http.StatusCreated, m)
+	}
+}
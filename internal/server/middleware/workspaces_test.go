@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"Darkyfun/UrlShortener/internal/storage/persistent"
+	"Darkyfun/UrlShortener/internal/workspace"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// stubWorkspaces хранит рабочие пространства и ключи API в памяти.
+type stubWorkspaces struct {
+	list []workspace.Workspace
+	keys map[string]int64
+}
+
+func (s *stubWorkspaces) CreateWorkspace(_ context.Context, w workspace.Workspace) (workspace.Workspace, error) {
+	w.ID = int64(len(s.list) + 1)
+	s.list = append(s.list, w)
+	return w, nil
+}
+
+func (s *stubWorkspaces) ListWorkspaces(context.Context) ([]workspace.Workspace, error) {
+	return s.list, nil
+}
+
+func (s *stubWorkspaces) AddMember(_ context.Context, m workspace.Member, keyHash []byte) (workspace.Member, error) {
+	if m.WorkspaceID > int64(len(s.list)) {
+		return workspace.Member{}, persistent.ErrNoRows
+	}
+	s.keys[string(keyHash)] = m.WorkspaceID
+	return m, nil
+}
+
+func (s *stubWorkspaces) WorkspaceByKey(_ context.Context, keyHash []byte) (int64, error) {
+	id, ok := s.keys[string(keyHash)]
+	if !ok {
+		return 0, persistent.ErrNoRows
+	}
+	return id, nil
+}
+
+func TestWorkspaces(t *testing.T) {
+	store := &stubWorkspaces{keys: make(map[string]int64)}
+	router := gin.New()
+	admin := router.Group("/admin", AdminAuth("admin-token"))
+	admin.POST("/workspaces", CreateWorkspace(store))
+	admin.GET("/workspaces", ListWorkspaces(store))
+	admin.POST("/workspaces/:id/members", AddMember(store))
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		token      string
+		body       string
+		statusCode int
+		code       string
+	}{
+		{name: "no token", method: http.MethodGet, url: "/admin/workspaces", statusCode: http.StatusUnauthorized, code: CodeUnauthorized},
+		{name: "wrong token", method: http.MethodGet, url: "/admin/workspaces", token: "guess", statusCode: http.StatusUnauthorized, code: CodeUnauthorized},
+		{name: "create", method: http.MethodPost, url: "/admin/workspaces", token: "admin-token", body: `{"name": "growth", "max_links": 1000}`, statusCode: http.StatusCreated},
+		{name: "invalid workspace", method: http.MethodPost, url: "/admin/workspaces", token: "admin-token", body: `{"name": "", "max_links": 1}`, statusCode: http.StatusBadRequest, code: CodeInvalidWorkspace},
+		{name: "list", method: http.MethodGet, url: "/admin/workspaces", token: "admin-token", statusCode: http.StatusOK},
+		{name: "invalid member", method: http.MethodPost, url: "/admin/workspaces/1/members", token: "admin-token", body: `{"name": "alice", "role": "root"}`, statusCode: http.StatusBadRequest, code: CodeInvalidMember},
+		{name: "unknown workspace", method: http.MethodPost, url: "/admin/workspaces/7/members", token: "admin-token", body: `{"name": "alice"}`, statusCode: http.StatusNotFound, code: CodeNotFound},
+		{name: "invalid id", method: http.MethodPost, url: "/admin/workspaces/growth/members", token: "admin-token", body: `{"name": "alice"}`, statusCode: http.StatusBadRequest, code: CodeInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest(tt.method, tt.url, bytes.NewBufferString(tt.body))
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			router.ServeHTTP(w, r)
+			assert.Equal(t, tt.statusCode, w.Result().StatusCode)
+
+			if tt.code != "" {
+				var p Problem
+				assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &p))
+				assert.Equal(t, tt.code, p.Code)
+			}
+		})
+	}
+
+	// ключ участника возвращается один раз, хранится только его хэш.
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPost, "/admin/workspaces/1/members", bytes.NewBufferString(`{"name": "alice"}`))
+	r.Header.Set("Authorization", "Bearer admin-token")
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+
+	var m workspace.Member
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &m))
+	assert.Equal(t, workspace.RoleMember, m.Role)
+	assert.NotEmpty(t, m.Key)
+	assert.Equal(t, int64(1), store.keys[string(workspace.HashKey(m.Key))])
+}
+
+func TestAuthenticate(t *testing.T) {
+	store := &stubWorkspaces{keys: map[string]int64{string(workspace.HashKey("sk_growth")): 3}}
+
+	tests := []struct {
+		name       string
+		require    bool
+		header     string
+		value      string
+		statusCode int
+		workspace  int64
+	}{
+		{name: "no key", statusCode: http.StatusOK, workspace: workspace.Default},
+		{name: "no key required", require: true, statusCode: http.StatusUnauthorized},
+		{name: "bearer", header: "Authorization", value: "Bearer sk_growth", statusCode: http.StatusOK, workspace: 3},
+		{name: "header", require: true, header: APIKeyHeader, value: "sk_growth", statusCode: http.StatusOK, workspace: 3},
+		{name: "unknown key", header: APIKeyHeader, value: "sk_guess", statusCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/api/links", Authenticate(store, tt.require), func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"workspace": *workspace.Scope(c.Request.Context())})
+			})
+
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest(http.MethodGet, "/api/links", nil)
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			router.ServeHTTP(w, r)
+			assert.Equal(t, tt.statusCode, w.Result().StatusCode)
+
+			if tt.statusCode == http.StatusOK {
+				var res struct{ Workspace int64 }
+				assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &res))
+				assert.Equal(t, tt.workspace, res.Workspace)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"net/url"
+	"strings"
+)
+
+// SetBlocklist заменяет список доменов, на которые нельзя создавать короткие ссылки.
+// Домен блокируется вместе со всеми поддоменами. Метод безопасно вызывать во время работы.
+func (s *Shortener) SetBlocklist(domains []string) {
+	normalized := make([]string, 0, len(domains))
+	for _, d := range domains {
+		d = strings.Trim(strings.ToLower(strings.TrimSpace(d)), ".")
+		if d != "" {
+			normalized = append(normalized, d)
+		}
+	}
+	s.blocklist.Store(&normalized)
+}
+
+// blocked сообщает, ведёт ли хотя бы один адрес запроса на заблокированный домен.
+func (s *Shortener) blocked(req ShortenRequest) bool {
+	domains := s.blocklist.Load()
+	if domains == nil || len(*domains) == 0 {
+		return false
+	}
+
+	targets := []string{req.Url}
+	for _, r := range req.Rules {
+		targets = append(targets, r.Destination)
+	}
+	for _, v := range req.Split.Variants {
+		targets = append(targets, v.Url)
+	}
+
+	for _, target := range targets {
+		if blockedHost(hostOf(target), *domains) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostOf возвращает имя хоста адреса в нижнем регистре. Адрес без схемы разбирается как http.
+func hostOf(raw string) string {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+}
+
+// blockedHost сообщает, совпадает ли host с одним из доменов или является его поддоменом.
+func blockedHost(host string, domains []string) bool {
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"Darkyfun/UrlShortener/internal/clicks"
+	"Darkyfun/UrlShortener/internal/links"
+	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/rules"
+	"Darkyfun/UrlShortener/internal/split"
+	"context"
+	"time"
+)
+
+type Cacher interface {
+	Set(ctx context.Context, key string, value any, ttl time.Duration) error
+	Get(ctx context.Context, key string) (string, error)
+	Expire(ctx context.Context, ttl time.Duration, keys ...string) error
+	Hit(ctx context.Context, alias string, window time.Duration) (int64, error)
+	CountClick(ctx context.Context, alias string) error
+	Purge(ctx context.Context, alias string) error
+}
+
+type Storager interface {
+	GetAlias(ctx context.Context, domain, orig string) (string, error)
+	GetOriginal(ctx context.Context, alias string) (string, error)
+	Create(ctx context.Context, alias string, l links.Draft) error
+	SetMeta(ctx context.Context, alias string, meta links.Meta) error
+	Delete(ctx context.Context, alias string) error
+	GetRules(ctx context.Context, alias string) (rules.Set, error)
+	GetSplit(ctx context.Context, alias string) (split.Split, error)
+	GetExpiry(ctx context.Context, alias string) (time.Time, error)
+	ClickStats(ctx context.Context, alias string) (clicks.Stats, error)
+}
+
+type ClickRecorder interface {
+	Record(ctx context.Context, click clicks.Click)
+}
+
+type Logger interface {
+	Log(ctx context.Context, level string, msg string, fields ...logging.Field)
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"Darkyfun/UrlShortener/internal/clicks"
+	"Darkyfun/UrlShortener/internal/links"
+	"Darkyfun/UrlShortener/internal/rules"
+	"Darkyfun/UrlShortener/internal/split"
+	"context"
+	"time"
+)
+
+// Deadlines - это ограничения времени на отдельные обращения к хранилищам.
+// Каждое обращение получает контекст запроса, дополнительно ограниченный соответствующим значением,
+// поэтому отменённый клиентом запрос сразу освобождает соединения. Нулевое значение снимает ограничение.
+type Deadlines struct {
+	// Cache ограничивает чтение и запись кэша.
+	Cache time.Duration
+	// StoreRead ограничивает чтение из SQL-базы данных.
+	StoreRead time.Duration
+	// StoreWrite ограничивает запись в SQL-базу данных.
+	StoreWrite time.Duration
+}
+
+// DefaultDeadlines - это ограничения времени по умолчанию.
+var DefaultDeadlines = Deadlines{Cache: time.Second, StoreRead: 3 * time.Second, StoreWrite: 3 * time.Second}
+
+// SetDeadlines задаёт ограничения времени на обращения к хранилищам. Метод нужно вызывать до начала обработки запросов.
+func (s *Shortener) SetDeadlines(d Deadlines) {
+	s.deadlines = d
+}
+
+// within возвращает контекст, ограниченный d, или просто отменяемый контекст, если d не задано.
+func within(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// deadlineCache ограничивает время каждого обращения к кэшу.
+type deadlineCache struct {
+	cache Cacher
+	d     *Deadlines
+}
+
+func (c deadlineCache) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	ctx, cancel := within(ctx, c.d.Cache)
+	defer cancel()
+	return c.cache.Set(ctx, key, value, ttl)
+}
+
+func (c deadlineCache) Get(ctx context.Context, key string) (string, error) {
+	ctx, cancel := within(ctx, c.d.Cache)
+	defer cancel()
+	return c.cache.Get(ctx, key)
+}
+
+func (c deadlineCache) Expire(ctx context.Context, ttl time.Duration, keys ...string) error {
+	ctx, cancel := within(ctx, c.d.Cache)
+	defer cancel()
+	return c.cache.Expire(ctx, ttl, keys...)
+}
+
+func (c deadlineCache) Hit(ctx context.Context, alias string, window time.Duration) (int64, error) {
+	ctx, cancel := within(ctx, c.d.Cache)
+	defer cancel()
+	return c.cache.Hit(ctx, alias, window)
+}
+
+func (c deadlineCache) CountClick(ctx context.Context, alias string) error {
+	ctx, cancel := within(ctx, c.d.Cache)
+	defer cancel()
+	return c.cache.CountClick(ctx, alias)
+}
+
+func (c deadlineCache) Purge(ctx context.Context, alias string) error {
+	ctx, cancel := within(ctx, c.d.Cache)
+	defer cancel()
+	return c.cache.Purge(ctx, alias)
+}
+
+// deadlineStore ограничивает время каждого обращения к SQL-базе данных.
+type deadlineStore struct {
+	store Storager
+	d     *Deadlines
+}
+
+func (s deadlineStore) GetAlias(ctx context.Context, domain, orig string) (string, error) {
+	ctx, cancel := within(ctx, s.d.StoreRead)
+	defer cancel()
+	return s.store.GetAlias(ctx, domain, orig)
+}
+
+func (s deadlineStore) GetOriginal(ctx context.Context, alias string) (string, error) {
+	ctx, cancel := within(ctx, s.d.StoreRead)
+	defer cancel()
+	return s.store.GetOriginal(ctx, alias)
+}
+
+func (s deadlineStore) Create(ctx context.Context, alias string, l links.Draft) error {
+	ctx, cancel := within(ctx, s.d.StoreWrite)
+	defer cancel()
+	return s.store.Create(ctx, alias, l)
+}
+
+func (s deadlineStore) SetMeta(ctx context.Context, alias string, meta links.Meta) error {
+	ctx, cancel := within(ctx, s.d.StoreWrite)
+	defer cancel()
+	return s.store.SetMeta(ctx, alias, meta)
+}
+
+func (s deadlineStore) Delete(ctx context.Context, alias string) error {
+	ctx, cancel := within(ctx, s.d.StoreWrite)
+	defer cancel()
+	return s.store.Delete(ctx, alias)
+}
+
+func (s deadlineStore) GetRules(ctx context.Context, alias string) (rules.Set, error) {
+	ctx, cancel := within(ctx, s.d.StoreRead)
+	defer cancel()
+	return s.store.GetRules(ctx, alias)
+}
+
+func (s deadlineStore) GetSplit(ctx context.Context, alias string) (split.Split, error) {
+	ctx, cancel := within(ctx, s.d.StoreRead)
+	defer cancel()
+	return s.store.GetSplit(ctx, alias)
+}
+
+func (s deadlineStore) GetExpiry(ctx context.Context, alias string) (time.Time, error) {
+	ctx, cancel := within(ctx, s.d.StoreRead)
+	defer cancel()
+	return s.store.GetExpiry(ctx, alias)
+}
+
+func (s deadlineStore) ClickStats(ctx context.Context, alias string) (clicks.Stats, error) {
+	ctx, cancel := within(ctx, s.d.StoreRead)
+	defer cancel()
+	return s.store.ClickStats(ctx, alias)
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"Darkyfun/UrlShortener/internal/links"
+	"Darkyfun/UrlShortener/internal/workspace"
+	"context"
+)
+
+// SetDomains заменяет список собственных доменов всех рабочих пространств, псевдонимы которых не пересекаются
+// с псевдонимами других доменов. Метод безопасно вызывать во время работы.
+func (s *Shortener) SetDomains(list []links.Domain) {
+	domains := make(map[string]int64, len(list))
+	for _, d := range list {
+		if host, err := links.NormalizeDomain(d.Host); err == nil {
+			domains[host] = d.WorkspaceID
+		}
+	}
+	s.domains.Store(&domains)
+}
+
+// HasDomain сообщает, зарегистрирован ли собственный домен host.
+func (s *Shortener) HasDomain(host string) bool {
+	_, ok := s.domainOwner(host)
+	return ok
+}
+
+// ownsDomain сообщает, может ли запрос создавать ссылки на домене host: домен должен быть зарегистрирован
+// в рабочем пространстве запроса. Запросы без рабочего пространства могут использовать любой домен.
+func (s *Shortener) ownsDomain(ctx context.Context, host string) bool {
+	owner, ok := s.domainOwner(host)
+	if scope := workspace.Scope(ctx); ok && scope != nil {
+		return owner == *scope
+	}
+	return ok
+}
+
+// domainOwner возвращает рабочее пространство, которому принадлежит домен host.
+func (s *Shortener) domainOwner(host string) (int64, bool) {
+	domains := s.domains.Load()
+	if domains == nil {
+		return 0, false
+	}
+	owner, ok := (*domains)[host]
+	return owner, ok
+}
+
+// LinkKey возвращает ключ ссылки с псевдонимом alias, запрошенной через хост host.
+// На зарегистрированном собственном домене псевдоним ищется среди ссылок этого домена, на любом другом хосте -
+// среди ссылок без домена.
+func (s *Shortener) LinkKey(host, alias string) string {
+	if s.HasDomain(host) {
+		return links.Key(host, alias)
+	}
+	return alias
+}
+
+// WithDomain возвращает запрос на создание ссылки на собственном домене domain.
+func (r ShortenRequest) WithDomain(domain string) (ShortenRequest, error) {
+	host, err := links.NormalizeDomain(domain)
+	if err != nil {
+		return ShortenRequest{}, err
+	}
+	r.Domain = host
+	return r, nil
+}
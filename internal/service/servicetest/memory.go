@@ -0,0 +1,294 @@
+// Package servicetest содержит хранилища в памяти для тестирования service.Shortener и транспортов поверх него.
+package servicetest
+
+import (
+	"Darkyfun/UrlShortener/internal/clicks"
+	"Darkyfun/UrlShortener/internal/links"
+	"Darkyfun/UrlShortener/internal/rules"
+	"Darkyfun/UrlShortener/internal/split"
+	"Darkyfun/UrlShortener/internal/storage/cache"
+	"Darkyfun/UrlShortener/internal/storage/persistent"
+	"Darkyfun/UrlShortener/internal/workspace"
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Cache - это кэш в памяти. Время жизни записей запоминается, но записи не истекают.
+type Cache struct {
+	mu   sync.Mutex
+	data map[string]string
+	ttl  map[string]time.Duration
+	hits map[string]int64
+}
+
+// NewCache возвращает пустой кэш в памяти.
+func NewCache() *Cache {
+	return &Cache{data: make(map[string]string), ttl: make(map[string]time.Duration), hits: make(map[string]int64)}
+}
+
+func (c *Cache) Set(_ context.Context, key string, value any, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch v := value.(type) {
+	case []byte:
+		c.data[key] = string(v)
+	default:
+		c.data[key] = fmt.Sprint(v)
+	}
+	c.ttl[key] = ttl
+	return nil
+}
+
+func (c *Cache) Expire(_ context.Context, ttl time.Duration, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if _, ok := c.data[key]; ok {
+			c.ttl[key] = ttl
+		}
+	}
+	return nil
+}
+
+// Hit считает переходы без учёта окна.
+func (c *Cache) Hit(_ context.Context, alias string, _ time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hits[alias]++
+	return c.hits[alias], nil
+}
+
+// CountClick увеличивает счётчик переходов под ключом cache.CountKey.
+func (c *Cache) CountClick(_ context.Context, alias string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n, _ := strconv.ParseInt(c.data[cache.CountKey(alias)], 10, 64)
+	c.data[cache.CountKey(alias)] = strconv.FormatInt(n+1, 10)
+	return nil
+}
+
+// TTL возвращает время жизни, с которым последний раз была записана или продлена запись.
+func (c *Cache) TTL(key string) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ttl[key]
+}
+
+// Purge удаляет все записи кэша псевдонима.
+func (c *Cache) Purge(_ context.Context, alias string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range cache.Keys(alias) {
+		delete(c.data, key)
+		delete(c.ttl, key)
+	}
+	return nil
+}
+
+func (c *Cache) Get(_ context.Context, key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	v, ok := c.data[key]
+	if !ok {
+		return "", cache.ErrCacheMiss
+	}
+	return v, nil
+}
+
+// link - это строка таблицы url.
+type link struct {
+	ws    int64
+	orig  string
+	rules rules.Set
+	split split.Split
+	meta  links.Meta
+	exp   time.Time
+}
+
+// Store - это SQL-база данных в памяти. Как и persistent.Db, она ограничивает запросы рабочим пространством из контекста.
+type Store struct {
+	mu     sync.Mutex
+	links  map[string]*link
+	clicks []clicks.Click
+}
+
+// NewStore возвращает пустую базу данных в памяти.
+func NewStore() *Store {
+	return &Store{links: make(map[string]*link)}
+}
+
+func (s *Store) GetAlias(ctx context.Context, domain, orig string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, l := range s.links {
+		if d, _ := links.ParseKey(key); d == domain && l.orig == orig && l.exp.IsZero() && visible(ctx, l) {
+			return key, nil
+		}
+	}
+	return "", persistent.ErrNoRows
+}
+
+func (s *Store) GetOriginal(ctx context.Context, alias string) (string, error) {
+	l, err := s.get(ctx, alias)
+	if err != nil {
+		return "", err
+	}
+	return l.orig, nil
+}
+
+// Set сохраняет ссылку без учёта квоты рабочего пространства.
+func (s *Store) Set(ctx context.Context, alias string, orig string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.links[alias]; ok {
+		return persistent.ErrAlreadyExists
+	}
+	s.links[alias] = &link{ws: workspace.Of(ctx), orig: orig}
+	return nil
+}
+
+// Create сохраняет ссылку вместе с атрибутами без учёта квоты рабочего пространства.
+func (s *Store) Create(ctx context.Context, alias string, l links.Draft) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.links[alias]; ok {
+		return persistent.ErrAlreadyExists
+	}
+	s.links[alias] = &link{ws: workspace.Of(ctx), orig: l.Original, rules: l.Rules, split: l.Split, meta: l.Meta, exp: l.ExpiresAt}
+	return nil
+}
+
+func (s *Store) GetRules(ctx context.Context, alias string) (rules.Set, error) {
+	l, err := s.get(ctx, alias)
+	if err != nil {
+		return nil, err
+	}
+	return l.rules, nil
+}
+
+func (s *Store) SetRules(ctx context.Context, alias string, set rules.Set) error {
+	return s.update(ctx, alias, func(l *link) { l.rules = set })
+}
+
+func (s *Store) GetSplit(ctx context.Context, alias string) (split.Split, error) {
+	l, err := s.get(ctx, alias)
+	if err != nil {
+		return split.Split{}, err
+	}
+	return l.split, nil
+}
+
+func (s *Store) SetSplit(ctx context.Context, alias string, ab split.Split) error {
+	return s.update(ctx, alias, func(l *link) { l.split = ab })
+}
+
+func (s *Store) SetMeta(ctx context.Context, alias string, meta links.Meta) error {
+	return s.update(ctx, alias, func(l *link) { l.meta = meta })
+}
+
+// Delete удаляет ссылку, переходы по ней сохраняются.
+func (s *Store) Delete(ctx context.Context, alias string) error {
+	if _, err := s.get(ctx, alias); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.links, alias)
+	return nil
+}
+
+func (s *Store) GetExpiry(ctx context.Context, alias string) (time.Time, error) {
+	l, err := s.get(ctx, alias)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return l.exp, nil
+}
+
+func (s *Store) SetExpiry(ctx context.Context, alias string, exp time.Time) error {
+	return s.update(ctx, alias, func(l *link) { l.exp = exp })
+}
+
+func (s *Store) SaveClick(_ context.Context, click clicks.Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clicks = append(s.clicks, click)
+	return nil
+}
+
+func (s *Store) ClickStats(ctx context.Context, alias string) (clicks.Stats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if l, ok := s.links[alias]; workspace.Scope(ctx) != nil && (!ok || !visible(ctx, l)) {
+		return clicks.Stats{}, persistent.ErrNoRows
+	}
+
+	stats := clicks.Stats{Alias: alias}
+	for _, c := range s.clicks {
+		if c.Alias != alias {
+			continue
+		}
+		stats.Total++
+		if c.Variant != "" {
+			if stats.Variants == nil {
+				stats.Variants = make(map[string]int64)
+			}
+			stats.Variants[c.Variant]++
+		}
+	}
+	return stats, nil
+}
+
+// Recorder записывает переходы в Store синхронно.
+type Recorder struct {
+	Store *Store
+}
+
+func (r Recorder) Record(_ context.Context, click clicks.Click) {
+	_ = r.Store.SaveClick(context.Background(), click)
+}
+
+func (s *Store) get(ctx context.Context, alias string) (*link, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.links[alias]
+	if !ok || !visible(ctx, l) {
+		return nil, persistent.ErrNoRows
+	}
+	return l, nil
+}
+
+func (s *Store) update(ctx context.Context, alias string, fn func(l *link)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.links[alias]
+	if !ok || !visible(ctx, l) {
+		return persistent.ErrNoRows
+	}
+	fn(l)
+	return nil
+}
+
+// visible сообщает, видна ли ссылка запросу с учётом его рабочего пространства.
+func visible(ctx context.Context, l *link) bool {
+	scope := workspace.Scope(ctx)
+	return scope == nil || *scope == l.ws
+}
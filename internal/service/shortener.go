@@ -0,0 +1,464 @@
+// Package service содержит бизнес-логику сокращения ссылок и перенаправления, не зависящую от транспорта (HTTP или gRPC).
+package service
+
+import (
+	"Darkyfun/UrlShortener/internal/aliasname"
+	"Darkyfun/UrlShortener/internal/clicks"
+	"Darkyfun/UrlShortener/internal/links"
+	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/rules"
+	"Darkyfun/UrlShortener/internal/split"
+	"Darkyfun/UrlShortener/internal/storage"
+	"Darkyfun/UrlShortener/internal/storage/cache"
+	"Darkyfun/UrlShortener/internal/webhooks"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var ErrInvalidRequest = errors.New("invalid request")
+var ErrInvalidUrl = links.ErrInvalidUrl
+var ErrInvalidRules = links.ErrInvalidRules
+var ErrInvalidSplit = links.ErrInvalidSplit
+var ErrInvalidMeta = links.ErrInvalidMeta
+var ErrBlockedUrl = errors.New("url domain is blocked")
+var ErrInvalidExpiry = errors.New("expiry should be in the future")
+var ErrUnknownDomain = errors.New("domain is not registered in the workspace")
+var ErrNotFound = fmt.Errorf("alias %w", storage.ErrNotFound)
+
+// aliasLen - это длина генерируемого псевдонима.
+const aliasLen = 10
+
+// Shortener - это структура, реализующая сокращение ссылок, перенаправление и статистику переходов.
+type Shortener struct {
+	cache    Cacher
+	store    Storager
+	log      Logger
+	recorder ClickRecorder
+	addr     string
+
+	// blocklist - домены, на которые нельзя создавать ссылки, меняется во время работы.
+	blocklist atomic.Pointer[[]string]
+	// domains - зарегистрированные собственные домены, меняются во время работы.
+	domains atomic.Pointer[map[string]int64]
+	// deadlines ограничивают время обращений к cache и store.
+	deadlines Deadlines
+	// ttl определяет время жизни записей кэша.
+	ttl cache.TTLPolicy
+	// events получает события жизненного цикла ссылок.
+	events webhooks.Publisher
+}
+
+// NewShortener возвращает Shortener, работающий с указанными кэшем и SQL-базой данных.
+// addr - это адрес сервера, из которого строятся короткие ссылки.
+// Обращения к хранилищам ограничены DefaultDeadlines, пока не вызван SetDeadlines,
+// а записи кэша живут по cache.DefaultTTLPolicy, пока не вызван SetTTLPolicy.
+func NewShortener(cacher Cacher, store Storager, logger Logger, recorder ClickRecorder, addr string) *Shortener {
+	s := &Shortener{
+		log:       logger,
+		recorder:  recorder,
+		addr:      addr,
+		deadlines: DefaultDeadlines,
+		ttl:       cache.DefaultTTLPolicy,
+		events:    webhooks.Discard{},
+	}
+	s.cache = deadlineCache{cache: cacher, d: &s.deadlines}
+	s.store = deadlineStore{store: store, d: &s.deadlines}
+	return s
+}
+
+// SetPublisher задаёт получателя событий жизненного цикла ссылок. Метод нужно вызывать до начала обработки запросов.
+func (s *Shortener) SetPublisher(p webhooks.Publisher) {
+	s.events = p
+}
+
+// SetTTLPolicy задаёт правила выбора времени жизни записей кэша. Метод нужно вызывать до начала обработки запросов.
+func (s *Shortener) SetTTLPolicy(p cache.TTLPolicy) {
+	s.ttl = p
+}
+
+// ShortenRequest - это проверенный запрос на создание короткой ссылки.
+type ShortenRequest struct {
+	Url   string
+	Rules rules.Set
+	Split split.Split
+	Meta  links.Meta
+	// ExpiresAt - срок действия ссылки, нулевое время означает бессрочную ссылку.
+	ExpiresAt time.Time
+	// Domain - собственный домен ссылки, пустая строка означает ссылку без домена.
+	Domain string
+	// Alias - выбранный псевдоним ссылки, пустая строка означает случайный псевдоним.
+	Alias string
+}
+
+// plain сообщает, что ссылка состоит только из оригинального URL и её можно переиспользовать.
+func (r ShortenRequest) plain() bool {
+	return len(r.Rules) == 0 && r.Split.Empty() && r.Meta.Empty() && r.ExpiresAt.IsZero() && r.Alias == ""
+}
+
+// WithAlias возвращает запрос на создание ссылки с псевдонимом alias, который должен проходить links.ValidAlias.
+func (r ShortenRequest) WithAlias(alias string) (ShortenRequest, error) {
+	if !links.ValidAlias(alias) {
+		return ShortenRequest{}, links.ErrInvalidAlias
+	}
+	r.Alias = alias
+	return r, nil
+}
+
+// WithExpiry возвращает запрос со сроком действия ссылки exp. Срок должен быть в будущем.
+func (r ShortenRequest) WithExpiry(exp time.Time) (ShortenRequest, error) {
+	if !exp.After(time.Now()) {
+		return ShortenRequest{}, ErrInvalidExpiry
+	}
+	r.ExpiresAt = exp
+	return r, nil
+}
+
+// NewShortenRequest проверяет входные данные и возвращает запрос на создание короткой ссылки, см. links.NewDraft.
+func NewShortenRequest(url string, rr []rules.Rule, s *split.Split, meta links.Meta) (ShortenRequest, error) {
+	if url == "" {
+		return ShortenRequest{}, ErrInvalidRequest
+	}
+
+	d, err := links.NewDraft(url, rr, s, meta)
+	if err != nil {
+		return ShortenRequest{}, err
+	}
+	return ShortenRequest{Url: d.Original, Rules: d.Rules, Split: d.Split, Meta: d.Meta}, nil
+}
+
+// Link - это созданная короткая ссылка.
+type Link struct {
+	Alias    string
+	ShortUrl string
+}
+
+// ShortUrl возвращает короткую ссылку по ключу ссылки (см. links.Key).
+// Ссылка на собственном домене ведёт на этот домен по https, остальные - на адрес сервера.
+func (s *Shortener) ShortUrl(key string) string {
+	domain, alias := links.ParseKey(key)
+	if domain != "" {
+		return "https://" + domain + "/redirect/" + alias
+	}
+	return "http://" + "localhost" + s.addr + "/redirect/" + alias
+}
+
+// link возвращает созданную ссылку по её ключу.
+func (s *Shortener) link(key string) Link {
+	_, alias := links.ParseKey(key)
+	return Link{Alias: alias, ShortUrl: s.ShortUrl(key)}
+}
+
+// Shorten создаёт короткую ссылку. Для оригинального URL без правил, A/B-распределения и метаданных
+// переиспользуется уже существующий псевдоним, иначе всегда создаётся новый.
+// Ссылки на домены из списка блокировки не создаются. Псевдоним ссылки на собственном домене уникален только на этом домене,
+// а сам домен должен принадлежать рабочему пространству запроса. Переиспользуются только ссылки того же рабочего пространства.
+// Если псевдоним выбран в запросе, а он уже занят, возвращается ошибка storage.ErrConflict.
+func (s *Shortener) Shorten(ctx context.Context, req ShortenRequest) (Link, error) {
+	if s.blocked(req) {
+		return Link{}, ErrBlockedUrl
+	}
+	if req.Domain != "" && !s.ownsDomain(ctx, req.Domain) {
+		return Link{}, ErrUnknownDomain
+	}
+
+	if req.plain() {
+		key, err := s.store.GetAlias(ctx, req.Domain, req.Url)
+		if key != "" && err == nil {
+			return s.link(key), nil
+		}
+
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return Link{}, fmt.Errorf("looking up alias: %w", err)
+		}
+	}
+
+	// ссылка записывается вместе со всеми атрибутами одним запросом: событие и кэш появляются только после этой записи,
+	// и ссылка без правил или срока действия, с которыми её создавали, не может стать видна.
+	// Ссылка хранится под ключом, включающим домен, поэтому дальше alias - это ключ ссылки.
+	draft := links.Draft{Original: req.Url, Rules: req.Rules, Split: req.Split, Meta: req.Meta, ExpiresAt: req.ExpiresAt}
+	alias := links.Key(req.Domain, req.Alias)
+	if req.Alias == "" {
+		alias = links.Key(req.Domain, aliasname.GetRandomAlias(aliasLen))
+	}
+	for {
+		err := s.store.Create(ctx, alias, draft)
+		if err == nil {
+			break
+		} else if !errors.Is(err, storage.ErrConflict) || req.Alias != "" {
+			return Link{}, fmt.Errorf("saving link: %w", err)
+		}
+		alias = links.Key(req.Domain, aliasname.GetRandomAlias(aliasLen))
+	}
+
+	created := webhooks.Event{Type: webhooks.EventLinkCreated, Alias: alias, Original: req.Url}
+	if !req.ExpiresAt.IsZero() {
+		created.ExpiresAt = &req.ExpiresAt
+	}
+	s.events.Publish(ctx, created)
+
+	ttl := s.ttl.TTL(0, req.ExpiresAt, time.Now())
+	if ttl == 0 {
+		return s.link(alias), nil
+	}
+
+	if err := s.cache.Set(ctx, alias, req.Url, ttl); err != nil {
+		s.log.Log(ctx, "error", "writing alias to cache failed", logging.String("alias", alias), logging.Err(err))
+		return Link{}, fmt.Errorf("caching link: %w", err)
+	}
+
+	raw, _ := json.Marshal(req.Rules)
+	if err := s.cache.Set(ctx, cache.RulesKey(alias), raw, ttl); err != nil {
+		s.log.Log(ctx, "error", "writing rules to cache failed", logging.String("alias", alias), logging.Err(err))
+		return Link{}, fmt.Errorf("caching rules: %w", err)
+	}
+
+	raw, _ = json.Marshal(req.Split)
+	if err := s.cache.Set(ctx, cache.SplitKey(alias), raw, ttl); err != nil {
+		s.log.Log(ctx, "error", "writing split to cache failed", logging.String("alias", alias), logging.Err(err))
+		return Link{}, fmt.Errorf("caching split: %w", err)
+	}
+
+	raw, _ = json.Marshal(req.ExpiresAt)
+	if err := s.cache.Set(ctx, cache.ExpiryKey(alias), raw, ttl); err != nil {
+		s.log.Log(ctx, "error", "writing expiry to cache failed", logging.String("alias", alias), logging.Err(err))
+		return Link{}, fmt.Errorf("caching expiry: %w", err)
+	}
+
+	return s.link(alias), nil
+}
+
+// BatchResult - это результат создания одной ссылки из пакета.
+type BatchResult struct {
+	Link Link
+	Err  error
+}
+
+// BatchShorten создаёт короткие ссылки для пакета запросов. Ошибка в одном запросе не прерывает обработку остальных.
+func (s *Shortener) BatchShorten(ctx context.Context, reqs []ShortenRequest) []BatchResult {
+	res := make([]BatchResult, len(reqs))
+	for i, req := range reqs {
+		res[i].Link, res[i].Err = s.Shorten(ctx, req)
+	}
+	return res
+}
+
+// UpdateMeta заменяет метаданные ссылки с ключом key и публикует событие link.updated.
+// Ссылка ищется только в рабочем пространстве запроса. Метаданные не кэшируются, поэтому кэш не меняется.
+func (s *Shortener) UpdateMeta(ctx context.Context, key string, meta links.Meta) error {
+	if err := meta.Validate(); err != nil {
+		return err
+	}
+
+	if err := s.store.SetMeta(ctx, key, meta); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("updating meta: %w", err)
+	}
+
+	s.events.Publish(ctx, webhooks.Event{Type: webhooks.EventLinkUpdated, Alias: key})
+	return nil
+}
+
+// Delete удаляет ссылку с ключом key вместе с её записями кэша и публикует событие link.deleted.
+// Ссылка ищется только в рабочем пространстве запроса, история переходов по ней сохраняется.
+func (s *Shortener) Delete(ctx context.Context, key string) error {
+	if err := s.store.Delete(ctx, key); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("deleting link: %w", err)
+	}
+
+	// ссылка уже удалена из базы: если кэш очистить не удалось, она открывается из кэша, пока его записи не истекут.
+	if err := s.cache.Purge(ctx, key); err != nil {
+		s.log.Log(ctx, "error", "purging cache failed", logging.String("alias", key), logging.Err(err))
+	}
+
+	s.events.Publish(ctx, webhooks.Event{Type: webhooks.EventLinkDeleted, Alias: key})
+	return nil
+}
+
+// Visitor - это сведения о клиенте, переходящем по короткой ссылке.
+// Variant - это закреплённый за клиентом ранее вариант A/B-распределения, если он есть.
+type Visitor struct {
+	UserAgent      string
+	AcceptLanguage string
+	Referrer       string
+	Variant        string
+	// Country - код страны клиента, если его определил прокси или CDN перед сервисом.
+	Country string
+}
+
+// Resolution - это результат разрешения псевдонима.
+// Если Sticky выставлен, транспорт должен закрепить за клиентом вариант Variant.
+type Resolution struct {
+	Destination string
+	Variant     string
+	Sticky      bool
+}
+
+// Resolve возвращает адрес, на который нужно перенаправить клиента.
+// Сначала оригинальный URL ищется в кэше, при промахе он загружается из SQL-базы данных и кэшируется.
+// Время жизни записей кэша выбирается по TTLPolicy и не превышает срок действия ссылки; истёкшая ссылка не найдена.
+// Если у псевдонима есть правила перенаправления, выбирается первое подходящее правило.
+// Иначе, если у псевдонима есть A/B-распределение, выбирается вариант по весам.
+// Оригинальный URL служит запасным вариантом. Если record выставлен, переход записывается в статистику.
+// Для ссылки на собственном домене alias - это её ключ, см. LinkKey.
+func (s *Shortener) Resolve(ctx context.Context, alias string, v Visitor, record bool) (Resolution, error) {
+	orig, err := s.cache.Get(ctx, alias)
+	hit := err == nil
+	if !hit {
+		if !errors.Is(err, storage.ErrNotFound) {
+			s.log.Log(ctx, "error", "reading alias from cache failed", logging.String("alias", alias), logging.Err(err))
+		}
+
+		orig, err = s.store.GetOriginal(ctx, alias)
+		if errors.Is(err, storage.ErrNotFound) || (err == nil && orig == "") {
+			return Resolution{}, ErrNotFound
+		}
+		if err != nil {
+			return Resolution{}, fmt.Errorf("reading alias: %w", err)
+		}
+	}
+
+	// срок действия кэшируется на базовое время: его запись может пережить запись псевдонима,
+	// потому что истечение всё равно проверяется при каждом переходе.
+	var exp time.Time
+	known := cached(ctx, s, s.store.GetExpiry, cache.ExpiryKey(alias), alias, &exp, s.ttl.Base)
+	if !exp.IsZero() && !time.Now().Before(exp) {
+		return Resolution{}, ErrNotFound
+	}
+
+	// без срока действия нельзя гарантировать, что запись кэша не переживёт ссылку.
+	var ttl time.Duration
+	if known {
+		var refresh bool
+		ttl, refresh = s.cacheTTL(ctx, alias, exp)
+		switch {
+		case ttl == 0:
+		case !hit:
+			if err = s.cache.Set(ctx, alias, orig, ttl); err != nil {
+				s.log.Log(ctx, "error", "reading and writing to cache failed", logging.String("alias", alias), logging.Err(err))
+			}
+		case refresh:
+			if err = s.cache.Expire(ctx, ttl, cache.Keys(alias)...); err != nil {
+				s.log.Log(ctx, "error", "refreshing cache ttl failed", logging.String("alias", alias), logging.Err(err))
+			}
+		}
+	}
+
+	res := s.destination(ctx, alias, orig, v, ttl)
+
+	if record {
+		if err = s.cache.CountClick(ctx, alias); err != nil {
+			s.log.Log(ctx, "error", "counting click failed", logging.String("alias", alias), logging.Err(err))
+		}
+		s.recorder.Record(ctx, clicks.Click{
+			Alias:       alias,
+			Variant:     res.Variant,
+			Destination: res.Destination,
+			Referrer:    v.Referrer,
+			UserAgent:   v.UserAgent,
+			Country:     v.Country,
+			Time:        time.Now(),
+		})
+	}
+
+	return res, nil
+}
+
+// Stats возвращает сводную статистику переходов по псевдониму.
+func (s *Shortener) Stats(ctx context.Context, alias string) (clicks.Stats, error) {
+	stats, err := s.store.ClickStats(ctx, alias)
+	if err != nil {
+		return clicks.Stats{}, fmt.Errorf("reading stats: %w", err)
+	}
+
+	// переходы, ещё не перенесённые из кэша, прибавляются к счётчику из SQL-базы данных.
+	if pending, err := s.cache.Get(ctx, cache.CountKey(alias)); err == nil {
+		n, _ := strconv.ParseInt(pending, 10, 64)
+		stats.Count += n
+	}
+	return stats, nil
+}
+
+// cacheTTL возвращает время жизни записей кэша псевдонима со сроком действия exp
+// и сообщает, нужно ли продлить их при попадании в кэш. Если правила учитывают популярность, переход засчитывается.
+func (s *Shortener) cacheTTL(ctx context.Context, alias string, exp time.Time) (time.Duration, bool) {
+	var hits int64
+	if s.ttl.CountsHits() {
+		var err error
+		if hits, err = s.cache.Hit(ctx, alias, s.ttl.Window); err != nil {
+			s.log.Log(ctx, "error", "counting cache hit failed", logging.String("alias", alias), logging.Err(err))
+		}
+	}
+	return s.ttl.TTL(hits, exp, time.Now()), s.ttl.Refresh(hits)
+}
+
+// destination выбирает адрес перенаправления с учётом правил и A/B-распределения псевдонима.
+// Загруженные из SQL-базы данных правила и распределение кэшируются на ttl. При любой ошибке клиент перенаправляется на оригинальный URL.
+func (s *Shortener) destination(ctx context.Context, alias, orig string, v Visitor, ttl time.Duration) Resolution {
+	var set rules.Set
+	if !cached(ctx, s, s.store.GetRules, cache.RulesKey(alias), alias, &set, ttl) {
+		return Resolution{Destination: orig}
+	}
+	if dest := set.Match(v.UserAgent, v.AcceptLanguage, time.Now()); dest != "" {
+		return Resolution{Destination: dest}
+	}
+
+	var ab split.Split
+	if !cached(ctx, s, s.store.GetSplit, cache.SplitKey(alias), alias, &ab, ttl) || ab.Empty() {
+		return Resolution{Destination: orig}
+	}
+
+	if ab.Sticky && v.Variant != "" {
+		if variant, ok := ab.Lookup(v.Variant); ok {
+			return Resolution{Destination: variant.Url, Variant: variant.Name}
+		}
+	}
+
+	// распределение из SQL-базы данных или кэша могло быть сохранено без проверки весов.
+	total := ab.Total()
+	if total <= 0 {
+		return Resolution{Destination: orig}
+	}
+	variant := ab.Pick(rand.Intn(total))
+	return Resolution{Destination: variant.Url, Variant: variant.Name, Sticky: ab.Sticky}
+}
+
+// cached читает из кэша значение по ключу key и декодирует его в dst.
+// При промахе значение загружается из SQL-базы данных функцией load и кэшируется на ttl, 0 отключает кэширование.
+// Возвращает false, если значение получить не удалось.
+func cached[T any](ctx context.Context, s *Shortener, load func(context.Context, string) (T, error), key, alias string, dst *T, ttl time.Duration) bool {
+	raw, err := s.cache.Get(ctx, key)
+	if err == nil {
+		if err = json.Unmarshal([]byte(raw), dst); err != nil {
+			s.log.Log(ctx, "error", "decoding cached value failed", logging.String("key", key), logging.Err(err))
+			return false
+		}
+		return true
+	}
+
+	v, err := load(ctx, alias)
+	if err != nil {
+		s.log.Log(ctx, "error", "reading value from sql failed", logging.String("key", key), logging.Err(err))
+		return false
+	}
+	*dst = v
+	if ttl == 0 {
+		return true
+	}
+
+	encoded, _ := json.Marshal(v)
+	if err = s.cache.Set(ctx, key, encoded, ttl); err != nil {
+		s.log.Log(ctx, "error", "writing value to cache failed", logging.String("key", key), logging.Err(err))
+	}
+	return true
+}
@@ -0,0 +1,375 @@
+package service
+
+import (
+	"Darkyfun/UrlShortener/internal/links"
+	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/rules"
+	"Darkyfun/UrlShortener/internal/service/servicetest"
+	"Darkyfun/UrlShortener/internal/split"
+	"Darkyfun/UrlShortener/internal/storage"
+	"Darkyfun/UrlShortener/internal/storage/cache"
+	"Darkyfun/UrlShortener/internal/webhooks"
+	"Darkyfun/UrlShortener/internal/workspace"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+const iphoneUA = "Mozilla/5.0 (iPhone; CPU iPhone OS 16_0 like Mac OS X)"
+
+func newTestShortener() (*Shortener, *servicetest.Store) {
+	store := servicetest.NewStore()
+	return NewShortener(servicetest.NewCache(), store, logging.NewLogger("json", io.Discard), servicetest.Recorder{Store: store}, ":5050"), store
+}
+
+func TestNewShortenRequest(t *testing.T) {
+	tests := []struct {
+		name  string
+		url   string
+		rules []rules.Rule
+		split *split.Split
+		meta  links.Meta
+		err   error
+	}{
+		{name: "plain", url: "https://www.google.com", err: nil},
+		{name: "empty url", url: "", err: ErrInvalidRequest},
+		{name: "invalid url", url: "https:/www", err: ErrInvalidUrl},
+		{name: "invalid rules", url: "https://www.google.com", rules: []rules.Rule{{Destination: "https://a.com"}}, err: ErrInvalidRules},
+		{name: "invalid split", url: "https://www.google.com", split: &split.Split{}, err: ErrInvalidSplit},
+		{name: "invalid meta", url: "https://www.google.com", meta: links.Meta{Tags: []string{""}}, err: ErrInvalidMeta},
+		{name: "campaign", url: "https://www.google.com", meta: links.Meta{Campaign: "black-friday"}, err: nil},
+		{name: "long campaign", url: "https://www.google.com", meta: links.Meta{Campaign: strings.Repeat("c", 129)}, err: ErrInvalidMeta},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewShortenRequest(tt.url, tt.rules, tt.split, tt.meta)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
+
+// events запоминает опубликованные события.
+type events []webhooks.Event
+
+func (e *events) Publish(_ context.Context, ev webhooks.Event) {
+	*e = append(*e, ev)
+}
+
+func TestShortener_Shorten(t *testing.T) {
+	svc, _ := newTestShortener()
+	published := &events{}
+	svc.SetPublisher(published)
+	ctx := context.Background()
+
+	first, err := svc.Shorten(ctx, ShortenRequest{Url: "https://www.google.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, "http://localhost:5050/redirect/"+first.Alias, first.ShortUrl)
+
+	// ссылка без правил и метаданных переиспользуется.
+	second, err := svc.Shorten(ctx, ShortenRequest{Url: "https://www.google.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, first.Alias, second.Alias)
+
+	// ссылка с метаданными всегда получает новый псевдоним.
+	third, err := svc.Shorten(ctx, ShortenRequest{Url: "https://www.google.com", Meta: links.Meta{Title: "Search"}})
+	assert.Nil(t, err)
+	assert.NotEqual(t, first.Alias, third.Alias)
+
+	// о переиспользованной ссылке событие не публикуется.
+	assert.Len(t, *published, 2)
+	assert.Equal(t, webhooks.Event{Type: webhooks.EventLinkCreated, Alias: first.Alias, Original: "https://www.google.com"}, (*published)[0])
+	assert.Equal(t, third.Alias, (*published)[1].Alias)
+
+	results := svc.BatchShorten(ctx, []ShortenRequest{{Url: "https://a.com"}, {Url: "https://b.com"}})
+	assert.Equal(t, 2, len(results))
+	assert.Nil(t, results[0].Err)
+	assert.Nil(t, results[1].Err)
+	assert.NotEqual(t, results[0].Link.Alias, results[1].Link.Alias)
+}
+
+func TestShortener_ShortenAlias(t *testing.T) {
+	svc, _ := newTestShortener()
+	ctx := context.Background()
+
+	for _, alias := range []string{"", "a/b", "go.brand-a.com/promo", "with space", strings.Repeat("a", links.MaxAliasLen+1)} {
+		_, err := ShortenRequest{Url: "https://www.google.com"}.WithAlias(alias)
+		assert.Equal(t, links.ErrInvalidAlias, err)
+	}
+
+	req, err := ShortenRequest{Url: "https://www.google.com"}.WithAlias("promo")
+	assert.Nil(t, err)
+	link, err := svc.Shorten(ctx, req)
+	assert.Nil(t, err)
+	assert.Equal(t, "promo", link.Alias)
+
+	res, err := svc.Resolve(ctx, "promo", Visitor{}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://www.google.com", res.Destination)
+
+	// занятый псевдоним не заменяется случайным и не переиспользуется.
+	_, err = svc.Shorten(ctx, req)
+	assert.ErrorIs(t, err, storage.ErrConflict)
+}
+
+// failingStore не может записать ни одной ссылки.
+type failingStore struct {
+	*servicetest.Store
+}
+
+func (failingStore) Create(context.Context, string, links.Draft) error {
+	return storage.ErrUnavailable
+}
+
+func TestShortener_ShortenAtomic(t *testing.T) {
+	svc, store := newTestShortener()
+	ctx := context.Background()
+	exp := time.Now().Add(time.Hour)
+	set := rules.Set{{Platform: "ios", Destination: "https://apps.apple.com"}}
+
+	// ссылка сохраняется сразу со всеми атрибутами.
+	link, err := svc.Shorten(ctx, ShortenRequest{Url: "https://www.google.com", Rules: set, Meta: links.Meta{Campaign: "spring"}, ExpiresAt: exp})
+	assert.Nil(t, err)
+	saved, err := store.GetRules(ctx, link.Alias)
+	assert.Nil(t, err)
+	assert.Equal(t, set, saved)
+	savedExp, err := store.GetExpiry(ctx, link.Alias)
+	assert.Nil(t, err)
+	assert.True(t, exp.Equal(savedExp))
+
+	// если ссылку записать не удалось, событие не публикуется.
+	published := &events{}
+	svc = NewShortener(servicetest.NewCache(), failingStore{servicetest.NewStore()}, logging.NewLogger("json", io.Discard), nil, ":5050")
+	svc.SetPublisher(published)
+	_, err = svc.Shorten(ctx, ShortenRequest{Url: "https://www.google.com", Rules: set})
+	assert.ErrorIs(t, err, storage.ErrUnavailable)
+	assert.Empty(t, *published)
+}
+
+func TestShortener_Blocklist(t *testing.T) {
+	svc, _ := newTestShortener()
+	ctx := context.Background()
+	svc.SetBlocklist([]string{" Evil.com ", ""})
+
+	tests := []struct {
+		name string
+		req  ShortenRequest
+		err  error
+	}{
+		{name: "domain", req: ShortenRequest{Url: "https://evil.com/login"}, err: ErrBlockedUrl},
+		{name: "subdomain", req: ShortenRequest{Url: "www.EVIL.com"}, err: ErrBlockedUrl},
+		{name: "split variant", req: ShortenRequest{Url: "https://a.com", Split: split.Split{Variants: []split.Variant{{Url: "https://x.evil.com", Weight: 1}}}}, err: ErrBlockedUrl},
+		{name: "similar domain", req: ShortenRequest{Url: "https://notevil.com"}, err: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.Shorten(ctx, tt.req)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+
+	// список можно очистить во время работы.
+	svc.SetBlocklist(nil)
+	_, err := svc.Shorten(ctx, ShortenRequest{Url: "https://evil.com"})
+	assert.Nil(t, err)
+}
+
+func TestShortener_Domains(t *testing.T) {
+	svc, _ := newTestShortener()
+	ctx := context.Background()
+
+	branded, err := ShortenRequest{Url: "https://brand-a.com"}.WithDomain("Go.Brand-A.com")
+	assert.Nil(t, err)
+	_, err = svc.Shorten(ctx, branded)
+	assert.Equal(t, ErrUnknownDomain, err)
+
+	svc.SetDomains([]links.Domain{{Host: "go.brand-a.com"}, {Host: "go.brand-b.com"}, {Host: "not a domain"}})
+	plain, err := svc.Shorten(ctx, ShortenRequest{Url: "https://brand-a.com"})
+	assert.Nil(t, err)
+	link, err := svc.Shorten(ctx, branded)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://go.brand-a.com/redirect/"+link.Alias, link.ShortUrl)
+
+	// ссылки без правил переиспользуются только в пределах домена.
+	assert.NotEqual(t, plain.Alias, link.Alias)
+	again, err := svc.Shorten(ctx, branded)
+	assert.Nil(t, err)
+	assert.Equal(t, link, again)
+
+	// псевдоним ищется среди ссылок домена, с которого пришёл запрос.
+	res, err := svc.Resolve(ctx, svc.LinkKey("go.brand-a.com", link.Alias), Visitor{}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://brand-a.com", res.Destination)
+	_, err = svc.Resolve(ctx, svc.LinkKey("go.brand-b.com", link.Alias), Visitor{}, false)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = svc.Resolve(ctx, svc.LinkKey("unknown.com", plain.Alias), Visitor{}, false)
+	assert.Nil(t, err)
+}
+
+func TestShortener_Workspaces(t *testing.T) {
+	svc, _ := newTestShortener()
+	growth := workspace.WithID(context.Background(), 1)
+	support := workspace.WithID(context.Background(), 2)
+	svc.SetDomains([]links.Domain{{Host: "go.brand-a.com", WorkspaceID: 1}})
+
+	// одинаковые ссылки разных рабочих пространств не переиспользуются.
+	a, err := svc.Shorten(growth, ShortenRequest{Url: "https://example.com"})
+	assert.Nil(t, err)
+	b, err := svc.Shorten(support, ShortenRequest{Url: "https://example.com"})
+	assert.Nil(t, err)
+	assert.NotEqual(t, a.Alias, b.Alias)
+
+	_, err = svc.Stats(support, a.Alias)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = svc.Stats(growth, a.Alias)
+	assert.Nil(t, err)
+
+	// перенаправление не ограничено рабочим пространством.
+	_, err = svc.Resolve(context.Background(), a.Alias, Visitor{}, false)
+	assert.Nil(t, err)
+
+	// домен доступен только рабочему пространству, которому он принадлежит.
+	req, _ := ShortenRequest{Url: "https://example.com"}.WithDomain("go.brand-a.com")
+	_, err = svc.Shorten(support, req)
+	assert.Equal(t, ErrUnknownDomain, err)
+	_, err = svc.Shorten(growth, req)
+	assert.Nil(t, err)
+}
+
+func TestShortener_Resolve(t *testing.T) {
+	svc, store := newTestShortener()
+	ctx := context.Background()
+
+	req, err := NewShortenRequest("https://www.google.com", []rules.Rule{{Platform: "ios", Destination: "https://apps.apple.com"}},
+		&split.Split{Variants: []split.Variant{{Url: "https://a.com", Weight: 1}}, Sticky: true}, links.Meta{})
+	assert.Nil(t, err)
+
+	link, err := svc.Shorten(ctx, req)
+	assert.Nil(t, err)
+
+	res, err := svc.Resolve(ctx, link.Alias, Visitor{UserAgent: iphoneUA}, true)
+	assert.Nil(t, err)
+	assert.Equal(t, Resolution{Destination: "https://apps.apple.com"}, res)
+
+	res, err = svc.Resolve(ctx, link.Alias, Visitor{}, true)
+	assert.Nil(t, err)
+	assert.Equal(t, Resolution{Destination: "https://a.com", Variant: "A", Sticky: true}, res)
+
+	// закреплённый вариант не требует повторной установки cookie.
+	res, err = svc.Resolve(ctx, link.Alias, Visitor{Variant: "A"}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, Resolution{Destination: "https://a.com", Variant: "A"}, res)
+
+	_, err = svc.Resolve(ctx, "unknown", Visitor{}, true)
+	assert.Equal(t, ErrNotFound, err)
+
+	stats, err := svc.Stats(ctx, link.Alias)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), stats.Total)
+	assert.Equal(t, map[string]int64{"A": 1}, stats.Variants)
+	// счётчик ещё не перенесён в базу и берётся из кэша.
+	assert.Equal(t, int64(2), stats.Count)
+
+	// правила и распределение берутся из кэша, даже если в базе они изменились.
+	assert.Nil(t, store.SetRules(ctx, link.Alias, nil))
+	res, err = svc.Resolve(ctx, link.Alias, Visitor{UserAgent: iphoneUA}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://apps.apple.com", res.Destination)
+}
+
+func TestShortener_ResolveBrokenSplit(t *testing.T) {
+	ctx := context.Background()
+
+	// веса, сохранённые в обход split.Normalize, не роняют перенаправление: клиент получает оригинальный URL.
+	for _, weights := range [][]int{{0}, {-1, 1}, {1 << 62, 1 << 62, 1 << 62, 1 << 62}} {
+		svc, store := newTestShortener()
+
+		ab := split.Split{}
+		for _, w := range weights {
+			ab.Variants = append(ab.Variants, split.Variant{Name: "v", Url: "https://a.com", Weight: w})
+		}
+		assert.Nil(t, store.Create(ctx, "broken", links.Draft{Original: "https://www.google.com", Split: ab}))
+
+		res, err := svc.Resolve(ctx, "broken", Visitor{}, false)
+		assert.Nil(t, err)
+		assert.Equal(t, Resolution{Destination: "https://www.google.com"}, res)
+	}
+}
+
+// slowStore отвечает на чтение оригинального URL только после отмены контекста.
+type slowStore struct {
+	*servicetest.Store
+}
+
+func (s slowStore) GetOriginal(ctx context.Context, _ string) (string, error) {
+	<-ctx.Done()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", storage.ErrTimeout
+	}
+	return "", storage.ErrUnavailable
+}
+
+func TestShortener_Deadlines(t *testing.T) {
+	store := slowStore{servicetest.NewStore()}
+	svc := NewShortener(servicetest.NewCache(), store, logging.NewLogger("json", io.Discard), servicetest.Recorder{Store: store.Store}, ":5050")
+	svc.SetDeadlines(Deadlines{Cache: time.Second, StoreRead: 10 * time.Millisecond, StoreWrite: time.Second})
+
+	// чтение ограничено StoreRead.
+	_, err := svc.Resolve(context.Background(), "alias", Visitor{}, false)
+	assert.ErrorIs(t, err, storage.ErrTimeout)
+
+	// отменённый клиентом запрос прерывает чтение раньше.
+	svc.SetDeadlines(Deadlines{StoreRead: time.Minute})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = svc.Resolve(ctx, "alias", Visitor{}, false)
+	assert.ErrorIs(t, err, storage.ErrUnavailable)
+}
+
+func TestShortener_CacheTTL(t *testing.T) {
+	store := servicetest.NewStore()
+	rapid := servicetest.NewCache()
+	svc := NewShortener(rapid, store, logging.NewLogger("json", io.Discard), servicetest.Recorder{Store: store}, ":5050")
+	svc.SetTTLPolicy(cache.TTLPolicy{Base: time.Hour, PopularTTL: 24 * time.Hour, Threshold: 2, Window: time.Minute})
+	ctx := context.Background()
+
+	link, err := svc.Shorten(ctx, ShortenRequest{Url: "https://www.google.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, time.Hour, rapid.TTL(link.Alias))
+
+	// первый переход не продлевает запись, второй делает ссылку популярной.
+	_, err = svc.Resolve(ctx, link.Alias, Visitor{}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Hour, rapid.TTL(link.Alias))
+	_, err = svc.Resolve(ctx, link.Alias, Visitor{}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 24*time.Hour, rapid.TTL(link.Alias))
+	assert.Equal(t, 24*time.Hour, rapid.TTL(cache.RulesKey(link.Alias)))
+
+	// время жизни не превышает срок действия ссылки.
+	req, err := ShortenRequest{Url: "https://www.google.com"}.WithExpiry(time.Now().Add(10 * time.Minute))
+	assert.Nil(t, err)
+	expiring, err := svc.Shorten(ctx, req)
+	assert.Nil(t, err)
+	assert.NotEqual(t, link.Alias, expiring.Alias)
+	assert.LessOrEqual(t, rapid.TTL(expiring.Alias), 10*time.Minute)
+	for i := 0; i < 3; i++ {
+		_, err = svc.Resolve(ctx, expiring.Alias, Visitor{}, false)
+		assert.Nil(t, err)
+	}
+	assert.LessOrEqual(t, rapid.TTL(expiring.Alias), 10*time.Minute)
+
+	// истёкшая ссылка не найдена.
+	assert.Nil(t, store.SetExpiry(ctx, expiring.Alias, time.Now().Add(-time.Second)))
+	assert.Nil(t, rapid.Set(ctx, cache.ExpiryKey(expiring.Alias), `"`+time.Now().Add(-time.Second).Format(time.RFC3339Nano)+`"`, time.Hour))
+	_, err = svc.Resolve(ctx, expiring.Alias, Visitor{}, false)
+	assert.Equal(t, ErrNotFound, err)
+
+	_, err = ShortenRequest{Url: "https://www.google.com"}.WithExpiry(time.Now().Add(-time.Minute))
+	assert.Equal(t, ErrInvalidExpiry, err)
+}
@@ -0,0 +1,107 @@
+// Package split содержит взвешенное распределение переходов между несколькими адресами назначения (A/B-тесты).
+package split
+
+import (
+	"errors"
+	"strconv"
+)
+
+var ErrNoVariants = errors.New("split has no variants")
+var ErrTooManyVariants = errors.New("split has too many variants")
+var ErrInvalidWeight = errors.New("variant weight must be between 1 and 10000")
+var ErrNoUrl = errors.New("variant has no url")
+var ErrDuplicateName = errors.New("duplicate variant name")
+
+// Ограничения на распределение: сумма весов не превышает MaxVariants*MaxWeight и не переполняет int.
+const (
+	MaxVariants = 100
+	MaxWeight   = 10000
+)
+
+// Variant - это один из адресов назначения со своим весом.
+type Variant struct {
+	Name   string `json:"name"`
+	Url    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// Split - это набор вариантов, между которыми распределяются переходы.
+// Если Sticky выставлен, вернувшийся клиент получает тот же вариант, что и в прошлый раз.
+type Split struct {
+	Variants []Variant `json:"variants"`
+	Sticky   bool      `json:"sticky"`
+}
+
+// Normalize проверяет набор вариантов, их количество и веса, и присваивает имена вариантам без имени ("A", "B", ...).
+func Normalize(s Split) (Split, error) {
+	if len(s.Variants) == 0 {
+		return Split{}, ErrNoVariants
+	}
+	if len(s.Variants) > MaxVariants {
+		return Split{}, ErrTooManyVariants
+	}
+
+	variants := make([]Variant, len(s.Variants))
+	names := make(map[string]bool, len(s.Variants))
+	for i, v := range s.Variants {
+		if v.Url == "" {
+			return Split{}, ErrNoUrl
+		}
+		if v.Weight <= 0 || v.Weight > MaxWeight {
+			return Split{}, ErrInvalidWeight
+		}
+		if v.Name == "" {
+			v.Name = defaultName(i)
+		}
+		if names[v.Name] {
+			return Split{}, ErrDuplicateName
+		}
+		names[v.Name] = true
+		variants[i] = v
+	}
+
+	return Split{Variants: variants, Sticky: s.Sticky}, nil
+}
+
+// Empty сообщает, что у ссылки нет A/B-распределения.
+func (s Split) Empty() bool {
+	return len(s.Variants) == 0
+}
+
+// Total возвращает сумму весов всех вариантов.
+func (s Split) Total() int {
+	total := 0
+	for _, v := range s.Variants {
+		total += v.Weight
+	}
+	return total
+}
+
+// Pick возвращает вариант, в интервал веса которого попадает n из диапазона [0, Total()).
+func (s Split) Pick(n int) Variant {
+	for _, v := range s.Variants {
+		if n < v.Weight {
+			return v
+		}
+		n -= v.Weight
+	}
+	return s.Variants[len(s.Variants)-1]
+}
+
+// Lookup ищет вариант по имени.
+func (s Split) Lookup(name string) (Variant, bool) {
+	for _, v := range s.Variants {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return Variant{}, false
+}
+
+// defaultName возвращает имя варианта по его порядковому номеру: "A", "B", ..., "Z", "27", ...
+func defaultName(i int) string {
+	if i < 26 {
+		return string(rune('A' + i))
+	}
+	return strconv.Itoa(i + 1)
+}
@@ -0,0 +1,56 @@
+package split
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		split Split
+		err   error
+	}{
+		{name: "valid", split: Split{Variants: []Variant{{Url: "https://a.com", Weight: 70}, {Url: "https://b.com", Weight: 30}}}, err: nil},
+		{name: "no variants", split: Split{}, err: ErrNoVariants},
+		{name: "zero weight", split: Split{Variants: []Variant{{Url: "https://a.com"}}}, err: ErrInvalidWeight},
+		{name: "too heavy", split: Split{Variants: []Variant{{Url: "https://a.com", Weight: MaxWeight + 1}}}, err: ErrInvalidWeight},
+		{name: "overflowing weights", split: Split{Variants: []Variant{{Url: "https://a.com", Weight: 1 << 62}, {Url: "https://b.com", Weight: 1 << 62}}},
+			err: ErrInvalidWeight},
+		{name: "too many variants", split: Split{Variants: make([]Variant, MaxVariants+1)}, err: ErrTooManyVariants},
+		{name: "no url", split: Split{Variants: []Variant{{Weight: 1}}}, err: ErrNoUrl},
+		{name: "duplicate name", split: Split{Variants: []Variant{{Name: "x", Url: "https://a.com", Weight: 1}, {Name: "x", Url: "https://b.com", Weight: 1}}}, err: ErrDuplicateName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Normalize(tt.split)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+
+	s, err := Normalize(Split{Variants: []Variant{{Url: "https://a.com", Weight: 1}, {Name: "control", Url: "https://b.com", Weight: 1}, {Url: "https://c.com", Weight: 1}}})
+	assert.Nil(t, err)
+	assert.Equal(t, "A", s.Variants[0].Name)
+	assert.Equal(t, "control", s.Variants[1].Name)
+	assert.Equal(t, "C", s.Variants[2].Name)
+}
+
+func TestSplit_Pick(t *testing.T) {
+	s, err := Normalize(Split{Variants: []Variant{{Url: "https://a.com", Weight: 70}, {Url: "https://b.com", Weight: 30}}})
+	assert.Nil(t, err)
+	assert.Equal(t, 100, s.Total())
+
+	counts := make(map[string]int)
+	for n := 0; n < s.Total(); n++ {
+		counts[s.Pick(n).Name]++
+	}
+	assert.Equal(t, map[string]int{"A": 70, "B": 30}, counts)
+
+	v, ok := s.Lookup("B")
+	assert.True(t, ok)
+	assert.Equal(t, "https://b.com", v.Url)
+
+	_, ok = s.Lookup("Z")
+	assert.False(t, ok)
+}
@@ -2,34 +2,36 @@
 package cache
 
 import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/storage"
 	"context"
 	"errors"
+	"fmt"
 	"github.com/redis/go-redis/v9"
 	"log"
+	"net"
+	"strings"
 	"time"
 )
 
 var ErrFailed = errors.New("operation failed")
-var ErrClientClosed = errors.New("unable to set a record: client is closed")
-var ErrCacheMiss = errors.New("cache miss")
+var ErrClientClosed = fmt.Errorf("client is closed: %w", storage.ErrUnavailable)
+var ErrCacheMiss = fmt.Errorf("cache miss: %w", storage.ErrNotFound)
+var ErrTimeout = fmt.Errorf("cache: %w", storage.ErrTimeout)
 
 // RapidDb - это структура, реализующая запросы к базе данных, являющейся кэшем.
 type RapidDb struct {
-	rdb *redis.Client
+	rdb redis.UniversalClient
 	log Logger
 }
 
-// Opts - это опции, необходимые для подключения к кэшу.
-type Opts struct {
-	Addr, User, Password string
-	MaxRetries, PoolSize int
-}
-
 // NewCacheDb возвращает переменную *RapidDb, готовую к работе с кэшем.
+// В зависимости от options.Mode подключается к одиночному Redis, к мастеру через Sentinel или к кластеру.
 func NewCacheDb(options Opts, logg Logger) *RapidDb {
-	client := redis.NewClient(&redis.Options{
-		Addr: options.Addr,
-	})
+	client, err := newClient(options)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
 	defer cancel()
@@ -50,36 +52,228 @@ func (c *RapidDb) Close() error {
 	return c.rdb.Close()
 }
 
-// Set сохраняет в кэше запись, состояющую из псевдонима и оригинального URL.
-func (c *RapidDb) Set(ctx context.Context, keyAlias string, valueOriginal any) error {
-	_, err := c.rdb.Set(ctx, keyAlias, valueOriginal, time.Hour).Result()
-
-	if err != nil && err.Error() == "redis: client is closed" {
-		c.log.Log("error", ErrClientClosed.Error())
-		return ErrClientClosed
-	} else if err != nil {
-		c.log.Log("error", ErrFailed.Error())
-		return ErrFailed
+// Set сохраняет в кэше запись, состояющую из псевдонима и оригинального URL, на время ttl.
+func (c *RapidDb) Set(ctx context.Context, keyAlias string, valueOriginal any, ttl time.Duration) error {
+	_, err := c.rdb.Set(ctx, keyAlias, valueOriginal, ttl).Result()
+	if err != nil {
+		c.log.Log(ctx, "error", "unable to set a record", logging.String("key", keyAlias), logging.Err(err))
 	}
 
-	return nil
+	return classify(err)
 }
 
 // Get получает значения псевдонима из кэша по указанному псевдониму
 func (c *RapidDb) Get(ctx context.Context, keyAlias string) (string, error) {
 	res := c.rdb.Get(ctx, keyAlias)
 	url, err := res.Result()
+	if err != nil {
+		return "", classify(err)
+	}
 
+	return url, nil
+}
+
+// Del удаляет из кэша записи с указанными ключами.
+// Ключи удаляются по одному в конвейере, потому что в кластере они могут лежать в разных слотах.
+func (c *RapidDb) Del(ctx context.Context, keys ...string) error {
+	pipe := c.rdb.Pipeline()
+	for _, key := range keys {
+		pipe.Del(ctx, key)
+	}
+	_, err := pipe.Exec(ctx)
 	if err != nil {
-		switch {
-		case err.Error() == "redis: nil":
-			return "", ErrCacheMiss
-		case err.Error() == "redis: client is closed":
-			return "", ErrClientClosed
-		default:
-			return "", ErrFailed
+		c.log.Log(ctx, "error", "unable to delete records", logging.String("keys", strings.Join(keys, " ")), logging.Err(err))
+	}
+
+	return classify(err)
+}
+
+// Entry - это запись кэша.
+type Entry struct {
+	Key   string
+	Value any
+	TTL   time.Duration
+}
+
+// SetMany сохраняет записи в кэше одним конвейером.
+func (c *RapidDb) SetMany(ctx context.Context, entries []Entry) error {
+	pipe := c.rdb.Pipeline()
+	for _, e := range entries {
+		pipe.Set(ctx, e.Key, e.Value, e.TTL)
+	}
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		c.log.Log(ctx, "error", "unable to set records", logging.Int("count", len(entries)), logging.Err(err))
+	}
+
+	return classify(err)
+}
+
+// Expire продлевает время жизни записей с указанными ключами до ttl. Отсутствующие ключи пропускаются.
+func (c *RapidDb) Expire(ctx context.Context, ttl time.Duration, keys ...string) error {
+	pipe := c.rdb.Pipeline()
+	for _, key := range keys {
+		pipe.Expire(ctx, key, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		c.log.Log(ctx, "error", "unable to expire records", logging.String("keys", strings.Join(keys, " ")), logging.Err(err))
+	}
+
+	return classify(err)
+}
+
+// Hit учитывает переход по псевдониму и возвращает число переходов за текущее окно window.
+// Окно начинается с первого перехода и длится window. Счётчик создаётся вместе со временем жизни в одной транзакции,
+// поэтому сбой между командами не оставляет его без срока и не блокирует псевдоним навсегда.
+func (c *RapidDb) Hit(ctx context.Context, alias string, window time.Duration) (int64, error) {
+	var incr *redis.IntCmd
+	_, err := c.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SetNX(ctx, HitsKey(alias), 0, window)
+		incr = pipe.Incr(ctx, HitsKey(alias))
+		return nil
+	})
+	if err != nil {
+		return 0, classify(err)
+	}
+
+	return incr.Val(), nil
+}
+
+// CountClick увеличивает счётчик переходов по псевдониму и помечает его как ещё не перенесённый в SQL-базу данных.
+func (c *RapidDb) CountClick(ctx context.Context, alias string) error {
+	pipe := c.rdb.Pipeline()
+	pipe.Incr(ctx, CountKey(alias))
+	pipe.SAdd(ctx, DirtyCountsKey, alias)
+	_, err := pipe.Exec(ctx)
+
+	return classify(err)
+}
+
+// TakeClickCounts забирает из кэша счётчики переходов не более чем n псевдонимов, помеченных CountClick, и обнуляет их.
+// Переходы, засчитанные после этого, попадут в следующий вызов.
+func (c *RapidDb) TakeClickCounts(ctx context.Context, n int) (map[string]int64, error) {
+	aliases, err := c.rdb.SPopN(ctx, DirtyCountsKey, int64(n)).Result()
+	if err != nil || len(aliases) == 0 {
+		return nil, classify(err)
+	}
+
+	pipe := c.rdb.Pipeline()
+	cmds := make([]*redis.StringCmd, len(aliases))
+	for i, alias := range aliases {
+		cmds[i] = pipe.GetDel(ctx, CountKey(alias))
+	}
+	_, err = pipe.Exec(ctx)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		c.log.Log(ctx, "error", "unable to take click counts", logging.Int("count", len(aliases)), logging.Err(err))
+		return nil, classify(err)
+	}
+
+	counts := make(map[string]int64, len(aliases))
+	for i, alias := range aliases {
+		// счётчик может отсутствовать, если переход засчитан между SPOP и GETDEL предыдущего вызова.
+		if n, err := cmds[i].Int64(); err == nil && n > 0 {
+			counts[alias] = n
 		}
 	}
+	return counts, nil
+}
 
-	return url, nil
+// PendingClicks возвращает сумму счётчиков переходов псевдонимов aliases, ещё не перенесённых в SQL-базу данных.
+func (c *RapidDb) PendingClicks(ctx context.Context, aliases []string) (int64, error) {
+	if len(aliases) == 0 {
+		return 0, nil
+	}
+
+	pipe := c.rdb.Pipeline()
+	cmds := make([]*redis.StringCmd, len(aliases))
+	for i, alias := range aliases {
+		cmds[i] = pipe.Get(ctx, CountKey(alias))
+	}
+	_, err := pipe.Exec(ctx)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, classify(err)
+	}
+
+	var total int64
+	for _, cmd := range cmds {
+		if n, err := cmd.Int64(); err == nil {
+			total += n
+		}
+	}
+	return total, nil
+}
+
+// RestoreClickCounts возвращает в кэш счётчики, забранные TakeClickCounts, если их не удалось сохранить.
+func (c *RapidDb) RestoreClickCounts(ctx context.Context, counts map[string]int64) error {
+	pipe := c.rdb.Pipeline()
+	for alias, n := range counts {
+		pipe.IncrBy(ctx, CountKey(alias), n)
+		pipe.SAdd(ctx, DirtyCountsKey, alias)
+	}
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		c.log.Log(ctx, "error", "unable to restore click counts", logging.Int("count", len(counts)), logging.Err(err))
+	}
+
+	return classify(err)
+}
+
+// Purge удаляет из кэша все записи, относящиеся к псевдониму.
+func (c *RapidDb) Purge(ctx context.Context, alias string) error {
+	return c.Del(ctx, Keys(alias)...)
+}
+
+// Keys возвращает ключи всех записей кэша, относящихся к псевдониму, кроме счётчика переходов.
+// Для ссылки на собственном домене alias - это её ключ вида "host/alias" (см. links.Key),
+// поэтому записи одинаковых псевдонимов разных доменов не пересекаются.
+func Keys(alias string) []string {
+	return []string{alias, RulesKey(alias), SplitKey(alias), ExpiryKey(alias)}
+}
+
+// classify приводит ошибку клиента Redis к ошибке из классификации пакета storage.
+// Неизвестные ошибки заменяются на ErrFailed.
+func classify(err error) error {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, redis.Nil):
+		return ErrCacheMiss
+	case errors.Is(err, redis.ErrClosed):
+		return ErrClientClosed
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrTimeout
+	case errors.As(err, &netErr):
+		return fmt.Errorf("%w: %w", storage.ErrUnavailable, err)
+	}
+	return ErrFailed
+}
+
+// RulesKey возвращает ключ, под которым в кэше хранится набор правил псевдонима.
+func RulesKey(alias string) string {
+	return "rules:" + alias
+}
+
+// SplitKey возвращает ключ, под которым в кэше хранится A/B-распределение псевдонима.
+func SplitKey(alias string) string {
+	return "split:" + alias
+}
+
+// ExpiryKey возвращает ключ, под которым в кэше хранится срок действия ссылки.
+func ExpiryKey(alias string) string {
+	return "expiry:" + alias
+}
+
+// DirtyCountsKey - это ключ множества псевдонимов, счётчики переходов которых ещё не перенесены в SQL-базу данных.
+const DirtyCountsKey = "counts:dirty"
+
+// CountKey возвращает ключ счётчика переходов по псевдониму, ещё не перенесённых в SQL-базу данных.
+func CountKey(alias string) string {
+	return "count:" + alias
+}
+
+// HitsKey возвращает ключ счётчика переходов по псевдониму за текущее окно.
+func HitsKey(alias string) string {
+	return "hits:" + alias
 }
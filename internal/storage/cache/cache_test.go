@@ -35,7 +35,7 @@ func TestRapidDb_Set(t *testing.T) {
 	rdb := NewCacheDb(Opts{Addr: "localhost:6379"}, logging.NewLogger("json", io.Discard))
 
 	// happy logpath.
-	err := rdb.Set(context.Background(), testKey, testValue)
+	err := rdb.Set(context.Background(), testKey, testValue, time.Hour)
 	assert.Nil(t, err)
 
 	// timeout.
@@ -43,15 +43,15 @@ func TestRapidDb_Set(t *testing.T) {
 	defer cancel()
 	time.Sleep(time.Millisecond)
 
-	err = rdb.Set(ctxExp, testKey, testValue)
-	assert.Equal(t, ErrFailed, err)
+	err = rdb.Set(ctxExp, testKey, testValue, time.Hour)
+	assert.Equal(t, ErrTimeout, err)
 
 	// closed client.
 	err = rdb.Close()
 	if err != nil {
 		t.Errorf("failed to close connection to cache during test: %v\n", err)
 	}
-	err = rdb.Set(context.Background(), testKey, testValue)
+	err = rdb.Set(context.Background(), testKey, testValue, time.Hour)
 	assert.Equal(t, ErrClientClosed, err)
 }
 
@@ -82,7 +82,7 @@ func TestRapidDb_Get(t *testing.T) {
 	time.Sleep(time.Millisecond)
 
 	res, err := rdb.Get(ctxExp, testValue)
-	assert.Equal(t, ErrFailed, err)
+	assert.Equal(t, ErrTimeout, err)
 	assert.Equal(t, "", res)
 
 	// closed client.
@@ -94,3 +94,24 @@ func TestRapidDb_Get(t *testing.T) {
 	assert.Equal(t, ErrClientClosed, err)
 	assert.Equal(t, "", res)
 }
+
+func TestRapidDb_Hit(t *testing.T) {
+	rdb := NewCacheDb(Opts{Addr: redisAddr}, logging.NewLogger("json", io.Discard))
+	defer rdb.Close()
+
+	ctx := context.Background()
+	rdb.rdb.Del(ctx, HitsKey("hit_alias"))
+
+	for i := int64(1); i <= 3; i++ {
+		hits, err := rdb.Hit(ctx, "hit_alias", time.Minute)
+		assert.Nil(t, err)
+		assert.Equal(t, i, hits)
+	}
+
+	// окно задаётся первым переходом и не продлевается следующими.
+	ttl, err := rdb.rdb.TTL(ctx, HitsKey("hit_alias")).Result()
+	assert.Nil(t, err)
+	assert.True(t, ttl > 0 && ttl <= time.Minute)
+
+	rdb.rdb.Del(ctx, HitsKey("hit_alias"))
+}
@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"Darkyfun/UrlShortener/internal/logging"
 	"context"
 	"fmt"
 	"time"
@@ -13,20 +14,27 @@ type Pinger interface {
 }
 
 type Logger interface {
-	Log(string, string)
+	Log(ctx context.Context, level string, msg string, fields ...logging.Field)
 }
 
-// PingCache циклично отправляет ping-запросы в кэш для его мониторинга.
-func PingCache(c Pinger, log Logger) {
-	for {
-		ctx, cancel := context.WithTimeout(context.Background(), timeInterval)
+// PingCache циклично отправляет ping-запросы в кэш для его мониторинга, пока не отменён ctx.
+func PingCache(ctx context.Context, c Pinger, log Logger) {
+	ticker := time.NewTicker(timeInterval)
+	defer ticker.Stop()
 
-		err := c.Ping(ctx)
-		if err != nil {
+	for {
+		pingCtx, cancel := context.WithTimeout(ctx, timeInterval)
+		err := c.Ping(pingCtx)
+		cancel()
+		if err != nil && ctx.Err() == nil {
 			fmt.Printf("ping cache timeout with %v interval: %v\n", timeInterval, fmt.Errorf("connection closed or connection error"))
-			log.Log("warn", "ping cache timeout: "+fmt.Sprint(timeInterval))
+			log.Log(ctx, "warn", "ping cache timeout", logging.Duration("interval", timeInterval), logging.Err(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
 		}
-		time.Sleep(timeInterval)
-		cancel()
 	}
 }
@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"os"
+)
+
+var ErrInvalidOpts = errors.New("invalid cache options")
+
+// Режимы подключения к Redis.
+const (
+	ModeStandalone = "standalone"
+	ModeSentinel   = "sentinel"
+	ModeCluster    = "cluster"
+)
+
+// Opts - это опции, необходимые для подключения к кэшу.
+type Opts struct {
+	Addr, User, Password string
+	MaxRetries, PoolSize int
+
+	// Mode - режим подключения: standalone (по умолчанию), sentinel или cluster.
+	Mode string
+	// Addrs - адреса sentinel-узлов или узлов кластера. Если пусто, используется Addr.
+	Addrs []string
+	// MasterName - имя мастера, за которым следят sentinel-узлы.
+	MasterName                     string
+	SentinelUser, SentinelPassword string
+
+	TLS TLSOpts
+}
+
+// TLSOpts - это настройки TLS-подключения к Redis.
+type TLSOpts struct {
+	Enabled bool
+	// CAFile - сертификат центра сертификации, по умолчанию используются системные.
+	CAFile string
+	// CertFile и KeyFile - клиентский сертификат и ключ, если сервер требует mTLS.
+	CertFile, KeyFile  string
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// addrs возвращает адреса узлов для выбранного режима.
+func (o Opts) addrs() []string {
+	if len(o.Addrs) > 0 {
+		return o.Addrs
+	}
+	if o.Addr != "" {
+		return []string{o.Addr}
+	}
+	return nil
+}
+
+// universal возвращает опции go-redis для всех режимов подключения.
+func (o Opts) universal() (*redis.UniversalOptions, error) {
+	tlsConf, err := o.TLS.config()
+	if err != nil {
+		return nil, err
+	}
+
+	return &redis.UniversalOptions{
+		Addrs:            o.addrs(),
+		Username:         o.User,
+		Password:         o.Password,
+		SentinelUsername: o.SentinelUser,
+		SentinelPassword: o.SentinelPassword,
+		MaxRetries:       o.MaxRetries,
+		PoolSize:         o.PoolSize,
+		MasterName:       o.MasterName,
+		TLSConfig:        tlsConf,
+	}, nil
+}
+
+// newClient создаёт клиент Redis для режима o.Mode.
+// Режим выбирается явно, а не по числу адресов, как в redis.NewUniversalClient,
+// чтобы кластер с одним адресом для обнаружения узлов не превратился в обычный клиент.
+func newClient(o Opts) (redis.UniversalClient, error) {
+	opts, err := o.universal()
+	if err != nil {
+		return nil, err
+	}
+
+	switch o.Mode {
+	case "", ModeStandalone:
+		return redis.NewClient(opts.Simple()), nil
+	case ModeSentinel:
+		if o.MasterName == "" {
+			return nil, fmt.Errorf("%w: sentinel mode needs master name", ErrInvalidOpts)
+		}
+		return redis.NewFailoverClient(opts.Failover()), nil
+	case ModeCluster:
+		return redis.NewClusterClient(opts.Cluster()), nil
+	}
+	return nil, fmt.Errorf("%w: unknown mode %q", ErrInvalidOpts, o.Mode)
+}
+
+// config возвращает настройки TLS или nil, если TLS выключен.
+func (t TLSOpts) config() (*tls.Config, error) {
+	if !t.Enabled {
+		return nil, nil
+	}
+
+	conf := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: can not read CA file: %v", ErrInvalidOpts, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: no certificates in CA file %s", ErrInvalidOpts, t.CAFile)
+		}
+		conf.RootCAs = pool
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: can not load client certificate: %v", ErrInvalidOpts, err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	return conf, nil
+}
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert создаёт самоподписанный сертификат и ключ во временной директории.
+func writeCert(t *testing.T) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "redis"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	assert.Nil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.Nil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certFile, keyFile
+}
+
+func TestNewClient(t *testing.T) {
+	certFile, keyFile := writeCert(t)
+	opts := Opts{Addr: "localhost:6379", User: "app", Password: "secret", MaxRetries: 5, PoolSize: 42}
+
+	// все опции передаются клиенту.
+	client, err := newClient(opts)
+	assert.Nil(t, err)
+	simple := client.(*redis.Client).Options()
+	assert.Equal(t, "localhost:6379", simple.Addr)
+	assert.Equal(t, "app", simple.Username)
+	assert.Equal(t, "secret", simple.Password)
+	assert.Equal(t, 5, simple.MaxRetries)
+	assert.Equal(t, 42, simple.PoolSize)
+	assert.Nil(t, simple.TLSConfig)
+	_ = client.Close()
+
+	// кластер с одним адресом для обнаружения узлов остаётся кластером.
+	cluster := opts
+	cluster.Mode = ModeCluster
+	cluster.TLS = TLSOpts{Enabled: true, CAFile: certFile, CertFile: certFile, KeyFile: keyFile, ServerName: "redis"}
+	client, err = newClient(cluster)
+	assert.Nil(t, err)
+	clusterOpts := client.(*redis.ClusterClient).Options()
+	assert.Equal(t, []string{"localhost:6379"}, clusterOpts.Addrs)
+	assert.Equal(t, 42, clusterOpts.PoolSize)
+	assert.NotNil(t, clusterOpts.TLSConfig.RootCAs)
+	assert.Len(t, clusterOpts.TLSConfig.Certificates, 1)
+	assert.Equal(t, "redis", clusterOpts.TLSConfig.ServerName)
+	_ = client.Close()
+
+	sentinel := opts
+	sentinel.Mode = ModeSentinel
+	sentinel.Addrs = []string{"s1:26379", "s2:26379"}
+	sentinel.MasterName = "mymaster"
+	client, err = newClient(sentinel)
+	assert.Nil(t, err)
+	assert.IsType(t, &redis.Client{}, client)
+	_ = client.Close()
+
+	tests := []struct {
+		name string
+		opts Opts
+	}{
+		{name: "unknown mode", opts: Opts{Mode: "ring"}},
+		{name: "sentinel without master", opts: Opts{Mode: ModeSentinel, Addrs: []string{"s1:26379"}}},
+		{name: "missing CA", opts: Opts{TLS: TLSOpts{Enabled: true, CAFile: filepath.Join(t.TempDir(), "none.pem")}}},
+		{name: "CA without certificates", opts: Opts{TLS: TLSOpts{Enabled: true, CAFile: keyFile}}},
+		{name: "cert without key", opts: Opts{TLS: TLSOpts{Enabled: true, CertFile: certFile}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newClient(tt.opts)
+			assert.ErrorIs(t, err, ErrInvalidOpts)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package cache
+
+import "time"
+
+// TTLPolicy - это правила выбора времени жизни записей кэша, относящихся к псевдониму.
+type TTLPolicy struct {
+	// Base - время жизни записи по умолчанию.
+	Base time.Duration
+	// Sliding продлевает время жизни записи при каждом попадании в кэш.
+	Sliding bool
+	// PopularTTL - время жизни записей популярных псевдонимов.
+	PopularTTL time.Duration
+	// Threshold - число переходов за Window, начиная с которого псевдоним считается популярным. 0 отключает учёт популярности.
+	Threshold int64
+	// Window - окно, за которое считаются переходы.
+	Window time.Duration
+}
+
+// DefaultTTLPolicy - это правила по умолчанию: час без продления и без учёта популярности.
+var DefaultTTLPolicy = TTLPolicy{Base: time.Hour}
+
+// CountsHits сообщает, нужно ли считать переходы по псевдонимам.
+func (p TTLPolicy) CountsHits() bool {
+	return p.Threshold > 0 && p.Window > 0
+}
+
+// Popular сообщает, что псевдоним с hits переходами за окно считается популярным.
+func (p TTLPolicy) Popular(hits int64) bool {
+	return p.CountsHits() && hits >= p.Threshold
+}
+
+// Refresh сообщает, нужно ли продлить время жизни записей при попадании в кэш.
+func (p TTLPolicy) Refresh(hits int64) bool {
+	return p.Sliding || p.Popular(hits)
+}
+
+// TTL возвращает время жизни записей псевдонима с hits переходами за окно.
+// Время жизни не превышает срок действия ссылки expiresAt, если он задан.
+// 0 означает, что ссылка уже истекла и кэшировать её не нужно.
+func (p TTLPolicy) TTL(hits int64, expiresAt time.Time, now time.Time) time.Duration {
+	ttl := p.Base
+	if p.Popular(hits) && p.PopularTTL > ttl {
+		ttl = p.PopularTTL
+	}
+
+	if !expiresAt.IsZero() {
+		if left := expiresAt.Sub(now); left < ttl {
+			ttl = left
+		}
+	}
+	if ttl <= 0 {
+		return 0
+	}
+	return ttl
+}
@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTTLPolicy(t *testing.T) {
+	now := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	p := TTLPolicy{Base: time.Hour, PopularTTL: 24 * time.Hour, Threshold: 100, Window: time.Minute}
+
+	tests := []struct {
+		name      string
+		policy    TTLPolicy
+		hits      int64
+		expiresAt time.Time
+		ttl       time.Duration
+		refresh   bool
+	}{
+		{name: "base", policy: p, hits: 5, ttl: time.Hour},
+		{name: "popular", policy: p, hits: 100, ttl: 24 * time.Hour, refresh: true},
+		{name: "capped by expiry", policy: p, hits: 100, expiresAt: now.Add(10 * time.Minute), ttl: 10 * time.Minute, refresh: true},
+		{name: "expired", policy: p, expiresAt: now.Add(-time.Second), ttl: 0},
+		{name: "sliding", policy: TTLPolicy{Base: time.Hour, Sliding: true}, ttl: time.Hour, refresh: true},
+		{name: "no threshold", policy: TTLPolicy{Base: time.Hour, PopularTTL: 24 * time.Hour}, hits: 1000, ttl: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.ttl, tt.policy.TTL(tt.hits, tt.expiresAt, now))
+			assert.Equal(t, tt.refresh, tt.policy.Refresh(tt.hits))
+		})
+	}
+}
@@ -0,0 +1,18 @@
+// Package storage содержит общую для кэша и SQL-базы данных классификацию ошибок.
+// Хранилища оборачивают ошибки драйверов в одну из ошибок ниже, а транспорты проверяют их через errors.Is,
+// не завися от текста ошибок драйверов.
+package storage
+
+import "errors"
+
+// ErrNotFound - запись не найдена.
+var ErrNotFound = errors.New("not found")
+
+// ErrConflict - запись с таким ключом уже существует.
+var ErrConflict = errors.New("conflict")
+
+// ErrUnavailable - хранилище недоступно: соединение закрыто, потеряно или не может быть установлено.
+var ErrUnavailable = errors.New("storage unavailable")
+
+// ErrTimeout - хранилище не ответило вовремя.
+var ErrTimeout = errors.New("storage timeout")
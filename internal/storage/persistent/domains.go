@@ -0,0 +1,66 @@
+package persistent
+
+import (
+	"Darkyfun/UrlShortener/internal/links"
+	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/storage"
+	"Darkyfun/UrlShortener/internal/workspace"
+	"context"
+	"errors"
+)
+
+// AddDomain регистрирует собственный домен в рабочем пространстве запроса.
+// Домен, уже зарегистрированный в любом рабочем пространстве, возвращает ErrAlreadyExists.
+func (d *Db) AddDomain(ctx context.Context, host string) (links.Domain, error) {
+	dom := links.Domain{Host: host, WorkspaceID: workspace.Of(ctx)}
+	err := d.pool.QueryRow(ctx, `insert into domains (host, workspace_id) values ($1, $2) returning created_at`,
+		host, dom.WorkspaceID).Scan(&dom.CreatedAt)
+
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to insert domain in sql", logging.String("host", host), logging.Err(err))
+	}
+
+	return dom, err
+}
+
+// ListDomains возвращает зарегистрированные домены рабочего пространства запроса.
+func (d *Db) ListDomains(ctx context.Context) ([]links.Domain, error) {
+	rows, err := d.pool.Query(ctx, `select host, workspace_id, created_at from domains
+	where $1::bigint is null or workspace_id = $1 order by host`, workspace.Scope(ctx))
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to select domains in sql", logging.Err(err))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	domains := make([]links.Domain, 0)
+	for rows.Next() {
+		var dom links.Domain
+		if err = rows.Scan(&dom.Host, &dom.WorkspaceID, &dom.CreatedAt); err != nil {
+			return nil, err
+		}
+		domains = append(domains, dom)
+	}
+
+	return domains, classify(rows.Err())
+}
+
+// DeleteDomain удаляет домен. Ссылки на домене остаются в базе данных, но не открываются, пока домен не зарегистрирован снова.
+func (d *Db) DeleteDomain(ctx context.Context, host string) error {
+	tag, err := d.pool.Exec(ctx, `delete from domains where host = $1 and ($2::bigint is null or workspace_id = $2)`,
+		host, workspace.Scope(ctx))
+
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to delete domain in sql", logging.String("host", host), logging.Err(err))
+	}
+	if err == nil && tag.RowsAffected() == 0 {
+		return ErrNoRows
+	}
+
+	return err
+}
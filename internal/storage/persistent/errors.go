@@ -0,0 +1,62 @@
+package persistent
+
+import (
+	"Darkyfun/UrlShortener/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/puddle/v2"
+	"net"
+	"strings"
+)
+
+var ErrConnect = fmt.Errorf("unable to connect: %w", storage.ErrTimeout)
+var ErrNoRows = fmt.Errorf("no rows: %w", storage.ErrNotFound)
+var ErrAlreadyExists = fmt.Errorf("alias already exists: %w", storage.ErrConflict)
+var ErrConnClosed = fmt.Errorf("connection closed: %w", storage.ErrUnavailable)
+
+// Коды SQLSTATE, которые различает classify.
+const (
+	codeUniqueViolation = "23505"
+	codeQueryCanceled   = "57014"
+	codeTooManyConns    = "53300"
+)
+
+// classify приводит ошибку драйвера к ошибке из классификации пакета storage.
+// Ошибки, не относящиеся ни к одному классу, возвращаются без изменений.
+func classify(err error) error {
+	var pgErr *pgconn.PgError
+	var netErr net.Error
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, pgx.ErrNoRows):
+		return ErrNoRows
+	case errors.As(err, &pgErr):
+		return classifyPg(pgErr)
+	case errors.Is(err, puddle.ErrClosedPool):
+		return ErrConnClosed
+	case errors.Is(err, context.DeadlineExceeded), pgconn.Timeout(err):
+		return ErrConnect
+	case errors.As(err, &netErr):
+		return fmt.Errorf("%w: %w", storage.ErrUnavailable, err)
+	}
+	return err
+}
+
+// classifyPg классифицирует ошибку, которую вернул сервер, по её коду SQLSTATE.
+func classifyPg(err *pgconn.PgError) error {
+	switch {
+	case err.Code == codeUniqueViolation:
+		return ErrAlreadyExists
+	case err.Code == codeQueryCanceled:
+		return fmt.Errorf("%w: %w", storage.ErrTimeout, err)
+	// класс 08 - ошибки соединения, класс 57P - остановка сервера.
+	case err.Code == codeTooManyConns, strings.HasPrefix(err.Code, "08"), strings.HasPrefix(err.Code, "57P"):
+		return fmt.Errorf("%w: %w", storage.ErrUnavailable, err)
+	}
+	return err
+}
@@ -0,0 +1,42 @@
+package persistent
+
+import (
+	"Darkyfun/UrlShortener/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/puddle/v2"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "no rows", err: fmt.Errorf("scan: %w", pgx.ErrNoRows), want: storage.ErrNotFound},
+		{name: "unique violation", err: &pgconn.PgError{Code: "23505", Message: "duplicate key value violates unique constraint \"url_pkey\""}, want: storage.ErrConflict},
+		{name: "statement timeout", err: &pgconn.PgError{Code: "57014"}, want: storage.ErrTimeout},
+		{name: "admin shutdown", err: &pgconn.PgError{Code: "57P01"}, want: storage.ErrUnavailable},
+		{name: "connection failure", err: &pgconn.PgError{Code: "08006"}, want: storage.ErrUnavailable},
+		{name: "closed pool", err: puddle.ErrClosedPool, want: storage.ErrUnavailable},
+		{name: "deadline", err: context.DeadlineExceeded, want: storage.ErrTimeout},
+		{name: "network", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: storage.ErrUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, classify(tt.err), tt.want)
+		})
+	}
+
+	// прочие ошибки сервера не относятся ни к одному классу.
+	syntax := &pgconn.PgError{Code: "42601"}
+	assert.Equal(t, syntax, classify(syntax))
+	assert.Nil(t, classify(nil))
+}
@@ -1,6 +1,7 @@
 package persistent
 
 import (
+	"Darkyfun/UrlShortener/internal/logging"
 	"context"
 	"fmt"
 	"time"
@@ -13,20 +14,27 @@ type Pinger interface {
 }
 
 type Logger interface {
-	Log(string, string)
+	Log(ctx context.Context, level string, msg string, fields ...logging.Field)
 }
 
-// PingStorage циклично отправляет ping-запросы в базу данных для её мониторинга.
-func PingStorage(s Pinger, log Logger) {
-	for {
-		ctx, cancel := context.WithTimeout(context.Background(), timeInterval)
+// PingStorage циклично отправляет ping-запросы в базу данных для её мониторинга, пока не отменён ctx.
+func PingStorage(ctx context.Context, s Pinger, log Logger) {
+	ticker := time.NewTicker(timeInterval)
+	defer ticker.Stop()
 
-		err := s.Ping(ctx)
-		if err != nil {
+	for {
+		pingCtx, cancel := context.WithTimeout(ctx, timeInterval)
+		err := s.Ping(pingCtx)
+		cancel()
+		if err != nil && ctx.Err() == nil {
 			fmt.Printf("ping database timeout with %v interval: %v\n", timeInterval, fmt.Errorf("connection closed or connection error"))
-			log.Log("warn", "ping database timeout: "+fmt.Sprint(timeInterval))
+			log.Log(ctx, "warn", "ping database timeout", logging.Duration("interval", timeInterval), logging.Err(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
 		}
-		time.Sleep(timeInterval)
-		cancel()
 	}
 }
@@ -0,0 +1,86 @@
+package persistent
+
+import (
+	"Darkyfun/UrlShortener/internal/outbox"
+	"context"
+	"encoding/json"
+)
+
+// outboxLock - это ключ рекомендательной блокировки, под которой события outbox ретранслирует
+// только один экземпляр сервиса за раз, чтобы события публиковались в порядке записи.
+const outboxLock = 0x6f7574626f78
+
+// change выполняет запрос изменения одной ссылки и в той же транзакции записывает в outbox событие typ с полями data,
+// если запрос изменил хотя бы одну строку. Возвращает число изменённых строк.
+func (d *Db) change(ctx context.Context, typ, alias string, data any, query string, args ...any) (int64, error) {
+	var raw []byte
+	if data != nil {
+		var err error
+		if raw, err = json.Marshal(data); err != nil {
+			return 0, err
+		}
+	}
+
+	tx, err := d.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	tag, err := tx.Exec(ctx, query, args...)
+	if err != nil || tag.RowsAffected() == 0 {
+		return 0, err
+	}
+
+	_, err = tx.Exec(ctx, `insert into outbox (type, alias, data) values ($1, $2, $3)`, typ, alias, raw)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), tx.Commit(ctx)
+}
+
+// RelayOutbox передаёт в fn не более n самых старых событий outbox и удаляет их в той же транзакции,
+// если fn не вернула ошибку. Пока события ретранслирует другой экземпляр сервиса, ничего не делает.
+func (d *Db) RelayOutbox(ctx context.Context, n int, fn func([]outbox.Event) error) (int, error) {
+	tx, err := d.pool.Begin(ctx)
+	if err != nil {
+		return 0, classify(err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var locked bool
+	if err = tx.QueryRow(ctx, `select pg_try_advisory_xact_lock($1)`, outboxLock).Scan(&locked); err != nil || !locked {
+		return 0, classify(err)
+	}
+
+	rows, err := tx.Query(ctx, `select id, type, alias, data, created_at from outbox order by id limit $1`, n)
+	if err != nil {
+		return 0, classify(err)
+	}
+
+	var events []outbox.Event
+	var ids []int64
+	for rows.Next() {
+		var ev outbox.Event
+		if err = rows.Scan(&ev.ID, &ev.Type, &ev.Alias, &ev.Data, &ev.CreatedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, ev)
+		ids = append(ids, ev.ID)
+	}
+	rows.Close()
+	if err = classify(rows.Err()); err != nil || len(events) == 0 {
+		return 0, err
+	}
+
+	if err = fn(events); err != nil {
+		return 0, err
+	}
+
+	if _, err = tx.Exec(ctx, `delete from outbox where id = any ($1)`, ids); err != nil {
+		return 0, classify(err)
+	}
+	return len(events), classify(tx.Commit(ctx))
+}
@@ -2,33 +2,173 @@
 package persistent
 
 import (
+	"Darkyfun/UrlShortener/internal/clicks"
+	"Darkyfun/UrlShortener/internal/links"
+	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/outbox"
+	"Darkyfun/UrlShortener/internal/rules"
+	"Darkyfun/UrlShortener/internal/split"
+	"Darkyfun/UrlShortener/internal/storage"
+	"Darkyfun/UrlShortener/internal/workspace"
 	"context"
+	"encoding/json"
 	"errors"
-	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 )
 
-var ErrConnect = errors.New("unable to connect")
-var ErrNoRows = errors.New("no rows")
-var ErrAlreadyExists = errors.New("alias already exists")
-var ErrConnClosed = errors.New("connection closed")
+// schema - это запросы, приводящие схему базы данных к актуальному виду. Запросы должны быть идемпотентными.
+var schema = []string{
+	`create table if not exists url (
+    alias varchar primary key ,
+    original varchar,
+    created_date timestamp
+	);`,
+	`alter table url add column if not exists rules jsonb;`,
+	`alter table url add column if not exists split jsonb;`,
+	`create table if not exists clicks (
+    id bigserial primary key,
+    alias varchar not null,
+    variant varchar,
+    destination varchar,
+    referrer varchar,
+    user_agent varchar,
+    clicked_at timestamp
+	);`,
+	`create index if not exists clicks_alias_clicked_at_idx on clicks (alias, clicked_at);`,
+	`alter table url add column if not exists title varchar;`,
+	`alter table url add column if not exists description varchar;`,
+	`alter table url add column if not exists tags text[];`,
+	`alter table url add column if not exists owner varchar;`,
+	`create index if not exists url_tags_idx on url using gin (tags);`,
+	`create index if not exists url_owner_idx on url (owner);`,
+	`create index if not exists url_created_date_idx on url (created_date, alias);`,
+	`alter table url add column if not exists expires_at timestamptz;`,
+	`alter table url add column if not exists click_count bigint not null default 0;`,
+	`create table if not exists webhooks (
+    id bigserial primary key,
+    url varchar not null,
+    secret varchar not null,
+    events text[] not null,
+    threshold bigint not null default 0,
+    created_at timestamptz not null default now()
+	);`,
+	`create table if not exists webhook_deliveries (
+    id bigserial primary key,
+    hook_id bigint not null references webhooks (id) on delete cascade,
+    event varchar not null,
+    payload jsonb not null,
+    status varchar not null default 'pending',
+    attempts int not null default 0,
+    last_status int not null default 0,
+    last_error varchar not null default '',
+    next_attempt_at timestamptz not null default now(),
+    created_at timestamptz not null default now()
+	);`,
+	`create index if not exists webhook_deliveries_due_idx on webhook_deliveries (next_attempt_at) where status = 'pending';`,
+	`create index if not exists webhook_deliveries_hook_idx on webhook_deliveries (hook_id, created_at);`,
+	`create table if not exists webhook_dead_letters (
+    id bigint primary key,
+    hook_id bigint not null references webhooks (id) on delete cascade,
+    event varchar not null,
+    payload jsonb not null,
+    attempts int not null,
+    last_status int not null,
+    last_error varchar not null,
+    created_at timestamptz not null,
+    failed_at timestamptz not null default now()
+	);`,
+	`alter table url add column if not exists expiry_notified boolean not null default false;`,
+	`create index if not exists url_expiry_pending_idx on url (expires_at) where not expiry_notified;`,
+	`create table if not exists outbox (
+    id bigserial primary key,
+    type varchar not null,
+    alias varchar not null,
+    data jsonb,
+    created_at timestamptz not null default now()
+	);`,
+	`create table if not exists domains (
+    host varchar primary key,
+    created_at timestamptz not null default now()
+	);`,
+	`create table if not exists workspaces (
+    id bigserial primary key,
+    name varchar not null unique,
+    max_links bigint not null default 0,
+    created_at timestamptz not null default now()
+	);`,
+	`create table if not exists workspace_members (
+    workspace_id bigint not null references workspaces (id) on delete cascade,
+    name varchar not null,
+    role varchar not null,
+    created_at timestamptz not null default now(),
+    primary key (workspace_id, name)
+	);`,
+	`create table if not exists api_keys (
+    key_hash bytea primary key,
+    workspace_id bigint not null,
+    member varchar not null,
+    created_at timestamptz not null default now(),
+    foreign key (workspace_id, member) references workspace_members (workspace_id, name) on delete cascade
+	);`,
+	`alter table url add column if not exists workspace_id bigint not null default 0;`,
+	`create index if not exists url_workspace_idx on url (workspace_id, created_date, alias);`,
+	`alter table domains add column if not exists workspace_id bigint not null default 0;`,
+	`alter table url add column if not exists campaign varchar;`,
+	`alter table webhooks add column if not exists workspace_id bigint not null default 0;`,
+	`create index if not exists url_campaign_idx on url (workspace_id, campaign) where campaign is not null;`,
+	`alter table clicks add column if not exists country varchar;`,
+	`alter table clicks add column if not exists inserted_at timestamptz not null default now();`,
+	`create table if not exists click_rollups (
+    alias varchar not null,
+    bucket timestamptz not null,
+    dimension varchar not null,
+    value varchar not null,
+    clicks bigint not null,
+    primary key (alias, dimension, bucket, value)
+	);`,
+	`create table if not exists click_rollup_position (
+    id boolean primary key default true check (id),
+    last_click_id bigint not null
+	);`,
+	`insert into click_rollup_position (last_click_id) values (0) on conflict do nothing;`,
+	`alter table clicks add column if not exists xid xid8 not null default pg_current_xact_id();`,
+	`create index if not exists clicks_xid_idx on clicks (xid, id);`,
+	`alter table click_rollup_position add column if not exists last_xid xid8;`,
+	`update click_rollup_position p set last_xid = coalesce((select min(xid) from clicks where id > p.last_click_id),
+    (select max(xid) from clicks), '0'::xid8) where last_xid is null;`,
+}
 
 // Db - это структура, реализующая запросы к SQL-базе данных.
+// Все записи и большинство чтений идут на основной сервер, а GetOriginal и GetAlias - на реплики, если они есть.
+// Запросы к ссылкам и доменам ограничены рабочим пространством из контекста (см. workspace.Scope), если оно задано.
 type Db struct {
-	pool *pgxpool.Pool
-	log  Logger
+	pool     *pgxpool.Pool
+	replicas *replicas
+	log      Logger
 }
 
-// Close закрывает пул соединений.
+// Close закрывает пулы соединений с основным сервером и репликами.
 func (d *Db) Close() {
 	d.pool.Close()
+	d.replicas.close()
 }
 
-// NewDb возвращает новую структуру Db, готовую к работе с базой данных.
+// NewDb возвращает новую структуру Db, готовую к работе с базой данных без реплик.
 func NewDb(ctx context.Context, logger Logger, conn string) Db {
-	pool, err := pgxpool.New(ctx, conn)
+	return Connect(ctx, logger, Opts{Primary: conn})
+}
+
+// Connect возвращает новую структуру Db, подключённую к основному серверу и репликам из opts.
+func Connect(ctx context.Context, logger Logger, opts Opts) Db {
+	conf, err := poolConfig(opts.Primary, opts.Pool)
+	if err != nil {
+		log.Fatalf("%s: %v", ErrConnect, err)
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, conf)
 	if err != nil {
 		log.Fatalf("%s", ErrConnect)
 	}
@@ -40,81 +180,479 @@ func NewDb(ctx context.Context, logger Logger, conn string) Db {
 		log.Fatal(err)
 	}
 
-	table := `create table if not exists url (
-    alias varchar primary key ,
-    original varchar,
-    created_date timestamp
-	);`
+	for _, query := range schema {
+		_, _ = pool.Exec(ctx, query)
+	}
 
-	_, _ = pool.Exec(ctx, table)
+	rs, err := connectReplicas(ctx, logger, opts.Replicas, opts.Pool)
+	if err != nil {
+		log.Fatalf("%s: %v", ErrConnect, err)
+	}
 
 	return Db{
-		pool: pool,
-		log:  logger,
+		pool:     pool,
+		replicas: rs,
+		log:      logger,
 	}
 }
 
-// GetOriginal возвращает из базы данных оригинальный URL по указанному псевдониму.
+// GetOriginal возвращает из базы данных оригинальный URL по указанному псевдониму. Читает с реплики, если она доступна.
 func (d *Db) GetOriginal(ctx context.Context, alias string) (string, error) {
-	res := d.pool.QueryRow(ctx, `select original from url where alias = $1`, alias)
 	var orig string
+	err := d.readRow(ctx, &orig, `select original from url where alias = $1 and ($2::bigint is null or workspace_id = $2)`,
+		alias, workspace.Scope(ctx))
+
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to select in sql", logging.String("alias", alias), logging.String("original", orig), logging.Err(err))
+	}
 
-	err := res.Scan(&orig)
+	return orig, err
+}
+
+// GetAlias возвращает из базы данных ключ бессрочной ссылки на домене domain по указанному оригинальному URL.
+// Пустой domain означает ссылки без собственного домена. Читает с реплики, если она доступна.
+func (d *Db) GetAlias(ctx context.Context, domain, orig string) (string, error) {
+	var alias string
+	err := d.readRow(ctx, &alias, `select alias from url where original = $1 and expires_at is null
+	and case when $2 = '' then strpos(alias, '/') = 0 else starts_with(alias, $2 || '/') end
+	and ($3::bigint is null or workspace_id = $3) limit 1`, orig, domain, workspace.Scope(ctx))
 
-	if err != nil && errors.Is(err, pgx.ErrNoRows) {
-		return "", ErrNoRows
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to select in sql", logging.String("alias", alias), logging.String("original", orig), logging.Err(err))
 	}
-	if err != nil && err.Error() == `closed pool` {
-		d.log.Log("error", "unable to insert "+alias+" "+orig+" in sql: pool is closed")
-		return "", ErrConnClosed
+
+	return alias, err
+}
+
+// Set записывает в базу данных оригинальный URL и его псевдоним в рабочее пространство запроса.
+// Если в рабочем пространстве уже столько ссылок, сколько позволяет его квота, возвращает workspace.ErrQuotaExceeded.
+func (d *Db) Set(ctx context.Context, alias string, orig string) error {
+	return d.Create(ctx, alias, links.Draft{Original: orig})
+}
+
+// Create записывает в базу данных новую ссылку вместе с правилами, A/B-распределением, метаданными и сроком действия
+// одним запросом, так что ссылка появляется сразу со всеми атрибутами или не появляется вовсе.
+// Ограничения те же, что у Set.
+func (d *Db) Create(ctx context.Context, alias string, l links.Draft) error {
+	data := map[string]any{"original": l.Original}
+	var rulesRaw, splitRaw []byte
+	var exp *time.Time
+	if len(l.Rules) > 0 {
+		rulesRaw, _ = json.Marshal(l.Rules)
+		data["rules"] = l.Rules
+	}
+	if !l.Split.Empty() {
+		splitRaw, _ = json.Marshal(l.Split)
+		data["split"] = l.Split
+	}
+	if !l.Meta.Empty() {
+		data["meta"] = l.Meta
 	}
-	if errors.Is(err, context.DeadlineExceeded) {
-		return "", ErrConnect
+	if !l.ExpiresAt.IsZero() {
+		exp = &l.ExpiresAt
+		data["expires_at"] = exp
 	}
 
-	return orig, err
+	// квота проверяется подсчётом ссылок по индексу url_workspace_idx, одновременные вставки могут превысить её на единицы.
+	n, err := d.change(ctx, outbox.LinkCreated, alias, data,
+		`insert into url (alias, original, created_date, workspace_id, rules, split, title, description, tags, owner, campaign, expires_at)
+		select $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+		where coalesce((select max_links from workspaces where id = $4), 0) = 0
+		or (select count(*) from url where workspace_id = $4) < (select max_links from workspaces where id = $4)`,
+		alias, l.Original, time.Now(), workspace.Of(ctx), rulesRaw, splitRaw, nullable(l.Meta.Title), nullable(l.Meta.Description),
+		l.Meta.Tags, nullable(l.Meta.Owner), nullable(l.Meta.Campaign), exp)
+
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to insert in sql", logging.String("alias", alias), logging.String("original", l.Original), logging.Err(err))
+	}
+	if err == nil && n == 0 {
+		return workspace.ErrQuotaExceeded
+	}
+
+	return err
 }
 
-// GetAlias возвращает из базы данных псевдоним по указанному оригинальному URL.
-func (d *Db) GetAlias(ctx context.Context, orig string) (string, error) {
-	res := d.pool.QueryRow(ctx, `select alias from url where original = $1`, orig)
-	var alias string
+// Ping проверяет соединение с базой данной и обновляет состояние реплик.
+// Ошибка возвращается только для основного сервера: без реплик сервис продолжает работать.
+func (d *Db) Ping(ctx context.Context) error {
+	d.replicas.check(ctx, d.log)
+	return d.pool.Ping(ctx)
+}
+
+// GetRules возвращает из базы данных набор правил перенаправления для указанного псевдонима.
+// Если правил у псевдонима нет, возвращается пустой набор.
+func (d *Db) GetRules(ctx context.Context, alias string) (rules.Set, error) {
+	res := d.pool.QueryRow(ctx, `select rules from url where alias = $1 and ($2::bigint is null or workspace_id = $2)`,
+		alias, workspace.Scope(ctx))
+	var raw []byte
 
-	err := res.Scan(&alias)
+	err := res.Scan(&raw)
 
-	if err != nil && errors.Is(err, pgx.ErrNoRows) {
-		return "", ErrNoRows
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to select rules in sql", logging.String("alias", alias), logging.Err(err))
 	}
-	if err != nil && err.Error() == `closed pool` {
-		d.log.Log("error", "unable to insert "+alias+" "+orig+" in sql: pool is closed")
-		return "", ErrConnClosed
+	if err != nil {
+		return nil, err
+	}
+
+	var set rules.Set
+	if len(raw) == 0 {
+		return set, nil
 	}
-	if errors.Is(err, context.DeadlineExceeded) {
-		return "", ErrConnect
+	if err = json.Unmarshal(raw, &set); err != nil {
+		return nil, err
 	}
 
-	return alias, nil
+	return set, nil
 }
 
-// Set записывает в базу данных оригинальный URL и его псевдоним.
-func (d *Db) Set(ctx context.Context, alias string, orig string) error {
-	_, err := d.pool.Exec(ctx, `insert into url (alias, original, created_date) values ($1, $2, $3)`, alias, orig, time.Now())
+// SetRules сохраняет в базе данных набор правил перенаправления для указанного псевдонима.
+func (d *Db) SetRules(ctx context.Context, alias string, set rules.Set) error {
+	raw, err := json.Marshal(set)
+	if err != nil {
+		return err
+	}
+
+	n, err := d.change(ctx, outbox.LinkUpdated, alias, map[string]any{"rules": set},
+		`update url set rules = $2 where alias = $1 and ($3::bigint is null or workspace_id = $3)`,
+		alias, raw, workspace.Scope(ctx))
+
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to update rules in sql", logging.String("alias", alias), logging.Err(err))
+	}
+	if err == nil && n == 0 {
+		return ErrNoRows
+	}
 
-	if err != nil && err.Error() == `ERROR: duplicate key value violates unique constraint "url_pkey" (SQLSTATE 23505)` {
-		return ErrAlreadyExists
+	return err
+}
+
+// GetSplit возвращает из базы данных A/B-распределение для указанного псевдонима.
+// Если распределения у псевдонима нет, возвращается пустое распределение.
+func (d *Db) GetSplit(ctx context.Context, alias string) (split.Split, error) {
+	res := d.pool.QueryRow(ctx, `select split from url where alias = $1 and ($2::bigint is null or workspace_id = $2)`,
+		alias, workspace.Scope(ctx))
+	var raw []byte
+
+	err := res.Scan(&raw)
+
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to select split in sql", logging.String("alias", alias), logging.Err(err))
 	}
-	if err != nil && err.Error() == `closed pool` {
-		d.log.Log("error", "unable to insert "+alias+" "+orig+" in sql: pool is closed")
-		return ErrConnClosed
+	if err != nil {
+		return split.Split{}, err
+	}
+
+	var s split.Split
+	if len(raw) == 0 {
+		return s, nil
 	}
-	if errors.Is(err, context.DeadlineExceeded) {
-		return ErrConnect
+	if err = json.Unmarshal(raw, &s); err != nil {
+		return split.Split{}, err
+	}
+
+	return s, nil
+}
+
+// SetSplit сохраняет в базе данных A/B-распределение для указанного псевдонима.
+func (d *Db) SetSplit(ctx context.Context, alias string, s split.Split) error {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	n, err := d.change(ctx, outbox.LinkUpdated, alias, map[string]any{"split": s},
+		`update url set split = $2 where alias = $1 and ($3::bigint is null or workspace_id = $3)`,
+		alias, raw, workspace.Scope(ctx))
+
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to update split in sql", logging.String("alias", alias), logging.Err(err))
+	}
+	if err == nil && n == 0 {
+		return ErrNoRows
 	}
 
 	return err
 }
 
-// Ping проверяет соединение с базой данной.
-func (d *Db) Ping(ctx context.Context) error {
-	return d.pool.Ping(ctx)
+// SaveClick записывает в базу данных переход по короткой ссылке. Время перехода хранится в UTC.
+func (d *Db) SaveClick(ctx context.Context, click clicks.Click) error {
+	_, err := d.pool.Exec(ctx,
+		`insert into clicks (alias, variant, destination, referrer, user_agent, country, clicked_at) values ($1, $2, $3, $4, $5, $6, $7)`,
+		click.Alias, click.Variant, click.Destination, click.Referrer, click.UserAgent, nullable(click.Country), click.Time.UTC(),
+	)
+
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to insert click in sql", logging.String("alias", click.Alias), logging.Err(err))
+	}
+
+	return err
+}
+
+// GetExpiry возвращает из базы данных срок действия ссылки или нулевое время, если ссылка бессрочная.
+// Читает с реплики, если она доступна.
+func (d *Db) GetExpiry(ctx context.Context, alias string) (time.Time, error) {
+	var exp *time.Time
+	err := d.readRow(ctx, &exp, `select expires_at from url where alias = $1 and ($2::bigint is null or workspace_id = $2)`,
+		alias, workspace.Scope(ctx))
+
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to select expiry in sql", logging.String("alias", alias), logging.Err(err))
+	}
+	if err != nil || exp == nil {
+		return time.Time{}, err
+	}
+
+	return *exp, nil
+}
+
+// SetExpiry сохраняет в базе данных срок действия ссылки. Нулевое время делает ссылку бессрочной.
+func (d *Db) SetExpiry(ctx context.Context, alias string, exp time.Time) error {
+	var value *time.Time
+	if !exp.IsZero() {
+		value = &exp
+	}
+
+	n, err := d.change(ctx, outbox.LinkUpdated, alias, map[string]any{"expires_at": value},
+		`update url set expires_at = $2 where alias = $1 and ($3::bigint is null or workspace_id = $3)`,
+		alias, value, workspace.Scope(ctx))
+
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to update expiry in sql", logging.String("alias", alias), logging.Err(err))
+	}
+	if err == nil && n == 0 {
+		return ErrNoRows
+	}
+
+	return err
+}
+
+// SetMeta сохраняет в базе данных метаданные ссылки с указанным псевдонимом.
+func (d *Db) SetMeta(ctx context.Context, alias string, meta links.Meta) error {
+	n, err := d.change(ctx, outbox.LinkUpdated, alias, meta,
+		`update url set title = $2, description = $3, tags = $4, owner = $5, campaign = $6
+		where alias = $1 and ($7::bigint is null or workspace_id = $7)`,
+		alias, meta.Title, meta.Description, meta.Tags, meta.Owner, nullable(meta.Campaign), workspace.Scope(ctx),
+	)
+
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to update meta in sql", logging.String("alias", alias), logging.Err(err))
+	}
+	if err == nil && n == 0 {
+		return ErrNoRows
+	}
+
+	return err
+}
+
+// ListLinks возвращает страницу ссылок, подходящих под фильтр.
+// Вторым значением возвращается курсор следующей страницы или nil, если страница последняя.
+func (d *Db) ListLinks(ctx context.Context, f links.Filter) ([]links.Link, *links.Cursor, error) {
+	query, args := listQuery(f, workspace.Scope(ctx))
+
+	rows, err := d.pool.Query(ctx, query, args...)
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to list links in sql", logging.Err(err))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	res := make([]links.Link, 0, f.Limit)
+	for rows.Next() {
+		var l links.Link
+		err = rows.Scan(&l.Alias, &l.Original, &l.CreatedAt, &l.Title, &l.Description, &l.Tags, &l.Owner, &l.Campaign)
+		if err != nil {
+			return nil, nil, err
+		}
+		res = append(res, l)
+	}
+	if err = classify(rows.Err()); err != nil {
+		return nil, nil, err
+	}
+
+	if len(res) <= f.Limit {
+		return res, nil, nil
+	}
+	res = res[:f.Limit]
+	return res, links.CursorAfter(res[len(res)-1]), nil
+}
+
+// listQuery собирает SQL-запрос для поиска ссылок по фильтру в рабочем пространстве ws, nil означает все ссылки.
+// Запрос выбирает на одну ссылку больше лимита, чтобы понять, есть ли следующая страница.
+func listQuery(f links.Filter, ws *int64) (string, []any) {
+	var conds []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if ws != nil {
+		conds = append(conds, "workspace_id = "+arg(*ws))
+	}
+	if f.Tag != "" {
+		conds = append(conds, arg(f.Tag)+" = any(tags)")
+	}
+	if f.Owner != "" {
+		conds = append(conds, "owner = "+arg(f.Owner))
+	}
+	if f.Campaign != "" {
+		conds = append(conds, "campaign = "+arg(f.Campaign))
+	}
+	if !f.From.IsZero() {
+		conds = append(conds, "created_date >= "+arg(f.From))
+	}
+	if !f.To.IsZero() {
+		conds = append(conds, "created_date < "+arg(f.To))
+	}
+	if f.Query != "" {
+		conds = append(conds, `original ilike `+arg("%"+likeEscaper.Replace(f.Query)+"%"))
+	}
+
+	cmp := "<"
+	if f.Order == links.OrderAsc {
+		cmp = ">"
+	}
+	if f.Cursor != nil {
+		if f.Sort == links.SortAlias {
+			conds = append(conds, "alias "+cmp+" "+arg(f.Cursor.Alias))
+		} else {
+			conds = append(conds, "(coalesce(created_date, 'epoch'), alias) "+cmp+" ("+arg(f.Cursor.CreatedAt)+", "+arg(f.Cursor.Alias)+")")
+		}
+	}
+
+	query := `select alias, original, coalesce(created_date, 'epoch'), coalesce(title, ''), coalesce(description, ''), coalesce(tags, '{}'), coalesce(owner, ''), coalesce(campaign, '') from url`
+	if len(conds) > 0 {
+		query += " where " + strings.Join(conds, " and ")
+	}
+
+	if f.Sort == links.SortAlias {
+		query += " order by alias " + f.Order
+	} else {
+		query += " order by coalesce(created_date, 'epoch') " + f.Order + ", alias " + f.Order
+	}
+	query += " limit " + arg(f.Limit+1)
+
+	return query, args
+}
+
+// likeEscaper экранирует спецсимволы шаблона LIKE.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Delete удаляет из базы данных ссылку с указанным псевдонимом. История переходов по ссылке сохраняется.
+func (d *Db) Delete(ctx context.Context, alias string) error {
+	n, err := d.change(ctx, outbox.LinkDeleted, alias, nil, `delete from url where alias = $1 and ($2::bigint is null or workspace_id = $2)`,
+		alias, workspace.Scope(ctx))
+
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to delete in sql", logging.String("alias", alias), logging.Err(err))
+	}
+	if err == nil && n == 0 {
+		return ErrNoRows
+	}
+
+	return err
+}
+
+// ClickStats возвращает сводную статистику переходов по указанному псевдониму.
+// Статистика удалённой ссылки доступна только запросам, не ограниченным рабочим пространством.
+func (d *Db) ClickStats(ctx context.Context, alias string) (clicks.Stats, error) {
+	stats := clicks.Stats{Alias: alias}
+	err := classify(d.readRow(ctx, &stats.Count,
+		`select click_count from url where alias = $1 and ($2::bigint is null or workspace_id = $2)`, alias, workspace.Scope(ctx)))
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to select stats in sql", logging.String("alias", alias), logging.Err(err))
+	}
+	if err != nil && (!errors.Is(err, storage.ErrNotFound) || workspace.Scope(ctx) != nil) {
+		return clicks.Stats{}, err
+	}
+
+	rows, err := d.pool.Query(ctx,
+		`select coalesce(variant, ''), count(*), min(clicked_at), max(clicked_at) from clicks where alias = $1 group by 1`, alias)
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to select stats in sql", logging.String("alias", alias), logging.Err(err))
+	}
+	if err != nil {
+		return clicks.Stats{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var variant string
+		var count int64
+		var first, last time.Time
+		if err = rows.Scan(&variant, &count, &first, &last); err != nil {
+			return clicks.Stats{}, err
+		}
+
+		stats.Total += count
+		if variant != "" {
+			if stats.Variants == nil {
+				stats.Variants = make(map[string]int64)
+			}
+			stats.Variants[variant] = count
+		}
+		if stats.First.IsZero() || first.Before(stats.First) {
+			stats.First = first
+		}
+		if last.After(stats.Last) {
+			stats.Last = last
+		}
+	}
+	if err = classify(rows.Err()); err != nil {
+		return clicks.Stats{}, err
+	}
+
+	return stats, nil
+}
+
+// AddClickCounts прибавляет к столбцу click_count накопленные числа переходов по псевдонимам одним запросом
+// и возвращает итоговые счётчики. Псевдонимов, которых уже нет в базе, в результате нет.
+func (d *Db) AddClickCounts(ctx context.Context, counts map[string]int64) (map[string]int64, error) {
+	aliases := make([]string, 0, len(counts))
+	deltas := make([]int64, 0, len(counts))
+	for alias, n := range counts {
+		aliases = append(aliases, alias)
+		deltas = append(deltas, n)
+	}
+
+	rows, err := d.pool.Query(ctx, `update url set click_count = url.click_count + c.n
+	from unnest($1::varchar[], $2::bigint[]) as c(alias, n) where url.alias = c.alias
+	returning url.alias, url.click_count`, aliases, deltas)
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to update click counts in sql", logging.Int("count", len(counts)), logging.Err(err))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := make(map[string]int64, len(counts))
+	for rows.Next() {
+		var alias string
+		var total int64
+		if err = rows.Scan(&alias, &total); err != nil {
+			return nil, err
+		}
+		totals[alias] = total
+	}
+
+	return totals, classify(rows.Err())
 }
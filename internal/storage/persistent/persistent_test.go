@@ -1,13 +1,19 @@
 package persistent
 
 import (
+	"Darkyfun/UrlShortener/internal/clicks"
+	"Darkyfun/UrlShortener/internal/links"
 	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/rollup"
+	"Darkyfun/UrlShortener/internal/rules"
+	"Darkyfun/UrlShortener/internal/transfer"
 	"context"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/assert"
 	"log"
 
 	"io"
+	"strings"
 	"testing"
 	"time"
 )
@@ -146,7 +152,7 @@ func TestDb_GetAlias(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			res, err := db.GetAlias(ctx, tt.origUrl)
+			res, err := db.GetAlias(ctx, "", tt.origUrl)
 			assert.Equal(t, tt.err, err)
 			assert.Equal(t, tt.alias, res)
 		})
@@ -156,13 +162,257 @@ func TestDb_GetAlias(t *testing.T) {
 	defer cancel()
 	time.Sleep(time.Nanosecond * 100)
 
-	res, err := db.GetAlias(ctxExp, "testurl")
+	res, err := db.GetAlias(ctxExp, "", "testurl")
 	assert.Equal(t, ErrConnect, err)
 	assert.Equal(t, "", res)
 
 	db.Close()
 
-	res, err = db.GetAlias(ctx, "closed connection")
+	res, err = db.GetAlias(ctx, "", "closed connection")
 	assert.Equal(t, ErrConnClosed, err)
 	assert.Equal(t, "", res)
 }
+
+func TestDb_Rules(t *testing.T) {
+	db := NewDb(context.Background(), logging.NewLogger("json", io.Discard), TestBase)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+
+	// у псевдонима без правил возвращается пустой набор.
+	set, err := db.GetRules(ctx, "newone")
+	assert.Nil(t, err)
+	assert.Empty(t, set)
+
+	want, err := rules.Compile([]rules.Rule{{Platform: "ios", Destination: "https://apps.apple.com"}})
+	assert.Nil(t, err)
+
+	err = db.SetRules(ctx, "newone", want)
+	assert.Nil(t, err)
+
+	set, err = db.GetRules(ctx, "newone")
+	assert.Nil(t, err)
+	assert.Equal(t, want, set)
+
+	err = db.SetRules(ctx, "not_exist_alias", want)
+	assert.Equal(t, ErrNoRows, err)
+
+	_, err = db.GetRules(ctx, "not_exist_alias")
+	assert.Equal(t, ErrNoRows, err)
+}
+
+func TestDb_Create(t *testing.T) {
+	db := NewDb(context.Background(), logging.NewLogger("json", io.Discard), TestBase)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+
+	set, err := rules.Compile([]rules.Rule{{Platform: "ios", Destination: "https://apps.apple.com"}})
+	assert.Nil(t, err)
+	exp := time.Now().Add(time.Hour).UTC().Truncate(time.Millisecond)
+
+	err = db.Create(ctx, "created_whole", links.Draft{Original: "https://whole.example", Rules: set, ExpiresAt: exp,
+		Meta: links.Meta{Title: "Whole", Campaign: "spring"}})
+	assert.Nil(t, err)
+
+	saved, err := db.GetRules(ctx, "created_whole")
+	assert.Nil(t, err)
+	assert.Equal(t, set, saved)
+	savedExp, err := db.GetExpiry(ctx, "created_whole")
+	assert.Nil(t, err)
+	assert.True(t, exp.Equal(savedExp))
+
+	// занятый псевдоним не перезаписывается.
+	err = db.Create(ctx, "created_whole", links.Draft{Original: "https://other.example"})
+	assert.Equal(t, ErrAlreadyExists, err)
+
+	assert.Nil(t, db.Delete(ctx, "created_whole"))
+}
+
+func TestDb_ListLinks(t *testing.T) {
+	db := NewDb(context.Background(), logging.NewLogger("json", io.Discard), TestBase)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+
+	for _, alias := range []string{"list_a", "list_b", "list_c"} {
+		err := db.Set(ctx, alias, "https://list.example.com/"+alias)
+		assert.Nil(t, err)
+		err = db.SetMeta(ctx, alias, links.Meta{Title: alias, Tags: []string{"list"}, Owner: "lister"})
+		assert.Nil(t, err)
+	}
+
+	err := db.SetMeta(ctx, "not_exist_alias", links.Meta{Title: "none"})
+	assert.Equal(t, ErrNoRows, err)
+
+	f, err := links.Filter{Tag: "list", Sort: links.SortAlias, Order: links.OrderAsc, Limit: 2}.Normalize()
+	assert.Nil(t, err)
+
+	page, next, err := db.ListLinks(ctx, f)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(page))
+	assert.Equal(t, "list_a", page[0].Alias)
+	assert.Equal(t, "lister", page[0].Owner)
+	assert.NotNil(t, next)
+
+	f.Cursor = next
+	page, next, err = db.ListLinks(ctx, f)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(page))
+	assert.Equal(t, "list_c", page[0].Alias)
+	assert.Nil(t, next)
+
+	// поиск по подстроке оригинального URL не воспринимает '_' как шаблон.
+	f, _ = links.Filter{Query: "list.example.com/list_b"}.Normalize()
+	page, _, err = db.ListLinks(ctx, f)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(page))
+}
+
+func TestDb_ImportLinks(t *testing.T) {
+	db := NewDb(context.Background(), logging.NewLogger("json", io.Discard), TestBase)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+
+	file := "alias,original\nimported_a,https://a.com\nnewone,https://overwritten.com\n"
+
+	// псевдоним newone уже существует.
+	r, _ := transfer.NewReader(transfer.FormatCSV, strings.NewReader(file))
+	_, _, err := db.ImportLinks(ctx, r, transfer.PolicyFail)
+	assert.Equal(t, ErrAlreadyExists, err)
+
+	r, _ = transfer.NewReader(transfer.FormatCSV, strings.NewReader(file))
+	created, overwritten, err := db.ImportLinks(ctx, r, transfer.PolicySkip)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"imported_a"}, created)
+	assert.Empty(t, overwritten)
+
+	r, _ = transfer.NewReader(transfer.FormatCSV, strings.NewReader(file))
+	created, overwritten, err = db.ImportLinks(ctx, r, transfer.PolicyOverwrite)
+	assert.Nil(t, err)
+	assert.Empty(t, created)
+	assert.ElementsMatch(t, []string{"imported_a", "newone"}, overwritten)
+
+	exported := make(map[string]string)
+	err = db.ExportLinks(ctx, func(r transfer.Record) error {
+		exported[r.Alias] = r.Original
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "https://overwritten.com", exported["newone"])
+	assert.Equal(t, "https://a.com", exported["imported_a"])
+
+	// рабочее пространство, срок действия и кампания переносятся выгрузкой и загрузкой.
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	r, _ = transfer.NewReader(transfer.FormatCSV, strings.NewReader(
+		"alias,original,campaign,workspace_id,expires_at\nimported_b,https://b.com,spring,7,2030-01-01T00:00:00Z\n"))
+	_, _, err = db.ImportLinks(ctx, r, transfer.PolicyOverwrite)
+	assert.Nil(t, err)
+
+	var rec transfer.Record
+	err = db.ExportLinks(ctx, func(r transfer.Record) error {
+		if r.Alias == "imported_b" {
+			rec = r
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "spring", rec.Campaign)
+	assert.Equal(t, int64(7), rec.WorkspaceID)
+	assert.True(t, expires.Equal(*rec.ExpiresAt))
+	assert.Nil(t, db.Delete(ctx, "imported_b"))
+
+	// возвращаем исходное значение для остальных тестов.
+	r, _ = transfer.NewReader(transfer.FormatCSV, strings.NewReader("alias,original\nnewone,testurl\n"))
+	_, _, err = db.ImportLinks(ctx, r, transfer.PolicyOverwrite)
+	assert.Nil(t, err)
+}
+
+func TestDb_DeleteAndClickStats(t *testing.T) {
+	db := NewDb(context.Background(), logging.NewLogger("json", io.Discard), TestBase)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+
+	err := db.Set(ctx, "to_delete", "https://delete.me")
+	assert.Nil(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	for _, variant := range []string{"A", "A", "B"} {
+		err = db.SaveClick(ctx, clicks.Click{Alias: "to_delete", Variant: variant, Time: now})
+		assert.Nil(t, err)
+	}
+
+	stats, err := db.ClickStats(ctx, "to_delete")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), stats.Total)
+	assert.Equal(t, map[string]int64{"A": 2, "B": 1}, stats.Variants)
+
+	err = db.Delete(ctx, "to_delete")
+	assert.Nil(t, err)
+
+	err = db.Delete(ctx, "to_delete")
+	assert.Equal(t, ErrNoRows, err)
+
+	_, err = db.GetOriginal(ctx, "to_delete")
+	assert.Equal(t, ErrNoRows, err)
+}
+
+func TestDb_ClickRollups(t *testing.T) {
+	db := NewDb(context.Background(), logging.NewLogger("json", io.Discard), TestBase)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+
+	hour := time.Now().UTC().Truncate(time.Hour)
+
+	// пока транзакция с переходом не завершена, не сворачиваются и переходы, записанные после неё.
+	tx, err := db.pool.Begin(ctx)
+	assert.Nil(t, err)
+	_, err = tx.Exec(ctx, `insert into clicks (alias, user_agent, country, clicked_at) values ('camp_a', '', 'DE', $1)`, hour)
+	assert.Nil(t, err)
+
+	for alias, n := range map[string]int{"camp_a": 2, "camp_b": 1} {
+		assert.Nil(t, db.Set(ctx, alias, "https://campaign.example"))
+		assert.Nil(t, db.SetMeta(ctx, alias, links.Meta{Campaign: "spring"}))
+		for i := 0; i < n; i++ {
+			_, err = db.pool.Exec(ctx, `insert into clicks (alias, user_agent, country, clicked_at) values ($1, $2, 'DE', $3)`,
+				alias, "Mozilla/5.0 (iPhone; CPU iPhone OS 16_0 like Mac OS X)", hour)
+			assert.Nil(t, err)
+		}
+	}
+
+	_, err = db.RollupClicks(ctx, 1000, rollup.Aggregate)
+	assert.Nil(t, err)
+	h, err := db.ClickRollups(ctx, rollup.Target{Campaign: "spring"}, hour, hour.Add(time.Hour), 1)
+	assert.Nil(t, err)
+	assert.Empty(t, h.Series)
+
+	assert.Nil(t, tx.Rollback(ctx))
+	_, err = db.RollupClicks(ctx, 1000, rollup.Aggregate)
+	assert.Nil(t, err)
+
+	h, err = db.ClickRollups(ctx, rollup.Target{Campaign: "spring"}, hour, hour.Add(time.Hour), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), h.Links)
+	assert.Equal(t, []rollup.LinkCount{{Alias: "camp_a", Clicks: 2}}, h.Top)
+	assert.Equal(t, []rollup.Count{{Value: "DE", Clicks: 3}}, h.Breakdowns[rollup.DimCountry])
+
+	h, err = db.ClickRollups(ctx, rollup.Target{Alias: "camp_b"}, hour, hour.Add(time.Hour), 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), h.Series[0].Clicks)
+
+	_, err = db.ClickRollups(ctx, rollup.Target{Campaign: "autumn"}, hour, hour.Add(time.Hour), 1)
+	assert.Equal(t, ErrNoRows, err)
+
+	for _, alias := range []string{"camp_a", "camp_b"} {
+		assert.Nil(t, db.Delete(ctx, alias))
+	}
+}
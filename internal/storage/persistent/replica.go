@@ -0,0 +1,157 @@
+package persistent
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Opts - это опции подключения к SQL-базе данных: основной сервер, реплики только для чтения и настройки пулов.
+type Opts struct {
+	Primary  string
+	Replicas []string
+	Pool     PoolOpts
+}
+
+// PoolOpts - это настройки пула соединений pgxpool. Нулевые значения оставляют настройки pgxpool по умолчанию.
+type PoolOpts struct {
+	MaxConns          int32
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
+}
+
+// poolConfig разбирает строку подключения и применяет к ней настройки пула.
+func poolConfig(conn string, opts PoolOpts) (*pgxpool.Config, error) {
+	conf, err := pgxpool.ParseConfig(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.MaxConns > 0 {
+		conf.MaxConns = opts.MaxConns
+	}
+	if opts.MinConns > 0 {
+		conf.MinConns = opts.MinConns
+	}
+	if opts.MaxConnLifetime > 0 {
+		conf.MaxConnLifetime = opts.MaxConnLifetime
+	}
+	if opts.MaxConnIdleTime > 0 {
+		conf.MaxConnIdleTime = opts.MaxConnIdleTime
+	}
+	if opts.HealthCheckPeriod > 0 {
+		conf.HealthCheckPeriod = opts.HealthCheckPeriod
+	}
+	return conf, nil
+}
+
+// replica - это пул соединений с репликой и её состояние по результатам последней проверки.
+type replica struct {
+	name    string
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+// replicas - это набор реплик, между которыми по кругу распределяются запросы на чтение.
+type replicas struct {
+	list []*replica
+	next atomic.Uint64
+}
+
+// connectReplicas создаёт пулы для реплик. Недоступная при старте реплика не мешает запуску:
+// она помечается нездоровой, и чтение идёт с основного сервера, пока проверка не вернёт её в строй.
+func connectReplicas(ctx context.Context, logger Logger, conns []string, opts PoolOpts) (*replicas, error) {
+	rs := &replicas{}
+	for i, conn := range conns {
+		conf, err := poolConfig(conn, opts)
+		if err != nil {
+			return nil, fmt.Errorf("replica %d: %w", i, err)
+		}
+		pool, err := pgxpool.NewWithConfig(ctx, conf)
+		if err != nil {
+			return nil, fmt.Errorf("replica %d: %w", i, err)
+		}
+
+		r := &replica{name: "replica-" + strconv.Itoa(i), pool: pool}
+		if err = pool.Ping(ctx); err != nil {
+			logger.Log(ctx, "warn", "replica is unavailable at start", logging.String("replica", r.name), logging.Err(err))
+		} else {
+			r.healthy.Store(true)
+		}
+		rs.list = append(rs.list, r)
+	}
+	return rs, nil
+}
+
+// pick возвращает следующую здоровую реплику или nil, если таких нет.
+func (rs *replicas) pick() *replica {
+	if rs == nil || len(rs.list) == 0 {
+		return nil
+	}
+
+	start := rs.next.Add(1)
+	for i := 0; i < len(rs.list); i++ {
+		r := rs.list[(start+uint64(i))%uint64(len(rs.list))]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// check проверяет все реплики и обновляет их состояние, логируя каждое изменение.
+func (rs *replicas) check(ctx context.Context, logger Logger) {
+	if rs == nil {
+		return
+	}
+
+	for _, r := range rs.list {
+		err := r.pool.Ping(ctx)
+		healthy := err == nil
+		if r.healthy.Swap(healthy) == healthy {
+			continue
+		}
+		if healthy {
+			logger.Log(ctx, "info", "replica recovered", logging.String("replica", r.name))
+		} else {
+			logger.Log(ctx, "warn", "replica is unavailable", logging.String("replica", r.name), logging.Err(err))
+		}
+	}
+}
+
+// close закрывает пулы всех реплик.
+func (rs *replicas) close() {
+	if rs == nil {
+		return
+	}
+	for _, r := range rs.list {
+		r.pool.Close()
+	}
+}
+
+// readRow выполняет запрос на чтение одной строки на здоровой реплике.
+// Если реплика не нашла строку, запрос повторяется на основном сервере: реплика могла ещё не получить только что
+// созданную ссылку. Если реплика не ответила по причине, не связанной с данными, она к тому же помечается нездоровой.
+func (d *Db) readRow(ctx context.Context, dest any, query string, args ...any) error {
+	if r := d.replicas.pick(); r != nil {
+		err := r.pool.QueryRow(ctx, query, args...).Scan(dest)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+
+		if !errors.Is(err, pgx.ErrNoRows) {
+			r.healthy.Store(false)
+			d.log.Log(ctx, "warn", "replica failed, reading from primary", logging.String("replica", r.name), logging.Err(err))
+		}
+	}
+
+	return d.pool.QueryRow(ctx, query, args...).Scan(dest)
+}
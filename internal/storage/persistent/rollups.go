@@ -0,0 +1,168 @@
+package persistent
+
+import (
+	"Darkyfun/UrlShortener/internal/clicks"
+	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/rollup"
+	"Darkyfun/UrlShortener/internal/storage"
+	"Darkyfun/UrlShortener/internal/workspace"
+	"context"
+	"errors"
+	"time"
+)
+
+// rollupLock - это ключ рекомендательной блокировки, под которой переходы сворачивает только один экземпляр сервиса за раз.
+const rollupLock = 0x726f6c6c7570
+
+// maxBreakdown - сколько самых частых значений каждого измерения возвращает ClickRollups.
+const maxBreakdown = 20
+
+// RollupClicks передаёт в fn не более n самых старых ещё не свёрнутых переходов, прибавляет возвращённые fn строки
+// к таблице click_rollups и сдвигает позицию свёртки в той же транзакции. Пока переходы сворачивает другой экземпляр
+// сервиса, ничего не делает. Переходы упорядочиваются по транзакции, которая их записала, и сворачиваются, только когда
+// эта транзакция старше всех ещё незавершённых: идентификаторы переходов выдаются до фиксации, и переход с меньшим
+// идентификатором может появиться позже, а переход из завершённой транзакции - уже нет.
+func (d *Db) RollupClicks(ctx context.Context, n int, fn func([]clicks.Click) []rollup.Row) (int, error) {
+	tx, err := d.pool.Begin(ctx)
+	if err != nil {
+		return 0, classify(err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var locked bool
+	if err = tx.QueryRow(ctx, `select pg_try_advisory_xact_lock($1)`, rollupLock).Scan(&locked); err != nil || !locked {
+		return 0, classify(err)
+	}
+
+	rows, err := tx.Query(ctx, `select c.id, c.alias, coalesce(c.referrer, ''), coalesce(c.user_agent, ''), coalesce(c.country, ''),
+	coalesce(c.clicked_at, c.inserted_at at time zone 'UTC')
+	from clicks c, click_rollup_position p
+	where (c.xid, c.id) > (p.last_xid, p.last_click_id) and c.xid < pg_snapshot_xmin(pg_current_snapshot())
+	order by c.xid, c.id limit $1`, n)
+	if err != nil {
+		return 0, classify(err)
+	}
+
+	var cs []clicks.Click
+	var last int64
+	for rows.Next() {
+		var c clicks.Click
+		if err = rows.Scan(&last, &c.Alias, &c.Referrer, &c.UserAgent, &c.Country, &c.Time); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		cs = append(cs, c)
+	}
+	rows.Close()
+	if err = classify(rows.Err()); err != nil || len(cs) == 0 {
+		return 0, err
+	}
+
+	res := fn(cs)
+	aliases := make([]string, len(res))
+	buckets := make([]time.Time, len(res))
+	dimensions := make([]string, len(res))
+	values := make([]string, len(res))
+	counts := make([]int64, len(res))
+	for i, r := range res {
+		aliases[i], buckets[i], dimensions[i], values[i], counts[i] = r.Alias, r.Bucket, r.Dimension, r.Value, r.Clicks
+	}
+
+	_, err = tx.Exec(ctx, `insert into click_rollups (alias, bucket, dimension, value, clicks)
+	select * from unnest($1::varchar[], $2::timestamptz[], $3::varchar[], $4::varchar[], $5::bigint[])
+	on conflict (alias, dimension, bucket, value) do update set clicks = click_rollups.clicks + excluded.clicks`,
+		aliases, buckets, dimensions, values, counts)
+	if err != nil {
+		return 0, classify(err)
+	}
+
+	_, err = tx.Exec(ctx, `update click_rollup_position set (last_xid, last_click_id) = (select xid, id from clicks where id = $1)`, last)
+	if err != nil {
+		return 0, classify(err)
+	}
+	return len(cs), classify(tx.Commit(ctx))
+}
+
+// ClickRollups возвращает свёрнутые переходы по ссылке или кампании t рабочего пространства запроса за период [from, to):
+// почасовые числа, самые частые значения каждого измерения и, если top больше нуля, top ссылок с наибольшим числом переходов.
+// Ссылка или кампания без ссылок в рабочем пространстве запроса возвращает ErrNoRows.
+func (d *Db) ClickRollups(ctx context.Context, t rollup.Target, from, to time.Time, top int) (rollup.Hours, error) {
+	column, value := "alias", t.Alias
+	if t.Campaign != "" {
+		column, value = "campaign", t.Campaign
+	}
+	target := `from url where ` + column + ` = $1 and ($2::bigint is null or workspace_id = $2)`
+	rollups := `from click_rollups where alias in (select alias ` + target + `) and bucket >= $3 and bucket < $4`
+	ws := workspace.Scope(ctx)
+
+	h := rollup.Hours{Breakdowns: make(map[string][]rollup.Count)}
+	err := classify(d.pool.QueryRow(ctx, `select count(*), coalesce(sum(click_count), 0), coalesce(array_agg(alias), '{}') `+target,
+		value, ws).Scan(&h.Links, &h.Count, &h.Aliases))
+	if err == nil && h.Links == 0 {
+		return rollup.Hours{}, ErrNoRows
+	}
+
+	if err == nil {
+		err = d.scanCounts(ctx, func(scan func(dest ...any) error) error {
+			var b rollup.Bucket
+			if err := scan(&b.Start, &b.Clicks); err != nil {
+				return err
+			}
+			h.Series = append(h.Series, b)
+			return nil
+		}, `select bucket, sum(clicks) `+rollups+` and dimension = '' group by 1 order by 1`, value, ws, from, to)
+	}
+
+	if err == nil {
+		err = d.scanCounts(ctx, func(scan func(dest ...any) error) error {
+			var dim string
+			var c rollup.Count
+			if err := scan(&dim, &c.Value, &c.Clicks); err != nil {
+				return err
+			}
+			h.Breakdowns[dim] = append(h.Breakdowns[dim], c)
+			return nil
+		}, `select dimension, value, clicks from (
+			select dimension, value, sum(clicks) as clicks,
+			row_number() over (partition by dimension order by sum(clicks) desc, value) as n
+			`+rollups+` and dimension <> '' group by 1, 2
+		) as b where n <= $5 order by dimension, n`, value, ws, from, to, maxBreakdown)
+	}
+
+	if err == nil && top > 0 {
+		err = d.scanCounts(ctx, func(scan func(dest ...any) error) error {
+			var l rollup.LinkCount
+			if err := scan(&l.Alias, &l.Clicks); err != nil {
+				return err
+			}
+			h.Top = append(h.Top, l)
+			return nil
+		}, `select alias, sum(clicks) `+rollups+` and dimension = '' group by 1 order by 2 desc, 1 limit $5`, value, ws, from, to, top)
+	}
+
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to select click rollups in sql", logging.String(column, value), logging.Err(err))
+	}
+	if err != nil {
+		return rollup.Hours{}, err
+	}
+
+	return h, nil
+}
+
+// scanCounts выполняет запрос и передаёт каждую строку результата в row.
+func (d *Db) scanCounts(ctx context.Context, row func(scan func(dest ...any) error) error, query string, args ...any) error {
+	rows, err := d.pool.Query(ctx, query, args...)
+	if err != nil {
+		return classify(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err = row(rows.Scan); err != nil {
+			return err
+		}
+	}
+
+	return classify(rows.Err())
+}
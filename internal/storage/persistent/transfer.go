@@ -0,0 +1,187 @@
+package persistent
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/outbox"
+	"Darkyfun/UrlShortener/internal/storage"
+	"Darkyfun/UrlShortener/internal/transfer"
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"io"
+	"time"
+)
+
+// importColumns - это столбцы таблицы url, заполняемые при загрузке ссылок.
+var importColumns = []string{"alias", "original", "created_date", "title", "description", "tags", "owner", "rules", "split",
+	"campaign", "workspace_id", "expires_at"}
+
+// ExportLinks построчно выгружает таблицу url, передавая каждую ссылку в функцию fn.
+func (d *Db) ExportLinks(ctx context.Context, fn func(transfer.Record) error) error {
+	rows, err := d.pool.Query(ctx, `select alias, coalesce(original, ''), coalesce(created_date, 'epoch'), coalesce(title, ''),
+       coalesce(description, ''), coalesce(tags, '{}'), coalesce(owner, ''), rules, split,
+       coalesce(campaign, ''), workspace_id, expires_at from url order by alias`)
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to export links from sql", logging.Err(err))
+	}
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r transfer.Record
+		var rules, split []byte
+		err = rows.Scan(&r.Alias, &r.Original, &r.CreatedAt, &r.Title, &r.Description, &r.Tags, &r.Owner, &rules, &split,
+			&r.Campaign, &r.WorkspaceID, &r.ExpiresAt)
+		if err != nil {
+			return err
+		}
+		if r.CreatedAt.Equal(time.Unix(0, 0)) {
+			r.CreatedAt = time.Time{}
+		}
+		r.Rules, r.Split = rules, split
+
+		if err = fn(r); err != nil {
+			return err
+		}
+	}
+
+	return classify(rows.Err())
+}
+
+// ImportLinks загружает ссылки в таблицу url через COPY во временную таблицу.
+// Ссылки с уже существующими псевдонимами пропускаются, перезаписываются или прерывают загрузку согласно policy.
+// Загрузка выполняется в одной транзакции. Возвращает псевдонимы добавленных ссылок и псевдонимы перезаписанных ссылок,
+// записи которых нужно убрать из кэша.
+func (d *Db) ImportLinks(ctx context.Context, src transfer.Reader, policy string) (created, overwritten []string, err error) {
+	if err = transfer.ValidPolicy(policy); err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := d.pool.Begin(ctx)
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to import links in sql", logging.Err(err))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	_, err = tx.Exec(ctx, `create temp table url_import (like url including defaults) on commit drop`)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	source := &copySource{src: src, now: time.Now()}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"url_import"}, importColumns, source)
+	if err != nil {
+		if source.err != nil {
+			return nil, nil, source.err
+		}
+		return nil, nil, err
+	}
+
+	query := `with imported as (insert into url (alias, original, created_date, title, description, tags, owner, rules, split,
+	campaign, workspace_id, expires_at)
+	select alias, original, created_date, title, description, tags, owner, rules, split, campaign, workspace_id, expires_at
+	from url_import`
+	switch policy {
+	case transfer.PolicySkip:
+		query += ` on conflict (alias) do nothing`
+	case transfer.PolicyOverwrite:
+		query += ` on conflict (alias) do update set original = excluded.original, created_date = excluded.created_date,
+	title = excluded.title, description = excluded.description, tags = excluded.tags, owner = excluded.owner,
+	rules = excluded.rules, split = excluded.split, campaign = excluded.campaign, workspace_id = excluded.workspace_id,
+	expires_at = excluded.expires_at,
+	expiry_notified = url.expiry_notified and url.expires_at is not distinct from excluded.expires_at`
+	}
+
+	// xmax отличен от нуля у строк, которые были обновлены, а не вставлены.
+	// События в outbox записываются тем же запросом, то есть в той же транзакции.
+	query += ` returning alias, original, xmax <> 0 as updated
+	), events as (insert into outbox (type, alias, data)
+	select case when updated then $1::varchar else $2::varchar end, alias, jsonb_build_object('original', original) from imported)
+	select alias, updated from imported`
+
+	rows, err := tx.Query(ctx, query, outbox.LinkUpdated, outbox.LinkCreated)
+	if err = classify(err); err != nil {
+		return nil, nil, err
+	}
+
+	for rows.Next() {
+		var alias string
+		var updated bool
+		if err = rows.Scan(&alias, &updated); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		if updated {
+			overwritten = append(overwritten, alias)
+		} else {
+			created = append(created, alias)
+		}
+	}
+	rows.Close()
+
+	if err = classify(rows.Err()); err != nil {
+		return nil, nil, err
+	}
+
+	if err = classify(tx.Commit(ctx)); err != nil {
+		return nil, nil, err
+	}
+	return created, overwritten, nil
+}
+
+// copySource адаптирует transfer.Reader к интерфейсу pgx.CopyFromSource.
+type copySource struct {
+	src transfer.Reader
+	cur transfer.Record
+	now time.Time
+	err error
+}
+
+func (c *copySource) Next() bool {
+	r, err := c.src.Read()
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			c.err = err
+		}
+		return false
+	}
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = c.now
+	}
+	c.cur = r
+	return true
+}
+
+func (c *copySource) Values() ([]any, error) {
+	r := c.cur
+
+	var rules, split []byte
+	if len(r.Rules) > 0 {
+		rules = r.Rules
+	}
+	if len(r.Split) > 0 {
+		split = r.Split
+	}
+
+	return []any{r.Alias, r.Original, r.CreatedAt, nullable(r.Title), nullable(r.Description), r.Tags, nullable(r.Owner), rules, split,
+		nullable(r.Campaign), r.WorkspaceID, r.ExpiresAt}, nil
+}
+
+func (c *copySource) Err() error {
+	return c.err
+}
+
+// nullable возвращает nil для пустой строки, чтобы в базе данных сохранился NULL.
+func nullable(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,55 @@
+package persistent
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/storage"
+	"Darkyfun/UrlShortener/internal/warmup"
+	"context"
+	"errors"
+	"time"
+)
+
+// warmupQueries - это запросы выбора ссылок для прогрева кэша в каждом из порядков. Истёкшие ссылки не выбираются.
+var warmupQueries = map[string]string{
+	warmup.OrderRecent: `select alias, coalesce(original, ''), rules, split, expires_at from url
+	where expires_at is null or expires_at > now()
+	order by created_date desc nulls last limit $1`,
+	warmup.OrderClicks: `select alias, coalesce(original, ''), rules, split, expires_at from url
+	where expires_at is null or expires_at > now()
+	order by click_count desc limit $1`,
+}
+
+// WarmLinks выбирает не более limit ссылок в порядке order и построчно передаёт их в функцию fn.
+func (d *Db) WarmLinks(ctx context.Context, order string, limit int, fn func(warmup.Link) error) error {
+	query, ok := warmupQueries[order]
+	if !ok {
+		return warmup.ErrUnknownOrder
+	}
+
+	rows, err := d.pool.Query(ctx, query, limit)
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to select links for warm-up in sql", logging.Err(err))
+	}
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l warmup.Link
+		var exp *time.Time
+		if err = rows.Scan(&l.Alias, &l.Original, &l.Rules, &l.Split, &exp); err != nil {
+			return err
+		}
+		if exp != nil {
+			l.ExpiresAt = *exp
+		}
+
+		if err = fn(l); err != nil {
+			return err
+		}
+	}
+
+	return classify(rows.Err())
+}
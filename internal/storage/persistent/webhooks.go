@@ -0,0 +1,214 @@
+package persistent
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/storage"
+	"Darkyfun/UrlShortener/internal/webhooks"
+	"Darkyfun/UrlShortener/internal/workspace"
+	"context"
+	"errors"
+	"time"
+)
+
+// CreateHook сохраняет подписку на события в рабочем пространстве запроса и возвращает её с присвоенным идентификатором.
+// Подписка получает события только о ссылках своего рабочего пространства.
+func (d *Db) CreateHook(ctx context.Context, h webhooks.Hook) (webhooks.Hook, error) {
+	err := d.pool.QueryRow(ctx, `insert into webhooks (url, secret, events, threshold, workspace_id) values ($1, $2, $3, $4, $5)
+	returning id, created_at`, h.URL, h.Secret, h.Events, h.Threshold, workspace.Of(ctx)).Scan(&h.ID, &h.CreatedAt)
+
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to insert webhook in sql", logging.String("url", h.URL), logging.Err(err))
+	}
+
+	return h, err
+}
+
+// ListHooks возвращает подписки рабочего пространства запроса без их секретов.
+func (d *Db) ListHooks(ctx context.Context) ([]webhooks.Hook, error) {
+	rows, err := d.pool.Query(ctx, `select id, url, events, threshold, created_at from webhooks
+	where $1::bigint is null or workspace_id = $1 order by id`, workspace.Scope(ctx))
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to select webhooks in sql", logging.Err(err))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := make([]webhooks.Hook, 0)
+	for rows.Next() {
+		var h webhooks.Hook
+		if err = rows.Scan(&h.ID, &h.URL, &h.Events, &h.Threshold, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, h)
+	}
+
+	return hooks, classify(rows.Err())
+}
+
+// DeleteHook удаляет подписку вместе с её доставками.
+func (d *Db) DeleteHook(ctx context.Context, id int64) error {
+	tag, err := d.pool.Exec(ctx, `delete from webhooks where id = $1 and ($2::bigint is null or workspace_id = $2)`,
+		id, workspace.Scope(ctx))
+
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to delete webhook in sql", logging.Int("id", int(id)), logging.Err(err))
+	}
+	if err == nil && tag.RowsAffected() == 0 {
+		return ErrNoRows
+	}
+
+	return err
+}
+
+// HookDeliveries возвращает не более limit последних доставок подписки, включая перенесённые в таблицу недоставленных.
+func (d *Db) HookDeliveries(ctx context.Context, id int64, limit int) ([]webhooks.Delivery, error) {
+	var exists bool
+	err := classify(d.pool.QueryRow(ctx, `select exists (select 1 from webhooks
+	where id = $1 and ($2::bigint is null or workspace_id = $2))`, id, workspace.Scope(ctx)).Scan(&exists))
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to select webhook deliveries in sql", logging.Int("id", int(id)), logging.Err(err))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNoRows
+	}
+
+	rows, err := d.pool.Query(ctx, `select id, hook_id, event, status, attempts, last_status, last_error, created_at,
+       case when status = 'pending' then next_attempt_at end
+	from webhook_deliveries where hook_id = $1
+	union all
+	select id, hook_id, event, 'dead', attempts, last_status, last_error, created_at, null
+	from webhook_dead_letters where hook_id = $1
+	order by created_at desc, id desc limit $2`, id, limit)
+	if err = classify(err); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]webhooks.Delivery, 0)
+	for rows.Next() {
+		var del webhooks.Delivery
+		err = rows.Scan(&del.ID, &del.HookID, &del.Event, &del.Status, &del.Attempts, &del.LastStatus, &del.LastError,
+			&del.CreatedAt, &del.NextAttempt)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, del)
+	}
+
+	return deliveries, classify(rows.Err())
+}
+
+// EnqueueDeliveries ставит в очередь доставку события о ссылке alias подписчикам event из рабочего пространства ссылки.
+// Если ссылки уже нет в базе, событие получают подписчики рабочего пространства запроса, а без него - никто.
+func (d *Db) EnqueueDeliveries(ctx context.Context, event, alias string, payload []byte) error {
+	_, err := d.pool.Exec(ctx, `insert into webhook_deliveries (hook_id, event, payload)
+	select id, $1, $2 from webhooks
+	where $1 = any (events) and workspace_id = coalesce((select workspace_id from url where alias = $3), $4::bigint)`,
+		event, payload, alias, workspace.Scope(ctx))
+
+	return classify(err)
+}
+
+// EnqueueThresholds ставит в очередь webhooks.EventClickThreshold подписчикам, порог которых лежит
+// между прежним и итоговым счётчиком псевдонима. counts - прибавленные переходы, totals - итоговые счётчики.
+func (d *Db) EnqueueThresholds(ctx context.Context, counts, totals map[string]int64) error {
+	aliases := make([]string, 0, len(totals))
+	before := make([]int64, 0, len(totals))
+	after := make([]int64, 0, len(totals))
+	for alias, total := range totals {
+		aliases = append(aliases, alias)
+		before = append(before, total-counts[alias])
+		after = append(after, total)
+	}
+
+	_, err := d.pool.Exec(ctx, `insert into webhook_deliveries (hook_id, event, payload)
+	select h.id, $1, jsonb_build_object('type', $1::text, 'alias', c.alias, 'original', u.original,
+	    'clicks', c.after, 'threshold', h.threshold, 'time', now())
+	from unnest($2::varchar[], $3::bigint[], $4::bigint[]) as c(alias, before, after)
+	join url u on u.alias = c.alias
+	join webhooks h on $1 = any (h.events) and h.workspace_id = u.workspace_id
+	    and h.threshold > c.before and h.threshold <= c.after`,
+		webhooks.EventClickThreshold, aliases, before, after)
+
+	return classify(err)
+}
+
+// EnqueueExpired отмечает не более n истёкших ссылок, о которых ещё не сообщалось,
+// и ставит в очередь webhooks.EventLinkExpired их подписчикам. Возвращает число отмеченных ссылок.
+func (d *Db) EnqueueExpired(ctx context.Context, n int) (int, error) {
+	var count int
+	err := d.pool.QueryRow(ctx, `with expired as (
+	    update url set expiry_notified = true where alias in (
+	        select alias from url where expires_at <= now() and not expiry_notified limit $2 for update skip locked)
+	    returning alias, original, expires_at, workspace_id
+	), queued as (
+	    insert into webhook_deliveries (hook_id, event, payload)
+	    select h.id, $1, jsonb_build_object('type', $1::text, 'alias', e.alias, 'original', e.original,
+	        'expires_at', e.expires_at, 'time', now())
+	    from expired e join webhooks h on $1 = any (h.events) and h.workspace_id = e.workspace_id
+	)
+	select count(*) from expired`, webhooks.EventLinkExpired, n).Scan(&count)
+
+	return count, classify(err)
+}
+
+// DueDeliveries забирает не более n ожидающих доставок, время которых пришло, вместе с адресом и секретом подписки
+// и откладывает их на lease, чтобы другие экземпляры сервиса не взяли их одновременно.
+func (d *Db) DueDeliveries(ctx context.Context, n int, lease time.Duration) ([]webhooks.Delivery, error) {
+	rows, err := d.pool.Query(ctx, `update webhook_deliveries d set next_attempt_at = $2
+	from webhooks h
+	where d.hook_id = h.id and d.id in (
+	    select id from webhook_deliveries where status = 'pending' and next_attempt_at <= now()
+	    order by next_attempt_at limit $1 for update skip locked)
+	returning d.id, d.hook_id, d.event, d.payload, d.status, d.attempts, d.last_status, d.last_error, d.created_at,
+	    h.url, h.secret`, n, time.Now().Add(lease))
+	if err = classify(err); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []webhooks.Delivery
+	for rows.Next() {
+		var del webhooks.Delivery
+		err = rows.Scan(&del.ID, &del.HookID, &del.Event, &del.Payload, &del.Status, &del.Attempts, &del.LastStatus,
+			&del.LastError, &del.CreatedAt, &del.URL, &del.Secret)
+		if err != nil {
+			return nil, err
+		}
+		due = append(due, del)
+	}
+
+	return due, classify(rows.Err())
+}
+
+// SaveAttempt сохраняет результат попытки доставки.
+func (d *Db) SaveAttempt(ctx context.Context, del webhooks.Delivery) error {
+	next := time.Now()
+	if del.NextAttempt != nil {
+		next = *del.NextAttempt
+	}
+
+	_, err := d.pool.Exec(ctx, `update webhook_deliveries set status = $2, attempts = $3, last_status = $4, last_error = $5,
+	next_attempt_at = $6 where id = $1`, del.ID, del.Status, del.Attempts, del.LastStatus, del.LastError, next)
+
+	return classify(err)
+}
+
+// DeadLetter переносит доставку в таблицу недоставленных событий.
+func (d *Db) DeadLetter(ctx context.Context, del webhooks.Delivery) error {
+	_, err := d.pool.Exec(ctx, `with moved as (
+	    delete from webhook_deliveries where id = $1 returning id, hook_id, event, payload, created_at
+	)
+	insert into webhook_dead_letters (id, hook_id, event, payload, attempts, last_status, last_error, created_at)
+	select id, hook_id, event, payload, $2, $3, $4, created_at from moved`, del.ID, del.Attempts, del.LastStatus, del.LastError)
+
+	return classify(err)
+}
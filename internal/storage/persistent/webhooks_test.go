@@ -0,0 +1,58 @@
+package persistent
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/webhooks"
+	"Darkyfun/UrlShortener/internal/workspace"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestDb_HooksScoped(t *testing.T) {
+	db := NewDb(context.Background(), logging.NewLogger("json", io.Discard), TestBase)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+	wsA, wsB := workspace.WithID(ctx, 1001), workspace.WithID(ctx, 1002)
+
+	hook, err := db.CreateHook(wsA, webhooks.Hook{URL: "https://a.example/hook", Secret: "secret",
+		Events: []string{webhooks.EventLinkCreated}})
+	assert.Nil(t, err)
+
+	// рабочее пространство B не видит и не может удалить подписку A.
+	hooks, err := db.ListHooks(wsB)
+	assert.Nil(t, err)
+	assert.Empty(t, hooks)
+
+	_, err = db.HookDeliveries(wsB, hook.ID, 10)
+	assert.Equal(t, ErrNoRows, err)
+
+	err = db.DeleteHook(wsB, hook.ID)
+	assert.Equal(t, ErrNoRows, err)
+
+	hooks, err = db.ListHooks(wsA)
+	assert.Nil(t, err)
+	assert.Len(t, hooks, 1)
+
+	// событие о ссылке B не доставляется подписчику A.
+	assert.Nil(t, db.Set(wsB, "hook_b", "https://b.example"))
+	assert.Nil(t, db.EnqueueDeliveries(ctx, webhooks.EventLinkCreated, "hook_b", []byte(`{}`)))
+	deliveries, err := db.HookDeliveries(wsA, hook.ID, 10)
+	assert.Nil(t, err)
+	assert.Empty(t, deliveries)
+
+	assert.Nil(t, db.Set(wsA, "hook_a", "https://a.example"))
+	assert.Nil(t, db.EnqueueDeliveries(ctx, webhooks.EventLinkCreated, "hook_a", []byte(`{}`)))
+	deliveries, err = db.HookDeliveries(wsA, hook.ID, 10)
+	assert.Nil(t, err)
+	assert.Len(t, deliveries, 1)
+
+	assert.Nil(t, db.DeleteHook(wsA, hook.ID))
+	for _, alias := range []string{"hook_a", "hook_b"} {
+		assert.Nil(t, db.Delete(ctx, alias))
+	}
+}
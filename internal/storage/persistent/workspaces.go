@@ -0,0 +1,103 @@
+package persistent
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/storage"
+	"Darkyfun/UrlShortener/internal/workspace"
+	"context"
+	"errors"
+)
+
+// CreateWorkspace создаёт рабочее пространство и возвращает его с присвоенным идентификатором.
+// Рабочее пространство с тем же именем возвращает ErrAlreadyExists.
+func (d *Db) CreateWorkspace(ctx context.Context, w workspace.Workspace) (workspace.Workspace, error) {
+	err := d.pool.QueryRow(ctx, `insert into workspaces (name, max_links) values ($1, $2) returning id, created_at`,
+		w.Name, w.MaxLinks).Scan(&w.ID, &w.CreatedAt)
+
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to insert workspace in sql", logging.String("name", w.Name), logging.Err(err))
+	}
+
+	return w, err
+}
+
+// ListWorkspaces возвращает все рабочие пространства.
+func (d *Db) ListWorkspaces(ctx context.Context) ([]workspace.Workspace, error) {
+	rows, err := d.pool.Query(ctx, `select id, name, max_links, created_at from workspaces order by id`)
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to select workspaces in sql", logging.Err(err))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]workspace.Workspace, 0)
+	for rows.Next() {
+		var w workspace.Workspace
+		if err = rows.Scan(&w.ID, &w.Name, &w.MaxLinks, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, w)
+	}
+
+	return list, classify(rows.Err())
+}
+
+// AddMember добавляет участника в рабочее пространство вместе с его ключом API, от которого хранится только хэш.
+// Неизвестное рабочее пространство возвращает ErrNoRows, участник с тем же именем - ErrAlreadyExists.
+func (d *Db) AddMember(ctx context.Context, m workspace.Member, keyHash []byte) (workspace.Member, error) {
+	tx, err := d.pool.Begin(ctx)
+	if err != nil {
+		return m, classify(err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	err = tx.QueryRow(ctx, `insert into workspace_members (workspace_id, name, role)
+	select id, $2, $3 from workspaces where id = $1 returning created_at`, m.WorkspaceID, m.Name, m.Role).Scan(&m.CreatedAt)
+	if err == nil {
+		_, err = tx.Exec(ctx, `insert into api_keys (key_hash, workspace_id, member) values ($1, $2, $3)`,
+			keyHash, m.WorkspaceID, m.Name)
+	}
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to insert workspace member in sql", logging.Int("workspace", int(m.WorkspaceID)),
+			logging.String("name", m.Name), logging.Err(err))
+	}
+
+	return m, err
+}
+
+// WorkspaceByKey возвращает рабочее пространство, которому принадлежит ключ API с хэшем keyHash.
+// Неизвестный ключ возвращает ErrNoRows. Читает с основного сервера, чтобы только что выданный ключ сразу работал.
+func (d *Db) WorkspaceByKey(ctx context.Context, keyHash []byte) (int64, error) {
+	var id int64
+	err := d.pool.QueryRow(ctx, `select workspace_id from api_keys where key_hash = $1`, keyHash).Scan(&id)
+
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to select api key in sql", logging.Err(err))
+	}
+
+	return id, err
+}
+
+// LinkWorkspace возвращает рабочее пространство, которому принадлежит ссылка alias.
+// Неизвестная ссылка возвращает ErrNoRows.
+func (d *Db) LinkWorkspace(ctx context.Context, alias string) (int64, error) {
+	var id int64
+	err := d.pool.QueryRow(ctx, `select workspace_id from url where alias = $1`, alias).Scan(&id)
+
+	err = classify(err)
+	if errors.Is(err, storage.ErrUnavailable) {
+		d.log.Log(ctx, "error", "unable to select link workspace in sql", logging.String("alias", alias), logging.Err(err))
+	}
+
+	return id, err
+}
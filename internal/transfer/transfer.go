@@ -0,0 +1,279 @@
+// Package transfer содержит форматы выгрузки и загрузки ссылок (CSV и JSONL).
+package transfer
+
+import (
+	"Darkyfun/UrlShortener/internal/links"
+	"Darkyfun/UrlShortener/internal/rules"
+	"Darkyfun/UrlShortener/internal/split"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+var ErrUnknownFormat = errors.New("unknown format, should be csv or jsonl")
+var ErrUnknownPolicy = errors.New("unknown conflict policy, should be skip, overwrite or fail")
+var ErrMissingColumn = errors.New("csv header should contain alias and original columns")
+var ErrInvalidRecord = errors.New("record should have alias and original")
+
+// Поддерживаемые форматы файлов.
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// Политики разрешения конфликтов при загрузке ссылок с уже существующими псевдонимами.
+const (
+	PolicySkip      = "skip"
+	PolicyOverwrite = "overwrite"
+	PolicyFail      = "fail"
+)
+
+// columns - это столбцы CSV-файла в порядке выгрузки.
+var columns = []string{"alias", "original", "created_at", "title", "description", "tags", "owner", "rules", "split",
+	"campaign", "workspace_id", "expires_at"}
+
+// Record - это одна строка таблицы url.
+type Record struct {
+	links.Link
+	Rules json.RawMessage `json:"rules,omitempty"`
+	Split json.RawMessage `json:"split,omitempty"`
+	// WorkspaceID - рабочее пространство, которому принадлежит ссылка.
+	WorkspaceID int64 `json:"workspace_id,omitempty"`
+	// ExpiresAt - срок действия ссылки, nil означает бессрочную ссылку.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// ValidPolicy проверяет название политики разрешения конфликтов.
+func ValidPolicy(policy string) error {
+	switch policy {
+	case PolicySkip, PolicyOverwrite, PolicyFail:
+		return nil
+	}
+	return ErrUnknownPolicy
+}
+
+// Writer - это потоковая запись ссылок в файл.
+type Writer interface {
+	Write(r Record) error
+	Flush() error
+}
+
+// Reader - это потоковое чтение ссылок из файла. По окончании файла Read возвращает io.EOF.
+type Reader interface {
+	Read() (Record, error)
+}
+
+// NewWriter возвращает Writer для указанного формата.
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatJSONL:
+		buf := bufio.NewWriter(w)
+		return &jsonlWriter{buf: buf, enc: json.NewEncoder(buf)}, nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+// NewReader возвращает Reader для указанного формата.
+func NewReader(format string, r io.Reader) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		return &csvReader{r: csv.NewReader(r)}, nil
+	case FormatJSONL:
+		return &jsonlReader{dec: json.NewDecoder(r)}, nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+// csvWriter записывает ссылки в CSV с заголовком. Теги, правила и A/B-распределение записываются как JSON.
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (c *csvWriter) Write(r Record) error {
+	if !c.headerWritten {
+		if err := c.w.Write(columns); err != nil {
+			return err
+		}
+		c.headerWritten = true
+	}
+
+	tags := ""
+	if len(r.Tags) > 0 {
+		raw, _ := json.Marshal(r.Tags)
+		tags = string(raw)
+	}
+	created := ""
+	if !r.CreatedAt.IsZero() {
+		created = r.CreatedAt.Format(time.RFC3339Nano)
+	}
+	expires := ""
+	if r.ExpiresAt != nil {
+		expires = r.ExpiresAt.Format(time.RFC3339Nano)
+	}
+
+	return c.w.Write([]string{r.Alias, r.Original, created, r.Title, r.Description, tags, r.Owner, string(r.Rules), string(r.Split),
+		r.Campaign, strconv.FormatInt(r.WorkspaceID, 10), expires})
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// csvReader читает ссылки из CSV. Столбцы определяются по заголовку, обязательны только alias и original.
+type csvReader struct {
+	r     *csv.Reader
+	index map[string]int
+	line  int
+}
+
+func (c *csvReader) Read() (Record, error) {
+	if c.index == nil {
+		header, err := c.r.Read()
+		if err != nil {
+			return Record{}, err
+		}
+		c.index = make(map[string]int, len(header))
+		for i, name := range header {
+			c.index[name] = i
+		}
+		if _, ok := c.index["alias"]; !ok {
+			return Record{}, ErrMissingColumn
+		}
+		if _, ok := c.index["original"]; !ok {
+			return Record{}, ErrMissingColumn
+		}
+		c.line = 1
+	}
+
+	row, err := c.r.Read()
+	if err != nil {
+		return Record{}, err
+	}
+	c.line++
+
+	field := func(name string) string {
+		if i, ok := c.index[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	var r Record
+	r.Alias = field("alias")
+	r.Original = field("original")
+	r.Title = field("title")
+	r.Description = field("description")
+	r.Owner = field("owner")
+	r.Campaign = field("campaign")
+
+	if v := field("created_at"); v != "" {
+		if r.CreatedAt, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			return Record{}, fmt.Errorf("line %d: invalid created_at: %w", c.line, err)
+		}
+	}
+	if v := field("expires_at"); v != "" {
+		exp, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return Record{}, fmt.Errorf("line %d: invalid expires_at: %w", c.line, err)
+		}
+		r.ExpiresAt = &exp
+	}
+	if v := field("workspace_id"); v != "" {
+		if r.WorkspaceID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return Record{}, fmt.Errorf("line %d: invalid workspace_id: %w", c.line, err)
+		}
+	}
+	if v := field("tags"); v != "" {
+		if err = json.Unmarshal([]byte(v), &r.Tags); err != nil {
+			return Record{}, fmt.Errorf("line %d: invalid tags: %w", c.line, err)
+		}
+	}
+	if v := field("rules"); v != "" {
+		r.Rules = json.RawMessage(v)
+	}
+	if v := field("split"); v != "" {
+		r.Split = json.RawMessage(v)
+	}
+
+	if err = check(r); err != nil {
+		return Record{}, fmt.Errorf("line %d: %w", c.line, err)
+	}
+	return r, nil
+}
+
+// jsonlWriter записывает ссылки по одному JSON-объекту в строке.
+type jsonlWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func (j *jsonlWriter) Write(r Record) error {
+	return j.enc.Encode(r)
+}
+
+func (j *jsonlWriter) Flush() error {
+	return j.buf.Flush()
+}
+
+// jsonlReader читает ссылки по одному JSON-объекту в строке.
+type jsonlReader struct {
+	dec  *json.Decoder
+	line int
+}
+
+func (j *jsonlReader) Read() (Record, error) {
+	var r Record
+	if err := j.dec.Decode(&r); err != nil {
+		if errors.Is(err, io.EOF) {
+			return Record{}, io.EOF
+		}
+		return Record{}, fmt.Errorf("record %d: %w", j.line+1, err)
+	}
+	j.line++
+
+	if err := check(r); err != nil {
+		return Record{}, fmt.Errorf("record %d: %w", j.line, err)
+	}
+	return r, nil
+}
+
+// check проверяет, что у записи заполнены обязательные поля, ключ ссылки корректен, а оригинальный URL, правила,
+// A/B-распределение и метаданные проходят те же проверки, что и при создании ссылки через API, см. links.NewDraft.
+func check(r Record) error {
+	if r.Alias == "" || r.Original == "" {
+		return ErrInvalidRecord
+	}
+	if err := links.CheckKey(r.Alias); err != nil {
+		return err
+	}
+
+	var rr []rules.Rule
+	if len(r.Rules) > 0 {
+		if err := json.Unmarshal(r.Rules, &rr); err != nil {
+			return fmt.Errorf("%w: %w", links.ErrInvalidRules, err)
+		}
+	}
+
+	var ab *split.Split
+	if len(r.Split) > 0 {
+		if err := json.Unmarshal(r.Split, &ab); err != nil {
+			return fmt.Errorf("%w: %w", links.ErrInvalidSplit, err)
+		}
+		// ссылка без A/B-распределения выгружается с пустым распределением.
+		if ab != nil && ab.Empty() && !ab.Sticky {
+			ab = nil
+		}
+	}
+
+	_, err := links.NewDraft(r.Original, rr, ab, r.Meta)
+	return err
+}
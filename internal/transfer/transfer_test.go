@@ -0,0 +1,145 @@
+package transfer
+
+import (
+	"Darkyfun/UrlShortener/internal/links"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	expires := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+	records := []Record{
+		{
+			Link: links.Link{
+				Alias:     "abc",
+				Original:  "https://www.google.com",
+				CreatedAt: time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC),
+				Meta:      links.Meta{Title: "Search, \"quoted\"", Tags: []string{"promo", "a,b"}, Owner: "growth", Campaign: "spring"},
+			},
+			Rules:       json.RawMessage(`[{"platform":"ios","destination":"https://apps.apple.com"}]`),
+			WorkspaceID: 7,
+			ExpiresAt:   &expires,
+		},
+		{Link: links.Link{Alias: "def", Original: "https://example.com"}},
+	}
+
+	for _, format := range []string{FormatCSV, FormatJSONL} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(format, &buf)
+			assert.Nil(t, err)
+			for _, r := range records {
+				assert.Nil(t, w.Write(r))
+			}
+			assert.Nil(t, w.Flush())
+
+			r, err := NewReader(format, &buf)
+			assert.Nil(t, err)
+
+			var res []Record
+			for {
+				rec, err := r.Read()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				assert.Nil(t, err)
+				res = append(res, rec)
+			}
+
+			assert.Equal(t, len(records), len(res))
+			for i := range records {
+				assert.Equal(t, records[i].Alias, res[i].Alias)
+				assert.Equal(t, records[i].Original, res[i].Original)
+				assert.Equal(t, records[i].Title, res[i].Title)
+				assert.Equal(t, records[i].Tags, res[i].Tags)
+				assert.Equal(t, records[i].Campaign, res[i].Campaign)
+				assert.Equal(t, records[i].WorkspaceID, res[i].WorkspaceID)
+				assert.Equal(t, records[i].ExpiresAt, res[i].ExpiresAt)
+				assert.True(t, records[i].CreatedAt.Equal(res[i].CreatedAt))
+				assert.JSONEq(t, string(orNull(records[i].Rules)), string(orNull(res[i].Rules)))
+			}
+		})
+	}
+}
+
+func TestCSVReader(t *testing.T) {
+	// файл старого сокращателя: только обязательные столбцы в произвольном порядке.
+	r, _ := NewReader(FormatCSV, strings.NewReader("original,alias\nhttps://a.com,a\n"))
+	rec, err := r.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, "a", rec.Alias)
+	assert.Equal(t, "https://a.com", rec.Original)
+
+	r, _ = NewReader(FormatCSV, strings.NewReader("alias,url\na,https://a.com\n"))
+	_, err = r.Read()
+	assert.Equal(t, ErrMissingColumn, err)
+
+	r, _ = NewReader(FormatCSV, strings.NewReader("alias,original\n,https://a.com\n"))
+	_, err = r.Read()
+	assert.ErrorIs(t, err, ErrInvalidRecord)
+}
+
+func TestReader_InvalidRows(t *testing.T) {
+	tests := []struct {
+		name string
+		row  string
+		err  error
+	}{
+		{name: "zero weight", row: `{"alias":"a","original":"https://a.com","split":{"variants":[{"url":"https://b.com","weight":0}]}}`,
+			err: links.ErrInvalidSplit},
+		{name: "negative weight", row: `{"alias":"a","original":"https://a.com","split":{"variants":[{"url":"https://b.com","weight":-1}]}}`,
+			err: links.ErrInvalidSplit},
+		{name: "no variants", row: `{"alias":"a","original":"https://a.com","split":{"variants":[],"sticky":true}}`,
+			err: links.ErrInvalidSplit},
+		{name: "rule without condition", row: `{"alias":"a","original":"https://a.com","rules":[{"destination":"https://b.com"}]}`,
+			err: links.ErrInvalidRules},
+		{name: "rule with bad timezone", row: `{"alias":"a","original":"https://a.com","rules":[{"from":"09:00","to":"18:00","tz":"Mars/Base","destination":"https://b.com"}]}`,
+			err: links.ErrInvalidRules},
+		{name: "rules not a list", row: `{"alias":"a","original":"https://a.com","rules":{"destination":"https://b.com"}}`,
+			err: links.ErrInvalidRules},
+		{name: "slash alias", row: `{"alias":"/","original":"https://a.com"}`, err: links.ErrInvalidAlias},
+		{name: "blank alias", row: `{"alias":" ","original":"https://a.com"}`, err: links.ErrInvalidAlias},
+		{name: "invalid original", row: `{"alias":"a","original":"not a url"}`, err: links.ErrInvalidUrl},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := NewReader(FormatJSONL, strings.NewReader(`{"alias":"ok","original":"https://ok.com"}`+"\n"+tt.row+"\n"))
+			_, err := r.Read()
+			assert.Nil(t, err)
+
+			_, err = r.Read()
+			assert.ErrorIs(t, err, tt.err)
+			assert.True(t, strings.HasPrefix(err.Error(), "record 2: "), err.Error())
+		})
+	}
+
+	// в CSV ошибка указывает номер строки файла с учётом заголовка.
+	r, _ := NewReader(FormatCSV, strings.NewReader("alias,original,split\na,https://a.com,\"{\"\"variants\"\":[{\"\"url\"\":\"\"https://b.com\"\",\"\"weight\"\":0}]}\"\n"))
+	_, err := r.Read()
+	assert.ErrorIs(t, err, links.ErrInvalidSplit)
+	assert.True(t, strings.HasPrefix(err.Error(), "line 2: "), err.Error())
+}
+
+func TestFormatAndPolicy(t *testing.T) {
+	_, err := NewWriter("xml", io.Discard)
+	assert.Equal(t, ErrUnknownFormat, err)
+	_, err = NewReader("xml", strings.NewReader(""))
+	assert.Equal(t, ErrUnknownFormat, err)
+
+	assert.Nil(t, ValidPolicy(PolicySkip))
+	assert.Equal(t, ErrUnknownPolicy, ValidPolicy("merge"))
+}
+
+func orNull(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return json.RawMessage("null")
+	}
+	return raw
+}
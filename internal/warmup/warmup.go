@@ -0,0 +1,137 @@
+// Package warmup содержит предварительное заполнение кэша ссылками из SQL-базы данных при старте сервиса,
+// чтобы после переключения или очистки Redis запросы не шли в SQL-базу данных все разом.
+package warmup
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/storage/cache"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrUnknownOrder = errors.New("unknown warm-up order, should be recent or clicks")
+
+// Порядок выбора ссылок для прогрева.
+const (
+	// OrderRecent выбирает последние созданные ссылки.
+	OrderRecent = "recent"
+	// OrderClicks выбирает ссылки с наибольшим числом переходов.
+	OrderClicks = "clicks"
+)
+
+// Link - это ссылка со всеми данными, которые сервис держит в кэше.
+type Link struct {
+	Alias     string
+	Original  string
+	Rules     []byte
+	Split     []byte
+	ExpiresAt time.Time
+}
+
+// Source - это SQL-база данных, из которой выбираются ссылки для прогрева.
+type Source interface {
+	WarmLinks(ctx context.Context, order string, limit int, fn func(Link) error) error
+}
+
+// Cache - это кэш, в который ссылки записываются пакетами.
+type Cache interface {
+	SetMany(ctx context.Context, entries []cache.Entry) error
+}
+
+type Logger interface {
+	Log(ctx context.Context, level string, msg string, fields ...logging.Field)
+}
+
+// Options - это настройки прогрева.
+type Options struct {
+	// Order - OrderRecent или OrderClicks.
+	Order string
+	// Limit - сколько ссылок загрузить.
+	Limit int
+	// Batch - сколько ссылок записывать в кэш за один конвейер.
+	Batch int
+	// TTL определяет время жизни записей так же, как при обычном кэшировании.
+	TTL cache.TTLPolicy
+}
+
+// ValidOrder проверяет порядок выбора ссылок.
+func ValidOrder(order string) error {
+	switch order {
+	case OrderRecent, OrderClicks:
+		return nil
+	}
+	return ErrUnknownOrder
+}
+
+// Run загружает opts.Limit ссылок из src и пакетами записывает их в dst, логируя ход прогрева после каждого пакета.
+// Истёкшие ссылки пропускаются. Возвращает число записанных ссылок.
+func Run(ctx context.Context, opts Options, src Source, dst Cache, log Logger) (int, error) {
+	if err := ValidOrder(opts.Order); err != nil {
+		return 0, err
+	}
+	if opts.Batch <= 0 {
+		opts.Batch = 1
+	}
+
+	start := time.Now()
+	log.Log(ctx, "info", "cache warm-up started", logging.String("order", opts.Order), logging.Int("limit", opts.Limit))
+
+	var loaded int
+	batch := make([]cache.Entry, 0, opts.Batch*len(cache.Keys("")))
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := dst.SetMany(ctx, batch); err != nil {
+			return err
+		}
+		loaded += len(batch) / len(cache.Keys(""))
+		batch = batch[:0]
+		log.Log(ctx, "info", "cache warm-up progress", logging.Int("loaded", loaded), logging.Int("limit", opts.Limit))
+		return nil
+	}
+
+	err := src.WarmLinks(ctx, opts.Order, opts.Limit, func(l Link) error {
+		ttl := opts.TTL.TTL(0, l.ExpiresAt, time.Now())
+		if ttl == 0 {
+			return nil
+		}
+
+		batch = append(batch, Entries(l, ttl)...)
+		if len(batch) < opts.Batch*len(cache.Keys("")) {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		log.Log(ctx, "error", "cache warm-up failed", logging.Int("loaded", loaded), logging.Err(err))
+		return loaded, err
+	}
+
+	log.Log(ctx, "info", "cache warm-up finished", logging.Int("loaded", loaded), logging.Duration("took", time.Since(start)))
+	return loaded, nil
+}
+
+// Entries возвращает записи кэша для ссылки в том же виде, в каком их записывает service.Shortener.
+func Entries(l Link, ttl time.Duration) []cache.Entry {
+	exp, _ := json.Marshal(l.ExpiresAt)
+	return []cache.Entry{
+		{Key: l.Alias, Value: l.Original, TTL: ttl},
+		{Key: cache.RulesKey(l.Alias), Value: orNull(l.Rules), TTL: ttl},
+		{Key: cache.SplitKey(l.Alias), Value: orNull(l.Split), TTL: ttl},
+		{Key: cache.ExpiryKey(l.Alias), Value: exp, TTL: ttl},
+	}
+}
+
+// orNull возвращает JSON null вместо пустого значения столбца.
+func orNull(raw []byte) []byte {
+	if len(raw) == 0 {
+		return []byte("null")
+	}
+	return raw
+}
@@ -0,0 +1,92 @@
+package warmup
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"Darkyfun/UrlShortener/internal/storage/cache"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+// sliceSource отдаёт ссылки из среза.
+type sliceSource struct {
+	links []Link
+	order string
+	limit int
+}
+
+func (s *sliceSource) WarmLinks(_ context.Context, order string, limit int, fn func(Link) error) error {
+	s.order, s.limit = order, limit
+	for i, l := range s.links {
+		if i == limit {
+			break
+		}
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batchCache запоминает записанные пакеты.
+type batchCache struct {
+	batches [][]cache.Entry
+	err     error
+}
+
+func (b *batchCache) SetMany(_ context.Context, entries []cache.Entry) error {
+	if b.err != nil {
+		return b.err
+	}
+	b.batches = append(b.batches, append([]cache.Entry(nil), entries...))
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	now := time.Now()
+	src := &sliceSource{links: []Link{
+		{Alias: "a", Original: "https://a.com"},
+		{Alias: "b", Original: "https://b.com", Rules: []byte(`[]`)},
+		{Alias: "expired", Original: "https://old.com", ExpiresAt: now.Add(-time.Minute)},
+		{Alias: "c", Original: "https://c.com", ExpiresAt: now.Add(time.Minute)},
+		{Alias: "limited", Original: "https://d.com"},
+	}}
+	dst := &batchCache{}
+	opts := Options{Order: OrderRecent, Limit: 4, Batch: 2, TTL: cache.TTLPolicy{Base: time.Hour}}
+
+	loaded, err := Run(context.Background(), opts, src, dst, logging.NewLogger("json", io.Discard))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, loaded)
+	assert.Equal(t, OrderRecent, src.order)
+	assert.Equal(t, 4, src.limit)
+
+	// две ссылки в первом пакете, оставшаяся - во втором, истёкшая пропущена.
+	assert.Len(t, dst.batches, 2)
+	assert.Len(t, dst.batches[0], 8)
+	assert.Len(t, dst.batches[1], 4)
+
+	first := dst.batches[0]
+	assert.Equal(t, cache.Entry{Key: "a", Value: "https://a.com", TTL: time.Hour}, first[0])
+	assert.Equal(t, []byte("null"), first[1].Value)
+	assert.Equal(t, []byte(`[]`), first[5].Value)
+
+	// ссылка со сроком действия кэшируется не дольше него.
+	assert.Equal(t, "c", dst.batches[1][0].Key)
+	assert.LessOrEqual(t, dst.batches[1][0].TTL, time.Minute)
+}
+
+func TestRun_Errors(t *testing.T) {
+	logger := logging.NewLogger("json", io.Discard)
+	src := &sliceSource{links: []Link{{Alias: "a", Original: "https://a.com"}}}
+
+	_, err := Run(context.Background(), Options{Order: "popular"}, src, &batchCache{}, logger)
+	assert.ErrorIs(t, err, ErrUnknownOrder)
+
+	failed := errors.New("redis is down")
+	loaded, err := Run(context.Background(), Options{Order: OrderClicks, Limit: 1, Batch: 1, TTL: cache.DefaultTTLPolicy}, src, &batchCache{err: failed}, logger)
+	assert.ErrorIs(t, err, failed)
+	assert.Equal(t, 0, loaded)
+}
@@ -0,0 +1,246 @@
+package webhooks
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Store - это хранилище подписок и очереди доставок.
+type Store interface {
+	// EnqueueDeliveries ставит в очередь доставку события о ссылке alias подписчикам event из рабочего пространства ссылки.
+	EnqueueDeliveries(ctx context.Context, event, alias string, payload []byte) error
+	// EnqueueThresholds ставит в очередь EventClickThreshold подписчикам, порог которых пройден:
+	// counts - прибавленные переходы, totals - итоговые счётчики псевдонимов.
+	EnqueueThresholds(ctx context.Context, counts, totals map[string]int64) error
+	// EnqueueExpired ставит в очередь EventLinkExpired для не более чем n истёкших ссылок, о которых ещё не сообщалось.
+	EnqueueExpired(ctx context.Context, n int) (int, error)
+	// DueDeliveries забирает не более n доставок, время которых пришло, и откладывает их на lease,
+	// чтобы другие экземпляры сервиса не взяли их одновременно.
+	DueDeliveries(ctx context.Context, n int, lease time.Duration) ([]Delivery, error)
+	// SaveAttempt сохраняет результат попытки доставки.
+	SaveAttempt(ctx context.Context, d Delivery) error
+	// DeadLetter переносит доставку, исчерпавшую попытки, в таблицу недоставленных событий.
+	DeadLetter(ctx context.Context, d Delivery) error
+}
+
+type Logger interface {
+	Log(ctx context.Context, level string, msg string, fields ...logging.Field)
+}
+
+// Options - это настройки доставки событий.
+type Options struct {
+	// Interval - как часто проверяется очередь доставок и истёкшие ссылки.
+	Interval time.Duration
+	// Batch - сколько доставок забирается за раз.
+	Batch int
+	// MaxAttempts - после стольких неудачных попыток доставка переносится в таблицу недоставленных.
+	MaxAttempts int
+	// Backoff - пауза после первой неудачной попытки, каждая следующая вдвое длиннее, но не больше MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Timeout ограничивает один запрос к подписчику.
+	Timeout time.Duration
+	// AllowHTTP разрешает подписки на http-адреса, иначе принимаются только https.
+	AllowHTTP bool
+	// AllowPrivate разрешает доставку на локальные и частные адреса, иначе соединение с ними отклоняется.
+	AllowPrivate bool
+}
+
+// Dispatcher - это структура, которая ставит события в очередь доставок и доставляет их в фоне.
+// Очередь хранится в SQL-базе данных, поэтому события не теряются при перезапуске.
+type Dispatcher struct {
+	store  Store
+	client *http.Client
+	log    Logger
+	opts   Options
+}
+
+// NewDispatcher возвращает Dispatcher с указанными настройками.
+func NewDispatcher(store Store, log Logger, opts Options) *Dispatcher {
+	return &Dispatcher{
+		store:  store,
+		client: newClient(opts),
+		log:    log,
+		opts:   opts,
+	}
+}
+
+// Publish ставит событие в очередь доставок всем его подписчикам. Ошибки логируются и не возвращаются,
+// чтобы недоступность очереди не мешала работе со ссылками.
+func (d *Dispatcher) Publish(ctx context.Context, ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	payload, _ := json.Marshal(ev)
+
+	if err := d.store.EnqueueDeliveries(ctx, ev.Type, ev.Alias, payload); err != nil {
+		d.log.Log(ctx, "error", "unable to enqueue webhook event", logging.String("event", ev.Type),
+			logging.String("alias", ev.Alias), logging.Err(err))
+	}
+}
+
+// ClicksCounted ставит в очередь события о пройденных порогах переходов.
+// counts - прибавленные переходы, totals - итоговые счётчики псевдонимов.
+func (d *Dispatcher) ClicksCounted(ctx context.Context, counts, totals map[string]int64) {
+	if err := d.store.EnqueueThresholds(ctx, counts, totals); err != nil {
+		d.log.Log(ctx, "error", "unable to enqueue click threshold events", logging.Int("count", len(counts)), logging.Err(err))
+	}
+}
+
+// Run раз в интервал ставит в очередь события об истёкших ссылках и доставляет события, пока не отменён ctx.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := d.store.EnqueueExpired(ctx, d.opts.Batch); err != nil && ctx.Err() == nil {
+				d.log.Log(ctx, "error", "unable to enqueue expired link events", logging.Err(err))
+			}
+			d.Dispatch(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Dispatch доставляет все события, время которых пришло.
+func (d *Dispatcher) Dispatch(ctx context.Context) {
+	for ctx.Err() == nil {
+		due, err := d.store.DueDeliveries(ctx, d.opts.Batch, 2*d.opts.Timeout)
+		if err != nil {
+			if ctx.Err() == nil {
+				d.log.Log(ctx, "error", "unable to select webhook deliveries", logging.Err(err))
+			}
+			return
+		}
+
+		for _, del := range due {
+			d.deliver(ctx, del)
+		}
+		if len(due) < d.opts.Batch {
+			return
+		}
+	}
+}
+
+// deliver выполняет одну попытку доставки и сохраняет её результат.
+func (d *Dispatcher) deliver(ctx context.Context, del Delivery) {
+	code, err := d.send(ctx, del)
+	if ctx.Err() != nil {
+		// попытка прервана остановкой сервиса и не засчитывается, доставка повторится после аренды.
+		return
+	}
+
+	del.Attempts++
+	del.LastStatus = code
+	del.LastError = ""
+	switch {
+	case err == nil:
+		del.Status = StatusDelivered
+	case del.Attempts >= d.opts.MaxAttempts:
+		del.Status = StatusDead
+		del.LastError = err.Error()
+	default:
+		del.Status = StatusPending
+		del.LastError = err.Error()
+		next := time.Now().Add(Backoff(d.opts.Backoff, d.opts.MaxBackoff, del.Attempts))
+		del.NextAttempt = &next
+	}
+
+	if del.Status == StatusDead {
+		d.log.Log(ctx, "warn", "webhook delivery failed, moved to dead letters", logging.Int("delivery", int(del.ID)),
+			logging.String("url", del.URL), logging.Err(err))
+		err = d.store.DeadLetter(ctx, del)
+	} else {
+		err = d.store.SaveAttempt(ctx, del)
+	}
+	if err != nil {
+		d.log.Log(ctx, "error", "unable to save webhook delivery", logging.Int("delivery", int(del.ID)), logging.Err(err))
+	}
+}
+
+// send отправляет событие подписчику и возвращает код ответа. Успехом считается любой ответ 2xx.
+func (d *Dispatcher) send(ctx context.Context, del Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, del.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, del.Event)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(del.ID, 10))
+	req.Header.Set(SignatureHeader, Sign(del.Secret, del.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Backoff возвращает паузу после attempt неудачных попыток: base, 2*base, 4*base и так далее, но не больше max.
+func Backoff(base, max time.Duration, attempt int) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
+
+// newClient возвращает HTTP-клиент для доставок. Без AllowPrivate адрес проверяется при установке соединения,
+// уже после разрешения имени, поэтому подписчик не направит запрос во внутреннюю сеть ни адресом, ни DNS-записью,
+// ни перенаправлением. Перенаправления не выполняются, прокси из окружения не используется.
+func newClient(opts Options) *http.Client {
+	dialer := &net.Dialer{Timeout: opts.Timeout, KeepAlive: 30 * time.Second}
+	if !opts.AllowPrivate {
+		dialer.Control = publicOnly
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// sharedAddressSpace - это диапазон 100.64.0.0/10 для NAT операторов связи (RFC 6598), net.IP.IsPrivate его не включает.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// publicOnly отклоняет соединения с локальными, частными, CGNAT, link-local и служебными адресами.
+func publicOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// Package webhooks содержит подписки клиентов API на события жизненного цикла ссылок
+// и доставку этих событий подписанными HTTP-запросами.
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/asaskevich/govalidator"
+	"net/url"
+	"time"
+)
+
+var ErrInvalidHook = errors.New("invalid webhook")
+var ErrForbiddenAddress = errors.New("webhook address is not public")
+
+// События, на которые можно подписаться.
+const (
+	EventLinkCreated    = "link.created"
+	EventLinkUpdated    = "link.updated"
+	EventLinkDeleted    = "link.deleted"
+	EventLinkExpired    = "link.expired"
+	EventClickThreshold = "link.click_threshold"
+)
+
+// Events - это все события, на которые можно подписаться.
+var Events = []string{EventLinkCreated, EventLinkUpdated, EventLinkDeleted, EventLinkExpired, EventClickThreshold}
+
+// Заголовки запроса доставки.
+const (
+	// SignatureHeader содержит "sha256=" и HMAC-SHA256 тела запроса в hex, ключ - секрет подписки.
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// Состояния доставки.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead"
+)
+
+// Hook - это подписка на события.
+type Hook struct {
+	ID     int64    `json:"id"`
+	URL    string   `json:"url"`
+	Secret string   `json:"secret,omitempty"`
+	Events []string `json:"events"`
+	// Threshold - число переходов, при достижении которого отправляется EventClickThreshold.
+	Threshold int64     `json:"threshold,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Validate проверяет подписку. Адрес подписчика должен быть https, http допускается только с allowHTTP.
+func (h Hook) Validate(allowHTTP bool) error {
+	if !govalidator.IsURL(h.URL) {
+		return fmt.Errorf("%w: url must be a valid url", ErrInvalidHook)
+	}
+	u, err := url.Parse(h.URL)
+	if err != nil || !(u.Scheme == "https" || allowHTTP && u.Scheme == "http") {
+		return fmt.Errorf("%w: url must be an https url", ErrInvalidHook)
+	}
+	if len(h.Events) == 0 {
+		return fmt.Errorf("%w: at least one event is required", ErrInvalidHook)
+	}
+	for _, ev := range h.Events {
+		if !known(ev) {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidHook, ev)
+		}
+		if ev == EventClickThreshold && h.Threshold <= 0 {
+			return fmt.Errorf("%w: threshold must be positive for %s", ErrInvalidHook, EventClickThreshold)
+		}
+	}
+	return nil
+}
+
+// known сообщает, что на событие можно подписаться.
+func known(event string) bool {
+	for _, ev := range Events {
+		if ev == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Event - это событие, отправляемое подписчикам в теле запроса.
+type Event struct {
+	Type      string     `json:"type"`
+	Alias     string     `json:"alias"`
+	Original  string     `json:"original,omitempty"`
+	Clicks    int64      `json:"clicks,omitempty"`
+	Threshold int64      `json:"threshold,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Time      time.Time  `json:"time"`
+}
+
+// Delivery - это доставка события одному подписчику.
+type Delivery struct {
+	ID         int64     `json:"id"`
+	HookID     int64     `json:"hook_id"`
+	Event      string    `json:"event"`
+	Payload    []byte    `json:"-"`
+	Status     string    `json:"status"`
+	Attempts   int       `json:"attempts"`
+	LastStatus int       `json:"last_status,omitempty"`
+	LastError  string    `json:"last_error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	// NextAttempt - время следующей попытки, задано только у ожидающих доставок.
+	NextAttempt *time.Time `json:"next_attempt_at,omitempty"`
+
+	// URL и Secret - это адрес и секрет подписки на момент доставки.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// Publisher - это получатель событий жизненного цикла ссылок.
+type Publisher interface {
+	Publish(ctx context.Context, ev Event)
+}
+
+// Discard - это получатель событий, который их не отправляет. Используется, когда вебхуки не нужны.
+type Discard struct{}
+
+func (Discard) Publish(context.Context, Event) {}
+
+// Sign возвращает значение заголовка SignatureHeader для тела body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSecret возвращает случайный секрет подписки.
+func NewSecret() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,223 @@
+package webhooks
+
+import (
+	"Darkyfun/UrlShortener/internal/logging"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHook_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		hook      Hook
+		allowHTTP bool
+		ok        bool
+	}{
+		{name: "valid", hook: Hook{URL: "https://crm.example.com/hook", Events: []string{EventLinkCreated}}, ok: true},
+		{name: "threshold", hook: Hook{URL: "https://crm.example.com/hook", Events: []string{EventClickThreshold}, Threshold: 1}, ok: true},
+		{name: "invalid url", hook: Hook{URL: "crm", Events: []string{EventLinkCreated}}},
+		{name: "http", hook: Hook{URL: "http://crm.example.com/hook", Events: []string{EventLinkCreated}}},
+		{name: "allowed http", hook: Hook{URL: "http://crm.example.com/hook", Events: []string{EventLinkCreated}}, allowHTTP: true, ok: true},
+		{name: "other scheme", hook: Hook{URL: "ftp://crm.example.com/hook", Events: []string{EventLinkCreated}}, allowHTTP: true},
+		{name: "no events", hook: Hook{URL: "https://crm.example.com/hook"}},
+		{name: "unknown event", hook: Hook{URL: "https://crm.example.com/hook", Events: []string{"link.visited"}}},
+		{name: "missing threshold", hook: Hook{URL: "https://crm.example.com/hook", Events: []string{EventClickThreshold}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.hook.Validate(tt.allowHTTP)
+			if tt.ok {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidHook)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		8:  30 * time.Minute,
+		60: 30 * time.Minute,
+	}
+	for attempt, want := range tests {
+		assert.Equal(t, want, Backoff(30*time.Second, 30*time.Minute, attempt))
+	}
+}
+
+// memoryQueue - это очередь доставок в памяти с одной подпиской.
+type memoryQueue struct {
+	hook       Hook
+	deliveries []Delivery
+	dead       []Delivery
+}
+
+func (m *memoryQueue) EnqueueDeliveries(_ context.Context, event, _ string, payload []byte) error {
+	for _, ev := range m.hook.Events {
+		if ev == event {
+			m.deliveries = append(m.deliveries, Delivery{ID: int64(len(m.deliveries) + 1), HookID: m.hook.ID,
+				Event: event, Payload: payload, Status: StatusPending})
+		}
+	}
+	return nil
+}
+
+func (m *memoryQueue) EnqueueThresholds(context.Context, map[string]int64, map[string]int64) error {
+	return nil
+}
+
+func (m *memoryQueue) EnqueueExpired(context.Context, int) (int, error) {
+	return 0, nil
+}
+
+// DueDeliveries отдаёт все ожидающие доставки без учёта времени следующей попытки.
+func (m *memoryQueue) DueDeliveries(_ context.Context, n int, _ time.Duration) ([]Delivery, error) {
+	var due []Delivery
+	for _, d := range m.deliveries {
+		if d.Status == StatusPending && len(due) < n {
+			d.URL, d.Secret = m.hook.URL, m.hook.Secret
+			due = append(due, d)
+		}
+	}
+	return due, nil
+}
+
+func (m *memoryQueue) SaveAttempt(_ context.Context, d Delivery) error {
+	for i := range m.deliveries {
+		if m.deliveries[i].ID == d.ID {
+			m.deliveries[i] = d
+		}
+	}
+	return nil
+}
+
+func (m *memoryQueue) DeadLetter(_ context.Context, d Delivery) error {
+	for i := range m.deliveries {
+		if m.deliveries[i].ID == d.ID {
+			m.deliveries = append(m.deliveries[:i], m.deliveries[i+1:]...)
+			break
+		}
+	}
+	m.dead = append(m.dead, d)
+	return nil
+}
+
+func TestDispatcher(t *testing.T) {
+	var fail atomic.Bool
+	var received atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, Sign("secret", body), r.Header.Get(SignatureHeader))
+		assert.Equal(t, EventLinkCreated, r.Header.Get(EventHeader))
+		assert.NotEmpty(t, r.Header.Get(DeliveryHeader))
+
+		var ev Event
+		assert.Nil(t, json.Unmarshal(body, &ev))
+		assert.Equal(t, "promo", ev.Alias)
+
+		received.Add(1)
+		if fail.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	queue := &memoryQueue{hook: Hook{ID: 1, URL: srv.URL, Secret: "secret", Events: []string{EventLinkCreated}}}
+	d := NewDispatcher(queue, logging.NewLogger("json", io.Discard), Options{
+		Interval: time.Hour, Batch: 10, MaxAttempts: 2, Backoff: time.Second, MaxBackoff: time.Minute, Timeout: time.Second,
+		AllowPrivate: true,
+	})
+	ctx := context.Background()
+
+	// событие без подписчиков не ставится в очередь.
+	d.Publish(ctx, Event{Type: EventLinkDeleted, Alias: "promo"})
+	assert.Empty(t, queue.deliveries)
+
+	d.Publish(ctx, Event{Type: EventLinkCreated, Alias: "promo", Original: "https://example.com"})
+	d.Dispatch(ctx)
+	assert.Equal(t, int32(1), received.Load())
+	assert.Equal(t, StatusDelivered, queue.deliveries[0].Status)
+	assert.Equal(t, http.StatusOK, queue.deliveries[0].LastStatus)
+
+	// неудачная доставка повторяется, а после MaxAttempts попыток переносится в недоставленные.
+	fail.Store(true)
+	d.Publish(ctx, Event{Type: EventLinkCreated, Alias: "promo"})
+	d.Dispatch(ctx)
+	assert.Equal(t, StatusPending, queue.deliveries[1].Status)
+	assert.Equal(t, 1, queue.deliveries[1].Attempts)
+	assert.Equal(t, http.StatusBadGateway, queue.deliveries[1].LastStatus)
+	assert.NotNil(t, queue.deliveries[1].NextAttempt)
+
+	d.Dispatch(ctx)
+	assert.Len(t, queue.deliveries, 1)
+	assert.Len(t, queue.dead, 1)
+	assert.Equal(t, 2, queue.dead[0].Attempts)
+	assert.Equal(t, int32(3), received.Load())
+}
+
+func TestDispatcher_PrivateAddress(t *testing.T) {
+	var received atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { received.Add(1) }))
+	defer srv.Close()
+
+	// адрес проверяется после разрешения имени, поэтому localhost отклоняется так же, как 127.0.0.1.
+	for _, u := range []string{srv.URL, strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)} {
+		queue := &memoryQueue{hook: Hook{ID: 1, URL: u, Secret: "secret", Events: []string{EventLinkCreated}}}
+		d := NewDispatcher(queue, logging.NewLogger("json", io.Discard), Options{
+			Interval: time.Hour, Batch: 10, MaxAttempts: 2, Backoff: time.Second, MaxBackoff: time.Minute, Timeout: time.Second,
+		})
+
+		d.Publish(context.Background(), Event{Type: EventLinkCreated, Alias: "promo"})
+		d.Dispatch(context.Background())
+		assert.Equal(t, StatusPending, queue.deliveries[0].Status)
+		assert.Contains(t, queue.deliveries[0].LastError, ErrForbiddenAddress.Error())
+	}
+	assert.Equal(t, int32(0), received.Load())
+}
+
+func TestPublicOnly(t *testing.T) {
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{address: "93.184.216.34:443", allowed: true},
+		{address: "[2606:2800:220:1:248:1893:25c8:1946]:443", allowed: true},
+		{address: "100.63.255.255:443", allowed: true},
+		{address: "100.128.0.0:443", allowed: true},
+		{address: "127.0.0.1:443"},
+		{address: "10.0.0.1:443"},
+		{address: "172.16.0.1:443"},
+		{address: "192.168.1.1:443"},
+		{address: "100.64.0.1:443"},
+		{address: "100.127.255.254:443"},
+		{address: "169.254.169.254:80"},
+		{address: "0.0.0.0:443"},
+		{address: "[::1]:443"},
+		{address: "[fd00::1]:443"},
+		{address: "[fe80::1]:443"},
+		{address: "[::ffff:100.64.0.1]:443"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := publicOnly("tcp", tt.address, nil)
+			if tt.allowed {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrForbiddenAddress)
+			}
+		})
+	}
+}
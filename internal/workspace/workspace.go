@@ -0,0 +1,119 @@
+// Package workspace содержит рабочие пространства (арендаторов), которым принадлежат ссылки, собственные домены
+// и ключи API, и передачу рабочего пространства запроса через context.Context.
+package workspace
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidWorkspace = errors.New("invalid workspace")
+var ErrInvalidMember = errors.New("invalid member")
+var ErrQuotaExceeded = errors.New("workspace link quota exceeded")
+var ErrUnauthorized = errors.New("missing or unknown api key")
+
+// Default - это рабочее пространство ссылок, созданных без ключа API, в том числе до появления рабочих пространств.
+const Default int64 = 0
+
+// Роли участников рабочего пространства.
+const (
+	RoleOwner  = "owner"
+	RoleMember = "member"
+)
+
+// Ограничения на имена.
+const (
+	maxNameLen = 64
+)
+
+// keyPrefix - это префикс ключей API, по которому их легко узнать в конфигурации и логах.
+const keyPrefix = "sk_"
+
+// Workspace - это рабочее пространство.
+type Workspace struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// MaxLinks - сколько ссылок можно создать в рабочем пространстве, 0 означает без ограничений.
+	MaxLinks  int64     `json:"max_links"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Validate проверяет имя и квоту рабочего пространства.
+func (w Workspace) Validate() error {
+	if w.Name == "" || len(w.Name) > maxNameLen {
+		return fmt.Errorf("%w: name should be 1 to %d characters", ErrInvalidWorkspace, maxNameLen)
+	}
+	if w.MaxLinks < 0 {
+		return fmt.Errorf("%w: max_links should not be negative", ErrInvalidWorkspace)
+	}
+	return nil
+}
+
+// Member - это участник рабочего пространства. Key - ключ API участника, он есть только в ответе на добавление.
+type Member struct {
+	WorkspaceID int64     `json:"workspace_id"`
+	Name        string    `json:"name"`
+	Role        string    `json:"role"`
+	Key         string    `json:"key,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Validate проверяет имя и роль участника. Пустая роль означает RoleMember.
+func (m Member) Validate() error {
+	if m.Name == "" || len(m.Name) > maxNameLen {
+		return fmt.Errorf("%w: name should be 1 to %d characters", ErrInvalidMember, maxNameLen)
+	}
+	switch m.Role {
+	case "", RoleOwner, RoleMember:
+	default:
+		return fmt.Errorf("%w: role should be %s or %s", ErrInvalidMember, RoleOwner, RoleMember)
+	}
+	return nil
+}
+
+// NewKey возвращает новый случайный ключ API.
+func NewKey() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return keyPrefix + hex.EncodeToString(b)
+}
+
+// HashKey возвращает хэш ключа API, под которым он хранится в базе данных. Сами ключи не хранятся.
+func HashKey(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:]
+}
+
+type ctxKey struct{}
+
+// WithID возвращает контекст запроса, ограниченного рабочим пространством id.
+func WithID(ctx context.Context, id int64) context.Context {
+	return context.WithValue(ctx, ctxKey{}, &id)
+}
+
+// Unscoped возвращает контекст, не ограниченный рабочим пространством, даже если ctx был ограничен.
+// Используется для служебных запросов, которым нужны данные всех рабочих пространств.
+func Unscoped(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxKey{}, (*int64)(nil))
+}
+
+// Scope возвращает рабочее пространство, которым ограничен запрос, или nil, если запрос не ограничен.
+// Запросы без рабочего пространства - перенаправления, фоновые задачи и команды администратора - видят все ссылки.
+func Scope(ctx context.Context) *int64 {
+	id, _ := ctx.Value(ctxKey{}).(*int64)
+	return id
+}
+
+// Of возвращает рабочее пространство, которому принадлежат данные, создаваемые запросом: рабочее пространство
+// запроса или Default, если запрос не ограничен.
+func Of(ctx context.Context) int64 {
+	if id := Scope(ctx); id != nil {
+		return *id
+	}
+	return Default
+}
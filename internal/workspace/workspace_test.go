@@ -0,0 +1,51 @@
+package workspace
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestScope(t *testing.T) {
+	ctx := context.Background()
+	assert.Nil(t, Scope(ctx))
+	assert.Equal(t, Default, Of(ctx))
+
+	scoped := WithID(ctx, 7)
+	assert.Equal(t, int64(7), *Scope(scoped))
+	assert.Equal(t, int64(7), Of(scoped))
+
+	assert.Nil(t, Scope(Unscoped(scoped)))
+	assert.Equal(t, Default, Of(Unscoped(scoped)))
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		got  error
+	}{
+		{name: "workspace", got: Workspace{Name: "growth", MaxLinks: 100}.Validate()},
+		{name: "workspace without name", err: ErrInvalidWorkspace, got: Workspace{}.Validate()},
+		{name: "negative quota", err: ErrInvalidWorkspace, got: Workspace{Name: "growth", MaxLinks: -1}.Validate()},
+		{name: "member", got: Member{Name: "alice"}.Validate()},
+		{name: "owner", got: Member{Name: "alice", Role: RoleOwner}.Validate()},
+		{name: "unknown role", err: ErrInvalidMember, got: Member{Name: "alice", Role: "admin"}.Validate()},
+		{name: "long name", err: ErrInvalidMember, got: Member{Name: strings.Repeat("a", 65)}.Validate()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.got, tt.err)
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	a, b := NewKey(), NewKey()
+	assert.NotEqual(t, a, b)
+	assert.True(t, strings.HasPrefix(a, keyPrefix))
+	assert.Equal(t, HashKey(a), HashKey(a))
+	assert.NotEqual(t, HashKey(a), HashKey(b))
+}